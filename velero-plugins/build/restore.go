@@ -55,11 +55,12 @@ func UpdateCommonSpec(
 	spec buildv1API.CommonSpec,
 	registry string,
 	backupRegistry string,
+	namespace string,
 	secretList *corev1API.SecretList,
 	log logrus.FieldLogger,
 	namespaceMapping map[string]string,
 ) (buildv1API.CommonSpec, error) {
-	newSecret, err := common.UpdatePullSecret(spec.Output.PushSecret, secretList, log)
+	newSecret, err := common.UpdatePullSecret(spec.Output.PushSecret, namespace, secretList, log)
 	if err != nil {
 		return spec, err
 	}
@@ -73,7 +74,7 @@ func UpdateCommonSpec(
 	}
 
 	if spec.Strategy.SourceStrategy != nil {
-		newSecret, err := common.UpdatePullSecret(spec.Strategy.SourceStrategy.PullSecret, secretList, log)
+		newSecret, err := common.UpdatePullSecret(spec.Strategy.SourceStrategy.PullSecret, namespace, secretList, log)
 		if err != nil {
 			return spec, err
 		}
@@ -86,7 +87,7 @@ func UpdateCommonSpec(
 
 	}
 	if spec.Strategy.DockerStrategy != nil {
-		newSecret, err := common.UpdatePullSecret(spec.Strategy.DockerStrategy.PullSecret, secretList, log)
+		newSecret, err := common.UpdatePullSecret(spec.Strategy.DockerStrategy.PullSecret, namespace, secretList, log)
 		if err != nil {
 			return spec, err
 		}
@@ -100,7 +101,7 @@ func UpdateCommonSpec(
 		}
 	}
 	if spec.Strategy.CustomStrategy != nil {
-		newSecret, err := common.UpdatePullSecret(spec.Strategy.CustomStrategy.PullSecret, secretList, log)
+		newSecret, err := common.UpdatePullSecret(spec.Strategy.CustomStrategy.PullSecret, namespace, secretList, log)
 		if err != nil {
 			return spec, err
 		}
@@ -112,8 +113,9 @@ func UpdateCommonSpec(
 		spec.Strategy.CustomStrategy.From = newFrom
 	}
 	if spec.Source.Images != nil {
-		for _, imageSource := range spec.Source.Images {
-			newSecret, err := common.UpdatePullSecret(imageSource.PullSecret, secretList, log)
+		for n := range spec.Source.Images {
+			imageSource := &spec.Source.Images[n]
+			newSecret, err := common.UpdatePullSecret(imageSource.PullSecret, namespace, secretList, log)
 			if err != nil {
 				return spec, err
 			}
@@ -100,7 +100,7 @@ func TestRestorePluginExecute(t *testing.T) {
 		}
 
 		namespaceMapping := make(map[string]string)
-		newCommonSpec, err := UpdateCommonSpec(build.Spec.CommonSpec, "registry", "backupRegistry", &secretList, test.NewLogger(), namespaceMapping)
+		newCommonSpec, err := UpdateCommonSpec(build.Spec.CommonSpec, "registry", "backupRegistry", "default", &secretList, test.NewLogger(), namespaceMapping)
 		assert.Equal(t, err, nil)
 		build.Spec.CommonSpec = newCommonSpec
 
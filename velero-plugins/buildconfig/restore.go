@@ -3,6 +3,8 @@ package buildconfig
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"strings"
 
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/build"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
@@ -10,6 +12,7 @@ import (
 	buildv1API "github.com/openshift/api/build/v1"
 	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	corev1API "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
@@ -44,6 +47,27 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		return nil, err
 	}
 
+	triggerPolicy, err := common.GetTriggerPolicy(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	registry := buildconfig.Annotations[common.RestoreRegistryHostname]
+	backupRegistry := buildconfig.Annotations[common.BackupRegistryHostname]
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	updateTriggers(&buildconfig, &buildconfigUnmodified, triggerPolicy, func(imageRef string) string {
+		return common.RemapImageRef(imageRef, backupRegistry, registry, digestMapping, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	}, p.Log)
+
+	externalRegistry, err := common.GetRestoreExternalRegistry(input.Restore)
+	if err != nil {
+		p.Log.Error("[buildconfig-restore] error getting external registry: ", err)
+		return nil, err
+	}
+	if externalRegistry != nil {
+		buildconfig = p.updateOutputForExternalRegistry(buildconfig, externalRegistry, input.Restore.Spec.NamespaceMapping)
+	}
+
 	var out map[string]interface{}
 	objrec, _ := json.Marshal(buildconfig)
 	json.Unmarshal(objrec, &out)
@@ -69,10 +93,38 @@ func (p *RestorePlugin) updateSecretsAndDockerRefs(buildconfig buildv1API.BuildC
 	registry := buildconfig.Annotations[common.RestoreRegistryHostname]
 	backupRegistry := buildconfig.Annotations[common.BackupRegistryHostname]
 
-	newCommonSpec, err := build.UpdateCommonSpec(buildconfig.Spec.CommonSpec, registry, backupRegistry, secretList, p.Log, namespaceMapping)
+	newCommonSpec, err := build.UpdateCommonSpec(buildconfig.Spec.CommonSpec, registry, backupRegistry, destNamespace, secretList, p.Log, namespaceMapping)
 	if err != nil {
 		return buildconfig, err
 	}
 	buildconfig.Spec.CommonSpec = newCommonSpec
 	return buildconfig, nil
 }
+
+// updateOutputForExternalRegistry replaces an ImageStreamTag build output with the matching
+// DockerImage reference in the external registry, as builds can't push to ImageStreamTags
+// on clusters without an internal registry. The push secret is set to the external registry
+// secret, which has to exist under the same name in the namespace of the BuildConfig.
+func (p *RestorePlugin) updateOutputForExternalRegistry(buildconfig buildv1API.BuildConfig, externalRegistry *common.ExternalRegistry, namespaceMapping map[string]string) buildv1API.BuildConfig {
+	to := buildconfig.Spec.Output.To
+	if to == nil || to.Kind != "ImageStreamTag" {
+		return buildconfig
+	}
+	namespace := to.Namespace
+	if namespace == "" {
+		namespace = buildconfig.Namespace
+	} else if namespaceMapping[namespace] != "" {
+		namespace = namespaceMapping[namespace]
+	}
+	name, tag := to.Name, "latest"
+	if nameTag := strings.SplitN(to.Name, ":", 2); len(nameTag) == 2 {
+		name, tag = nameTag[0], nameTag[1]
+	}
+	newRef := fmt.Sprintf("%s/%s/%s:%s", externalRegistry.Registry, namespace, name, tag)
+	p.Log.Infof("[buildconfig-restore] replacing output ImageStreamTag %s with external image %s", to.Name, newRef)
+	buildconfig.Spec.Output.To = &corev1API.ObjectReference{Kind: "DockerImage", Name: newRef}
+	if externalRegistry.Secret != "" {
+		buildconfig.Spec.Output.PushSecret = &corev1API.LocalObjectReference{Name: externalRegistry.Secret}
+	}
+	return buildconfig
+}
@@ -0,0 +1,57 @@
+package buildconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	buildv1API "github.com/openshift/api/build/v1"
+	corev1API "k8s.io/api/core/v1"
+)
+
+func TestUpdateOutputForExternalRegistry(t *testing.T) {
+	tests := []struct {
+		name             string
+		to               *corev1API.ObjectReference
+		externalRegistry common.ExternalRegistry
+		wantOutput       buildv1API.BuildOutput
+	}{
+		{
+			name:             "image stream tag with secret",
+			to:               &corev1API.ObjectReference{Kind: "ImageStreamTag", Name: "web:v1", Namespace: "src"},
+			externalRegistry: common.ExternalRegistry{Registry: "quay.io/myorg", Secret: "quay-creds"},
+			wantOutput: buildv1API.BuildOutput{
+				To:         &corev1API.ObjectReference{Kind: "DockerImage", Name: "quay.io/myorg/dest/web:v1"},
+				PushSecret: &corev1API.LocalObjectReference{Name: "quay-creds"},
+			},
+		},
+		{
+			name:             "image stream tag without secret",
+			to:               &corev1API.ObjectReference{Kind: "ImageStreamTag", Name: "web"},
+			externalRegistry: common.ExternalRegistry{Registry: "quay.io/myorg"},
+			wantOutput: buildv1API.BuildOutput{
+				To: &corev1API.ObjectReference{Kind: "DockerImage", Name: "quay.io/myorg/app/web:latest"},
+			},
+		},
+		{
+			name:             "docker image",
+			to:               &corev1API.ObjectReference{Kind: "DockerImage", Name: "docker.io/library/web:v1"},
+			externalRegistry: common.ExternalRegistry{Registry: "quay.io/myorg", Secret: "quay-creds"},
+			wantOutput: buildv1API.BuildOutput{
+				To: &corev1API.ObjectReference{Kind: "DockerImage", Name: "docker.io/library/web:v1"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildconfig := testBuildConfig(0)
+			buildconfig.Spec.Output.To = tt.to
+			p := &RestorePlugin{Log: test.NewLogger()}
+			got := p.updateOutputForExternalRegistry(*buildconfig, &tt.externalRegistry, map[string]string{"src": "dest"})
+			if !reflect.DeepEqual(got.Spec.Output, tt.wantOutput) {
+				t.Errorf("updateOutputForExternalRegistry() output = %+v, want %+v", got.Spec.Output, tt.wantOutput)
+			}
+		})
+	}
+}
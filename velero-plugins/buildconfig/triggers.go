@@ -0,0 +1,117 @@
+package buildconfig
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	buildv1API "github.com/openshift/api/build/v1"
+	"github.com/sirupsen/logrus"
+	corev1API "k8s.io/api/core/v1"
+)
+
+// updateTriggers keeps restored triggers of buildconfig from starting builds. The last triggered image IDs of
+// the ImageChange triggers, recorded in the status of buildconfigFromBackup which is not restored, are set on the
+// triggers and remapped to the restored images. With the pause policy ImageChange triggers are paused and the
+// ConfigChange trigger, which starts a build as soon as a buildconfig without builds is created, is removed. Paused
+// and removed trigger types are recorded in the PausedTriggers annotation.
+func updateTriggers(buildconfig, buildconfigFromBackup *buildv1API.BuildConfig, policy string, remapImage func(string) string, log logrus.FieldLogger) {
+	paused := map[buildv1API.BuildTriggerType]bool{}
+	triggers := []buildv1API.BuildTriggerPolicy{}
+	for _, trigger := range buildconfig.Spec.Triggers {
+		switch trigger.Type {
+		case buildv1API.ConfigChangeBuildTriggerType:
+			if policy == common.TriggersPause {
+				log.Infof("[buildconfig-restore] removing config change trigger of buildconfig %s", buildconfig.Name)
+				paused[trigger.Type] = true
+				continue
+			}
+		case buildv1API.ImageChangeBuildTriggerType:
+			if trigger.ImageChange == nil {
+				break
+			}
+			lastTriggeredImageID := trigger.ImageChange.LastTriggeredImageID
+			if id := lastTriggeredImageIDFromStatus(buildconfigFromBackup, backupImageChangeTrigger(buildconfigFromBackup, trigger.ImageChange.From)); id != "" {
+				lastTriggeredImageID = id
+			}
+			if lastTriggeredImageID != "" {
+				trigger.ImageChange.LastTriggeredImageID = remapImage(lastTriggeredImageID)
+			}
+			if policy == common.TriggersPause && !trigger.ImageChange.Paused {
+				log.Infof("[buildconfig-restore] pausing image change trigger of buildconfig %s", buildconfig.Name)
+				trigger.ImageChange.Paused = true
+				paused[trigger.Type] = true
+			}
+		}
+		triggers = append(triggers, trigger)
+	}
+	buildconfig.Spec.Triggers = triggers
+	if len(paused) == 0 {
+		return
+	}
+	types := []string{}
+	for _, triggerType := range []buildv1API.BuildTriggerType{buildv1API.ConfigChangeBuildTriggerType, buildv1API.ImageChangeBuildTriggerType} {
+		if paused[triggerType] {
+			types = append(types, string(triggerType))
+		}
+	}
+	if buildconfig.Annotations == nil {
+		buildconfig.Annotations = map[string]string{}
+	}
+	buildconfig.Annotations[common.PausedTriggers] = strings.Join(types, ",")
+}
+
+// backupImageChangeTrigger returns the ImageChange trigger of buildconfig with the from reference from, or nil
+func backupImageChangeTrigger(buildconfig *buildv1API.BuildConfig, from *corev1API.ObjectReference) *buildv1API.ImageChangeTrigger {
+	for _, trigger := range buildconfig.Spec.Triggers {
+		if trigger.Type != buildv1API.ImageChangeBuildTriggerType || trigger.ImageChange == nil {
+			continue
+		}
+		if reflect.DeepEqual(trigger.ImageChange.From, from) {
+			return trigger.ImageChange
+		}
+	}
+	return nil
+}
+
+// lastTriggeredImageIDFromStatus returns the last triggered image ID recorded in the status of buildconfig for
+// an ImageChange trigger, whose image is the strategy image if the trigger has no from reference
+func lastTriggeredImageIDFromStatus(buildconfig *buildv1API.BuildConfig, trigger *buildv1API.ImageChangeTrigger) string {
+	if trigger == nil {
+		return ""
+	}
+	from := trigger.From
+	if from == nil {
+		from = strategyFrom(buildconfig.Spec.Strategy)
+	}
+	if from == nil || from.Kind != "ImageStreamTag" {
+		return ""
+	}
+	namespace := from.Namespace
+	if namespace == "" {
+		namespace = buildconfig.Namespace
+	}
+	for _, status := range buildconfig.Status.ImageChangeTriggers {
+		statusNamespace := status.From.Namespace
+		if statusNamespace == "" {
+			statusNamespace = buildconfig.Namespace
+		}
+		if status.From.Name == from.Name && statusNamespace == namespace {
+			return status.LastTriggeredImageID
+		}
+	}
+	return ""
+}
+
+// strategyFrom returns the image a build strategy builds from
+func strategyFrom(strategy buildv1API.BuildStrategy) *corev1API.ObjectReference {
+	switch {
+	case strategy.SourceStrategy != nil:
+		return &strategy.SourceStrategy.From
+	case strategy.DockerStrategy != nil:
+		return strategy.DockerStrategy.From
+	case strategy.CustomStrategy != nil:
+		return &strategy.CustomStrategy.From
+	}
+	return nil
+}
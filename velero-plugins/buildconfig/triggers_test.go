@@ -0,0 +1,125 @@
+package buildconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	buildv1API "github.com/openshift/api/build/v1"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testBuildConfig(lastVersion int64) *buildv1API.BuildConfig {
+	return &buildv1API.BuildConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+		Spec: buildv1API.BuildConfigSpec{
+			CommonSpec: buildv1API.CommonSpec{
+				Strategy: buildv1API.BuildStrategy{
+					SourceStrategy: &buildv1API.SourceBuildStrategy{
+						From: corev1API.ObjectReference{Kind: "ImageStreamTag", Name: "nodejs:18", Namespace: "openshift"},
+					},
+				},
+			},
+			Triggers: []buildv1API.BuildTriggerPolicy{
+				{Type: buildv1API.ConfigChangeBuildTriggerType},
+				{Type: buildv1API.ImageChangeBuildTriggerType, ImageChange: &buildv1API.ImageChangeTrigger{}},
+				{Type: buildv1API.GenericWebHookBuildTriggerType},
+			},
+		},
+		Status: buildv1API.BuildConfigStatus{
+			LastVersion: lastVersion,
+			ImageChangeTriggers: []buildv1API.ImageChangeTriggerStatus{
+				{LastTriggeredImageID: "old-registry/openshift/nodejs@sha256:1234", From: buildv1API.ImageStreamTagReference{Name: "nodejs:18", Namespace: "openshift"}},
+			},
+		},
+	}
+}
+
+func TestUpdateTriggers(t *testing.T) {
+	remapImage := func(imageRef string) string {
+		return strings.Replace(imageRef, "old-registry", "new-registry", 1)
+	}
+	tests := []struct {
+		name        string
+		lastVersion int64
+		policy      string
+		wantTypes   []buildv1API.BuildTriggerType
+		wantPaused  bool
+		annotation  string
+	}{
+		{
+			name:      "never built",
+			policy:    common.TriggersRemap,
+			wantTypes: []buildv1API.BuildTriggerType{buildv1API.ConfigChangeBuildTriggerType, buildv1API.ImageChangeBuildTriggerType, buildv1API.GenericWebHookBuildTriggerType},
+		},
+		{
+			name:        "built before",
+			lastVersion: 3,
+			policy:      common.TriggersRemap,
+			wantTypes:   []buildv1API.BuildTriggerType{buildv1API.ConfigChangeBuildTriggerType, buildv1API.ImageChangeBuildTriggerType, buildv1API.GenericWebHookBuildTriggerType},
+		},
+		{
+			name:       "pause",
+			policy:     common.TriggersPause,
+			wantTypes:  []buildv1API.BuildTriggerType{buildv1API.ImageChangeBuildTriggerType, buildv1API.GenericWebHookBuildTriggerType},
+			wantPaused: true,
+			annotation: "ConfigChange,ImageChange",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buildconfig := testBuildConfig(tt.lastVersion)
+			buildconfig.Status = buildv1API.BuildConfigStatus{}
+			updateTriggers(buildconfig, testBuildConfig(tt.lastVersion), tt.policy, remapImage, test.NewLogger())
+			types := []buildv1API.BuildTriggerType{}
+			var imageChange *buildv1API.ImageChangeTrigger
+			for _, trigger := range buildconfig.Spec.Triggers {
+				types = append(types, trigger.Type)
+				if trigger.ImageChange != nil {
+					imageChange = trigger.ImageChange
+				}
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("updateTriggers() trigger types = %v, want %v", types, tt.wantTypes)
+			}
+			if imageChange.LastTriggeredImageID != "new-registry/openshift/nodejs@sha256:1234" {
+				t.Errorf("updateTriggers() lastTriggeredImageID = %q, want remapped status value", imageChange.LastTriggeredImageID)
+			}
+			if imageChange.Paused != tt.wantPaused {
+				t.Errorf("updateTriggers() paused = %v, want %v", imageChange.Paused, tt.wantPaused)
+			}
+			if annotation := buildconfig.Annotations[common.PausedTriggers]; annotation != tt.annotation {
+				t.Errorf("updateTriggers() %s = %q, want %q", common.PausedTriggers, annotation, tt.annotation)
+			}
+		})
+	}
+}
+
+func TestUpdateTriggersMatchesFrom(t *testing.T) {
+	base := &corev1API.ObjectReference{Kind: "ImageStreamTag", Name: "base:latest"}
+	backup := testBuildConfig(3)
+	backup.Spec.Triggers = []buildv1API.BuildTriggerPolicy{
+		{Type: buildv1API.ImageChangeBuildTriggerType, ImageChange: &buildv1API.ImageChangeTrigger{}},
+		{Type: buildv1API.ImageChangeBuildTriggerType, ImageChange: &buildv1API.ImageChangeTrigger{From: base}},
+	}
+	backup.Status.ImageChangeTriggers = append(backup.Status.ImageChangeTriggers, buildv1API.ImageChangeTriggerStatus{
+		LastTriggeredImageID: "old-registry/app/base@sha256:5678", From: buildv1API.ImageStreamTagReference{Name: "base:latest"},
+	})
+	// the restored triggers are in a different order than in the backup
+	buildconfig := testBuildConfig(0)
+	buildconfig.Status = buildv1API.BuildConfigStatus{}
+	buildconfig.Spec.Triggers = []buildv1API.BuildTriggerPolicy{
+		{Type: buildv1API.ImageChangeBuildTriggerType, ImageChange: &buildv1API.ImageChangeTrigger{From: base.DeepCopy()}},
+		{Type: buildv1API.ImageChangeBuildTriggerType, ImageChange: &buildv1API.ImageChangeTrigger{}},
+	}
+	updateTriggers(buildconfig, backup, common.TriggersRemap, func(imageRef string) string { return imageRef }, test.NewLogger())
+	want := []string{"old-registry/app/base@sha256:5678", "old-registry/openshift/nodejs@sha256:1234"}
+	for i, trigger := range buildconfig.Spec.Triggers {
+		if trigger.ImageChange.LastTriggeredImageID != want[i] {
+			t.Errorf("updateTriggers() trigger %d lastTriggeredImageID = %q, want %q", i, trigger.ImageChange.LastTriggeredImageID, want[i])
+		}
+	}
+}
@@ -8,8 +8,10 @@ import (
 	ocpirconfigv1 "github.com/openshift/client-go/imageregistry/clientset/versioned/typed/imageregistry/v1"
 	routev1 "github.com/openshift/client-go/route/clientset/versioned/typed/route/v1"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 	appsv1 "k8s.io/client-go/kubernetes/typed/apps/v1"
 	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	storagev1 "k8s.io/client-go/kubernetes/typed/storage/v1"
 	"k8s.io/client-go/rest"
 )
 
@@ -41,6 +43,12 @@ var routeClientError error
 var buildClient *buildv1.BuildV1Client
 var buildClientError error
 
+var storageClient *storagev1.StorageV1Client
+var storageClientError error
+
+var dynamicClient dynamic.Interface
+var dynamicClientError error
+
 var inClusterConfig *rest.Config
 
 func SetInClusterConfig(config *rest.Config) {
@@ -75,6 +83,16 @@ func CoreClientFromConfig(config *rest.Config) (*corev1.CoreV1Client, error) {
 	return client, nil
 }
 
+// SetCoreClient makes CoreClient return client, e.g. a client of a test server. The returned func
+// restores the previous client.
+func SetCoreClient(client *corev1.CoreV1Client) func() {
+	previous, previousError := coreClient, coreClientError
+	coreClient, coreClientError = client, nil
+	return func() {
+		coreClient, coreClientError = previous, previousError
+	}
+}
+
 func newCoreClient() (*corev1.CoreV1Client, error) {
 	config, err := GetInClusterConfig()
 	if err != nil {
@@ -195,6 +213,15 @@ func OCPAppsClient() (*ocpappsv1.AppsV1Client, error) {
 	return ocpAppsClient, ocpAppsClientError
 }
 
+// SetOCPAppsClient makes OCPAppsClient return client. The returned func restores the previous client.
+func SetOCPAppsClient(client *ocpappsv1.AppsV1Client) func() {
+	previous, previousError := ocpAppsClient, ocpAppsClientError
+	ocpAppsClient, ocpAppsClientError = client, nil
+	return func() {
+		ocpAppsClient, ocpAppsClientError = previous, previousError
+	}
+}
+
 func newOCPAppsClient() (*ocpappsv1.AppsV1Client, error) {
 	config, err := GetInClusterConfig()
 	if err != nil {
@@ -245,6 +272,55 @@ func newOCPImageRegistryConfigClient() (*ocpirconfigv1.ImageregistryV1Client, er
 	return client, nil
 }
 
+// StorageClient returns a kubernetes StorageV1Client
+func StorageClient() (*storagev1.StorageV1Client, error) {
+	if storageClient == nil && storageClientError == nil {
+		storageClient, storageClientError = newStorageClient()
+	}
+	return storageClient, storageClientError
+}
+
+func newStorageClient() (*storagev1.StorageV1Client, error) {
+	config, err := GetInClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := storagev1.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// DynamicClient returns a kubernetes dynamic client
+func DynamicClient() (dynamic.Interface, error) {
+	if dynamicClient == nil && dynamicClientError == nil {
+		dynamicClient, dynamicClientError = newDynamicClient()
+	}
+	return dynamicClient, dynamicClientError
+}
+
+// SetDynamicClient makes DynamicClient return client. The returned func restores the previous client.
+func SetDynamicClient(client dynamic.Interface) func() {
+	previous, previousError := dynamicClient, dynamicClientError
+	dynamicClient, dynamicClientError = client, nil
+	return func() {
+		dynamicClient, dynamicClientError = previous, previousError
+	}
+}
+
+func newDynamicClient() (dynamic.Interface, error) {
+	config, err := GetInClusterConfig()
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
 func init() {
 	coreClient, coreClientError = nil, nil
 	imageClient, imageClientError = nil, nil
@@ -253,4 +329,6 @@ func init() {
 	buildClient, buildClientError = nil, nil
 	ocpAppsClient, ocpAppsClientError = nil, nil
 	appsClient, appsClientError = nil, nil
+	storageClient, storageClientError = nil, nil
+	dynamicClient, dynamicClientError = nil, nil
 }
@@ -1,7 +1,9 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"k8s.io/apimachinery/pkg/runtime"
 
@@ -63,11 +65,35 @@ func (p *BackupPlugin) Execute(item runtime.Unstructured, backup *v1.Backup) (ru
 	}
 
 	annotations[BackupServerVersion] = fmt.Sprintf("%v.%v", major, minor)
-	registryHostname, err := GetRegistryInfo(p.Log)
+	registryHostnames, err := GetRegistryHostnames(backup.Namespace, p.Log)
 	if err != nil {
 		return nil, nil, err
 	}
-	annotations[BackupRegistryHostname] = registryHostname
+	annotations[BackupRegistryHostname] = registryHostnames.Hostname
+	if len(registryHostnames.Aliases) > 0 {
+		// references to images in the registry may be written with any of them
+		annotations[BackupRegistryAliases] = strings.Join(registryHostnames.Aliases, ",")
+	}
+
+	policy, err := GetClusterCompatibilityPolicy(backup.UID, backup.Namespace, backup.Annotations)
+	if err != nil {
+		return nil, nil, err
+	}
+	if policy != ClusterCompatibilityDisabled {
+		fingerprint, err := GetClusterFingerprint(backup.UID, p.Log)
+		if err != nil {
+			return nil, nil, err
+		}
+		// compared with the restoring cluster, recorded once on the backup
+		recorded, err := json.Marshal(fingerprint)
+		if err != nil {
+			return nil, nil, err
+		}
+		err = AnnotateBackup(backup, BackupClusterFingerprint, string(recorded))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
 
 	metadata.SetAnnotations(annotations)
 	return item, nil, nil
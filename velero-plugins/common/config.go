@@ -0,0 +1,192 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// GetPluginConfig returns the data of the plugin ConfigMap (RegistryConfigMap) in the
+// velero namespace. A missing ConfigMap is not an error and results in an empty map.
+// The result is cached per backup/restore UID.
+func GetPluginConfig(uid types.UID, namespace string) (map[string]string, error) {
+	if BackupUidMap == nil {
+		BackupUidMap = make(map[types.UID]*CommonStruct)
+	}
+	if BackupUidMap[uid] == nil {
+		BackupUidMap[uid] = &CommonStruct{}
+	}
+	BackupUidMap[uid].JustAccessed()
+	if BackupUidMap[uid].PluginConfig != nil {
+		return BackupUidMap[uid].PluginConfig, nil
+	}
+
+	data, err := ReadPluginConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+	BackupUidMap[uid].PluginConfig = data
+	return data, nil
+}
+
+// ReadPluginConfig returns the data of the plugin ConfigMap in the velero namespace without
+// caching it, for settings not tied to a backup/restore.
+func ReadPluginConfig(namespace string) (map[string]string, error) {
+	client, err := clients.CoreClient()
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]string{}
+	cm, err := client.ConfigMaps(namespace).Get(context.Background(), RegistryConfigMap, metav1.GetOptions{})
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	if err == nil && cm.Data != nil {
+		data = cm.Data
+	}
+	return data, nil
+}
+
+// PluginConfigValue returns the value of an option annotation from plugin ConfigMap data
+func PluginConfigValue(config map[string]string, annotation string) string {
+	return config[pluginConfigKey(annotation)]
+}
+
+// GetPluginOption returns the value of an option set either as annotation on the
+// backup/restore or as key in the plugin ConfigMap. The annotation takes precedence.
+// The ConfigMap key is the annotation name without its prefix, e.g.
+// "oadp.openshift.io/restore-external-registry" is read from "restore-external-registry".
+func GetPluginOption(uid types.UID, namespace string, annotations map[string]string, annotation string) (string, error) {
+	if val, ok := annotations[annotation]; ok {
+		return val, nil
+	}
+	config, err := GetPluginConfig(uid, namespace)
+	if err != nil {
+		return "", err
+	}
+	return PluginConfigValue(config, annotation), nil
+}
+
+// ExternalRegistry describes an external registry used in place of the internal registry
+// or the BSL registry
+type ExternalRegistry struct {
+	// Registry host with an optional repository prefix, e.g. quay.io/myorg
+	Registry string
+	// Name of a dockerconfigjson secret in the velero namespace
+	Secret   string
+	Insecure bool
+	// Template of the repository below Registry images are backed up to, backups only
+	RepositoryTemplate string
+}
+
+// GetRestoreExternalRegistry returns the external registry configured for the restore,
+// or nil when images are restored into the internal registry.
+func GetRestoreExternalRegistry(restore *velero.Restore) (*ExternalRegistry, error) {
+	registry, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, RestoreExternalRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return getExternalRegistry(restore.UID, restore.Namespace, restore.Annotations, registry, RestoreExternalRegistrySecret, RestoreExternalRegistryInsecure)
+}
+
+// GetBackupExternalRegistry returns the external registry configured for the backup,
+// or nil when images are backed up to the BSL.
+func GetBackupExternalRegistry(backup *velero.Backup) (*ExternalRegistry, error) {
+	registry, err := GetPluginOption(backup.UID, backup.Namespace, backup.Annotations, BackupExternalRegistry)
+	if err != nil {
+		return nil, err
+	}
+	external, err := getExternalRegistry(backup.UID, backup.Namespace, backup.Annotations, registry, BackupExternalRegistrySecret, BackupExternalRegistryInsecure)
+	if err != nil || external == nil {
+		return external, err
+	}
+	external.RepositoryTemplate, err = GetPluginOption(backup.UID, backup.Namespace, backup.Annotations, BackupExternalRegistryRepository)
+	if err != nil {
+		return nil, err
+	}
+	return external, nil
+}
+
+// GetBackupExternalRegistryForRestore returns the external registry images were backed up to,
+// with the credentials configured for the restore.
+func GetBackupExternalRegistryForRestore(restore *velero.Restore, registry string) (*ExternalRegistry, error) {
+	return getExternalRegistry(restore.UID, restore.Namespace, restore.Annotations, registry, BackupExternalRegistrySecret, BackupExternalRegistryInsecure)
+}
+
+func getExternalRegistry(uid types.UID, namespace string, annotations map[string]string, registry, secretAnnotation, insecureAnnotation string) (*ExternalRegistry, error) {
+	registry = strings.TrimSuffix(strings.TrimPrefix(registry, "docker://"), "/")
+	if registry == "" {
+		return nil, nil
+	}
+	secret, err := GetPluginOption(uid, namespace, annotations, secretAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	insecure, err := GetPluginOption(uid, namespace, annotations, insecureAnnotation)
+	if err != nil {
+		return nil, err
+	}
+	return &ExternalRegistry{
+		Registry: registry,
+		Secret:   secret,
+		Insecure: insecure == "true",
+	}, nil
+}
+
+// GetRestoreRegistryInfo returns the registry restored images are pushed to and referenced
+// from, and whether it is an external registry. Without an external registry configured
+// the internal registry of the destination cluster is returned.
+func GetRestoreRegistryInfo(restore *velero.Restore, log logrus.FieldLogger) (string, bool, error) {
+	external, err := GetRestoreExternalRegistry(restore)
+	if err != nil {
+		return "", false, err
+	}
+	if external != nil {
+		return external.Registry, true, nil
+	}
+	registry, err := GetRegistryHostnames(restore.Namespace, log)
+	if err != nil {
+		return "", false, err
+	}
+	return registry.Hostname, false, nil
+}
+
+// ConvertDeploymentConfigs returns true if a restore converts DeploymentConfigs to Deployments
+func ConvertDeploymentConfigs(restore *velero.Restore) (bool, error) {
+	convert, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, RestoreConvertDeploymentConfigs)
+	if err != nil {
+		return false, err
+	}
+	return convert == "true", nil
+}
+
+// GetTriggerPolicy returns the RestoreTriggers option of a restore
+func GetTriggerPolicy(restore *velero.Restore) (string, error) {
+	policy, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, RestoreTriggers)
+	if err != nil {
+		return "", err
+	}
+	switch policy {
+	case "":
+		return TriggersRemap, nil
+	case TriggersRemap, TriggersPause:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, must be %s or %s", RestoreTriggers, policy, TriggersRemap, TriggersPause)
+	}
+}
+
+// pluginConfigKey returns the ConfigMap key used for an option annotation
+func pluginConfigKey(annotation string) string {
+	if i := strings.LastIndex(annotation, "/"); i >= 0 {
+		return annotation[i+1:]
+	}
+	return annotation
+}
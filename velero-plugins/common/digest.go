@@ -0,0 +1,65 @@
+package common
+
+import (
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// DigestMappingReader reads the source to BSL digest mapping of all ImageStreams of a backup from its BSL or
+// image backup replicas. It is set by the imagestream package.
+var DigestMappingReader func(backup *velero.Backup, log logrus.FieldLogger) (map[string]string, error)
+
+// GetDigestMappingForRestore returns the source to BSL digest mapping of all ImageStreams in the backup being
+// restored. It is empty unless the backup recorded mappings, which it does when images are backed up to the BSL
+// or to an external registry. Errors reading the mapping are logged and result in an empty mapping, images pinned
+// to changed digests are then restored as is. The result is cached per restore UID.
+func GetDigestMappingForRestore(restore *velero.Restore, log logrus.FieldLogger) map[string]string {
+	if restore.Labels[MigrationApplicationLabelKey] == MigrationApplicationLabelValue {
+		return map[string]string{}
+	}
+	if BackupUidMap == nil {
+		BackupUidMap = make(map[types.UID]*CommonStruct)
+	}
+	if BackupUidMap[restore.UID] == nil {
+		BackupUidMap[restore.UID] = &CommonStruct{}
+	}
+	BackupUidMap[restore.UID].JustAccessed()
+	if BackupUidMap[restore.UID].DigestMapping != nil {
+		return BackupUidMap[restore.UID].DigestMapping
+	}
+
+	digestMapping := readDigestMappingForRestore(restore, log)
+	BackupUidMap[restore.UID].DigestMapping = digestMapping
+	return digestMapping
+}
+
+func readDigestMappingForRestore(restore *velero.Restore, log logrus.FieldLogger) map[string]string {
+	backup, err := GetBackup(restore.UID, restore.Spec.BackupName, restore.Namespace)
+	if err != nil {
+		log.Warnf("[digest-mapping] error getting backup %s, image digests are not remapped: %v", restore.Spec.BackupName, err)
+		return map[string]string{}
+	}
+	if backup.Annotations[ImageDigestMappings] != "true" || DigestMappingReader == nil {
+		return map[string]string{}
+	}
+	digestMapping, err := DigestMappingReader(backup, log)
+	if err != nil {
+		log.Warnf("[digest-mapping] error reading digest mappings of backup %s, image digests are not remapped: %v", backup.Name, err)
+		return map[string]string{}
+	}
+	log.Infof("[digest-mapping] loaded %d digest mappings for backup %s", len(digestMapping), backup.Name)
+	return digestMapping
+}
+
+// RecordRestoredDigests updates the digest mapping of the restore with the digests images were restored with
+// when they differ from their digests in the BSL, e.g. after decryption. restoredDigests maps BSL to restored
+// digests. Workloads restored afterwards are updated to the restored digests.
+func RecordRestoredDigests(restore *velero.Restore, restoredDigests map[string]string, log logrus.FieldLogger) {
+	digestMapping := GetDigestMappingForRestore(restore, log)
+	for sourceDigest, bslDigest := range digestMapping {
+		if restoredDigest, ok := restoredDigests[bslDigest]; ok {
+			digestMapping[sourceDigest] = restoredDigest
+		}
+	}
+}
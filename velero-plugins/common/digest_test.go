@@ -0,0 +1,72 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestReadDigestMappingForRestore(t *testing.T) {
+	mapping := map[string]string{"sha256:old": "sha256:new"}
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		readErr     error
+		want        map[string]string
+		wantRead    bool
+	}{
+		{
+			name: "backup without mappings",
+			want: map[string]string{},
+		},
+		{
+			name:        "backup with mappings",
+			annotations: map[string]string{ImageDigestMappings: "true"},
+			want:        mapping,
+			wantRead:    true,
+		},
+		{
+			name:        "mappings can not be read",
+			annotations: map[string]string{ImageDigestMappings: "true"},
+			readErr:     errors.New("bsl unavailable"),
+			want:        map[string]string{},
+			wantRead:    true,
+		},
+	}
+	defer func(reader func(*velero.Backup, logrus.FieldLogger) (map[string]string, error)) {
+		DigestMappingReader = reader
+	}(DigestMappingReader)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid := types.UID("restore-" + tt.name)
+			BackupUidMap = map[types.UID]*CommonStruct{
+				uid: {Backup: &velero.Backup{ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "openshift-adp", Annotations: tt.annotations}}},
+			}
+			read := false
+			DigestMappingReader = func(backup *velero.Backup, log logrus.FieldLogger) (map[string]string, error) {
+				read = true
+				if tt.readErr != nil {
+					return nil, tt.readErr
+				}
+				return mapping, nil
+			}
+			restore := &velero.Restore{
+				ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "openshift-adp", UID: uid},
+				Spec:       velero.RestoreSpec{BackupName: "backup"},
+			}
+			got := readDigestMappingForRestore(restore, test.NewLogger())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("readDigestMappingForRestore() = %v, want %v", got, tt.want)
+			}
+			if read != tt.wantRead {
+				t.Errorf("readDigestMappingForRestore() read mappings = %v, want %v", read, tt.wantRead)
+			}
+		})
+	}
+}
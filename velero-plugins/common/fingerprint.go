@@ -0,0 +1,257 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/openshift"
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/version"
+)
+
+// name of the cluster scoped config.openshift.io objects
+const clusterConfigName = "cluster"
+
+// ClusterFingerprint describes the cluster a backup was taken on. Fields the cluster does not
+// provide, e.g. on non OpenShift clusters, are empty.
+type ClusterFingerprint struct {
+	// OpenShift version from the ClusterVersion
+	Version string `json:"version,omitempty"`
+	// enabled cluster capabilities
+	Capabilities []string `json:"capabilities,omitempty"`
+	// infrastructure platform type, e.g. AWS
+	Platform string `json:"platform,omitempty"`
+	// cluster network plugin, e.g. OVNKubernetes
+	NetworkType    string   `json:"networkType,omitempty"`
+	StorageClasses []string `json:"storageClasses,omitempty"`
+	// default domain of routes
+	IngressDomain string `json:"ingressDomain,omitempty"`
+	// served API group versions, e.g. apps.openshift.io/v1
+	APIGroups []string `json:"apiGroups,omitempty"`
+}
+
+// CompatibilityReport lists the differences between a backup cluster and the restoring cluster
+type CompatibilityReport struct {
+	// differences likely to break restored resources
+	Incompatibilities []string
+	// other differences
+	Warnings []string
+}
+
+// GetClusterFingerprint returns the fingerprint of this cluster. It is cached per backup/restore UID.
+func GetClusterFingerprint(uid types.UID, log logrus.FieldLogger) (*ClusterFingerprint, error) {
+	if BackupUidMap == nil {
+		BackupUidMap = make(map[types.UID]*CommonStruct)
+	}
+	if BackupUidMap[uid] == nil {
+		BackupUidMap[uid] = &CommonStruct{}
+	}
+	BackupUidMap[uid].JustAccessed()
+	if BackupUidMap[uid].ClusterFingerprint != nil {
+		return BackupUidMap[uid].ClusterFingerprint, nil
+	}
+	fingerprint, err := readClusterFingerprint(log)
+	if err != nil {
+		return nil, err
+	}
+	BackupUidMap[uid].ClusterFingerprint = fingerprint
+	return fingerprint, nil
+}
+
+// readClusterFingerprint collects the fingerprint of this cluster. Only the served API groups are
+// required; OpenShift config and storage classes are skipped with a log message if they can not be read.
+func readClusterFingerprint(log logrus.FieldLogger) (*ClusterFingerprint, error) {
+	fingerprint := &ClusterFingerprint{}
+	discoveryClient, err := clients.DiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	groups, err := discoveryClient.ServerGroups()
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups.Groups {
+		for _, groupVersion := range group.Versions {
+			fingerprint.APIGroups = append(fingerprint.APIGroups, groupVersion.GroupVersion)
+		}
+	}
+
+	if clusterVersion, err := openshift.GetClusterVersion(); err != nil {
+		log.Infof("[cluster-fingerprint] cluster version not found: %v", err)
+	} else {
+		fingerprint.Version = clusterVersion.Status.Desired.Version
+		for _, capability := range clusterVersion.Status.Capabilities.EnabledCapabilities {
+			fingerprint.Capabilities = append(fingerprint.Capabilities, string(capability))
+		}
+	}
+	configClient, err := clients.OCPConfigClient()
+	if err != nil {
+		return nil, err
+	}
+	if infrastructure, err := configClient.Infrastructures().Get(context.Background(), clusterConfigName, metav1.GetOptions{}); err != nil {
+		log.Infof("[cluster-fingerprint] infrastructure not found: %v", err)
+	} else if infrastructure.Status.PlatformStatus != nil {
+		fingerprint.Platform = string(infrastructure.Status.PlatformStatus.Type)
+	}
+	if network, err := configClient.Networks().Get(context.Background(), clusterConfigName, metav1.GetOptions{}); err != nil {
+		log.Infof("[cluster-fingerprint] network config not found: %v", err)
+	} else {
+		fingerprint.NetworkType = network.Status.NetworkType
+	}
+	if ingress, err := configClient.Ingresses().Get(context.Background(), clusterConfigName, metav1.GetOptions{}); err != nil {
+		log.Infof("[cluster-fingerprint] ingress config not found: %v", err)
+	} else {
+		fingerprint.IngressDomain = ingress.Spec.Domain
+	}
+	storageClient, err := clients.StorageClient()
+	if err != nil {
+		return nil, err
+	}
+	if storageClasses, err := storageClient.StorageClasses().List(context.Background(), metav1.ListOptions{}); err != nil {
+		log.Infof("[cluster-fingerprint] storage classes not listed: %v", err)
+	} else {
+		for _, storageClass := range storageClasses.Items {
+			fingerprint.StorageClasses = append(fingerprint.StorageClasses, storageClass.Name)
+		}
+	}
+	sort.Strings(fingerprint.APIGroups)
+	sort.Strings(fingerprint.Capabilities)
+	sort.Strings(fingerprint.StorageClasses)
+	return fingerprint, nil
+}
+
+// GetClusterCompatibilityPolicy returns the ClusterCompatibility option of a backup or restore
+func GetClusterCompatibilityPolicy(uid types.UID, namespace string, annotations map[string]string) (string, error) {
+	policy, err := GetPluginOption(uid, namespace, annotations, ClusterCompatibility)
+	if err != nil {
+		return "", err
+	}
+	switch policy {
+	case "":
+		return ClusterCompatibilityWarn, nil
+	case ClusterCompatibilityWarn, ClusterCompatibilityStrict, ClusterCompatibilityDisabled:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, must be %s, %s or %s", ClusterCompatibility, policy, ClusterCompatibilityWarn, ClusterCompatibilityStrict, ClusterCompatibilityDisabled)
+	}
+}
+
+// CheckClusterCompatibility compares the backup cluster fingerprint recorded on the backup with this cluster once
+// per restore and logs the report. With the strict policy an error is returned for every item if the clusters
+// are incompatible.
+func CheckClusterCompatibility(restore *velero.Restore, log logrus.FieldLogger) error {
+	policy, err := GetClusterCompatibilityPolicy(restore.UID, restore.Namespace, restore.Annotations)
+	if err != nil || policy == ClusterCompatibilityDisabled {
+		return err
+	}
+	if BackupUidMap == nil {
+		BackupUidMap = make(map[types.UID]*CommonStruct)
+	}
+	if BackupUidMap[restore.UID] == nil {
+		BackupUidMap[restore.UID] = &CommonStruct{}
+	}
+	BackupUidMap[restore.UID].JustAccessed()
+	report := BackupUidMap[restore.UID].Compatibility
+	if report == nil {
+		report, err = compareWithBackupCluster(restore, log)
+		if err != nil {
+			return err
+		}
+		BackupUidMap[restore.UID].Compatibility = report
+	}
+	if policy == ClusterCompatibilityStrict && len(report.Incompatibilities) > 0 {
+		return fmt.Errorf("cluster is incompatible with the backup cluster: %s", strings.Join(report.Incompatibilities, "; "))
+	}
+	return nil
+}
+
+// compareWithBackupCluster compares the fingerprint recorded on the backup of restore with this cluster and logs
+// the differences. The report is empty if the backup was taken without fingerprint.
+func compareWithBackupCluster(restore *velero.Restore, log logrus.FieldLogger) (*CompatibilityReport, error) {
+	backup, err := GetBackup(restore.UID, restore.Spec.BackupName, restore.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	recorded := backup.Annotations[BackupClusterFingerprint]
+	if recorded == "" {
+		return &CompatibilityReport{}, nil
+	}
+	backupFingerprint := &ClusterFingerprint{}
+	if err := json.Unmarshal([]byte(recorded), backupFingerprint); err != nil {
+		return nil, fmt.Errorf("errors reading %s: %v", BackupClusterFingerprint, err)
+	}
+	fingerprint, err := GetClusterFingerprint(restore.UID, log)
+	if err != nil {
+		return nil, err
+	}
+	report := CompareClusterFingerprints(backupFingerprint, fingerprint)
+	for _, incompatibility := range report.Incompatibilities {
+		log.Warnf("[cluster-compatibility] incompatible with backup cluster: %s", incompatibility)
+	}
+	for _, warning := range report.Warnings {
+		log.Infof("[cluster-compatibility] differs from backup cluster: %s", warning)
+	}
+	return report, nil
+}
+
+// CompareClusterFingerprints returns the differences of the restoring cluster to the backup cluster
+func CompareClusterFingerprints(backup, restore *ClusterFingerprint) *CompatibilityReport {
+	report := &CompatibilityReport{}
+	if backup.Version != "" && restore.Version != "" {
+		backupVersion, err1 := version.ParseGeneric(backup.Version)
+		restoreVersion, err2 := version.ParseGeneric(restore.Version)
+		switch {
+		case err1 != nil || err2 != nil:
+			report.Warnings = append(report.Warnings, fmt.Sprintf("version %s, backup cluster %s", restore.Version, backup.Version))
+		case restoreVersion.Major() < backupVersion.Major() ||
+			restoreVersion.Major() == backupVersion.Major() && restoreVersion.Minor() < backupVersion.Minor():
+			report.Incompatibilities = append(report.Incompatibilities, fmt.Sprintf("version %s is older than backup cluster version %s", restore.Version, backup.Version))
+		case restoreVersion.LessThan(backupVersion):
+			report.Warnings = append(report.Warnings, fmt.Sprintf("version %s is an older patch release than backup cluster version %s", restore.Version, backup.Version))
+		}
+	}
+	// clusters without ClusterVersion report no capabilities at all
+	if len(restore.Capabilities) > 0 {
+		for _, capability := range missing(backup.Capabilities, restore.Capabilities) {
+			report.Incompatibilities = append(report.Incompatibilities, fmt.Sprintf("capability %s is not enabled", capability))
+		}
+	}
+	for _, storageClass := range missing(backup.StorageClasses, restore.StorageClasses) {
+		report.Incompatibilities = append(report.Incompatibilities, fmt.Sprintf("storage class %s does not exist", storageClass))
+	}
+	for _, group := range missing(backup.APIGroups, restore.APIGroups) {
+		report.Incompatibilities = append(report.Incompatibilities, fmt.Sprintf("API group version %s is not served", group))
+	}
+	if backup.Platform != restore.Platform {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("platform %q, backup cluster %q", restore.Platform, backup.Platform))
+	}
+	if backup.NetworkType != restore.NetworkType {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("network type %q, backup cluster %q", restore.NetworkType, backup.NetworkType))
+	}
+	if backup.IngressDomain != restore.IngressDomain {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("ingress domain %q, backup cluster %q", restore.IngressDomain, backup.IngressDomain))
+	}
+	return report
+}
+
+// missing returns the values of want not found in have
+func missing(want, have []string) []string {
+	found := map[string]bool{}
+	for _, value := range have {
+		found[value] = true
+	}
+	var result []string
+	for _, value := range want {
+		if !found[value] {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestCompareClusterFingerprints(t *testing.T) {
+	backup := &ClusterFingerprint{
+		Version:        "4.14.5",
+		Capabilities:   []string{"Build", "ImageRegistry"},
+		Platform:       "AWS",
+		NetworkType:    "OVNKubernetes",
+		StorageClasses: []string{"gp3-csi"},
+		IngressDomain:  "apps.source.example.com",
+		APIGroups:      []string{"apps.openshift.io/v1", "apps/v1"},
+	}
+	tests := []struct {
+		name    string
+		restore ClusterFingerprint
+		want    *CompatibilityReport
+	}{
+		{
+			name:    "same cluster",
+			restore: *backup,
+			want:    &CompatibilityReport{},
+		},
+		{
+			name: "newer cluster on another platform",
+			restore: ClusterFingerprint{
+				Version:        "4.15.0",
+				Capabilities:   []string{"Build", "ImageRegistry", "Console"},
+				Platform:       "Azure",
+				NetworkType:    "OVNKubernetes",
+				StorageClasses: []string{"gp3-csi", "managed-csi"},
+				IngressDomain:  "apps.destination.example.com",
+				APIGroups:      []string{"apps.openshift.io/v1", "apps/v1"},
+			},
+			want: &CompatibilityReport{
+				Warnings: []string{
+					`platform "Azure", backup cluster "AWS"`,
+					`ingress domain "apps.destination.example.com", backup cluster "apps.source.example.com"`,
+				},
+			},
+		},
+		{
+			name: "older cluster missing capability, storage class and API group",
+			restore: ClusterFingerprint{
+				Version:        "4.13.20",
+				Capabilities:   []string{"ImageRegistry"},
+				Platform:       "AWS",
+				NetworkType:    "OVNKubernetes",
+				StorageClasses: []string{"gp2"},
+				IngressDomain:  "apps.source.example.com",
+				APIGroups:      []string{"apps/v1"},
+			},
+			want: &CompatibilityReport{
+				Incompatibilities: []string{
+					"version 4.13.20 is older than backup cluster version 4.14.5",
+					"capability Build is not enabled",
+					"storage class gp3-csi does not exist",
+					"API group version apps.openshift.io/v1 is not served",
+				},
+			},
+		},
+		{
+			name: "older patch release on a cluster without ClusterVersion capabilities",
+			restore: ClusterFingerprint{
+				Version:        "4.14.1",
+				Platform:       "AWS",
+				NetworkType:    "OVNKubernetes",
+				StorageClasses: []string{"gp3-csi"},
+				IngressDomain:  "apps.source.example.com",
+				APIGroups:      []string{"apps.openshift.io/v1", "apps/v1"},
+			},
+			want: &CompatibilityReport{
+				Warnings: []string{"version 4.14.1 is an older patch release than backup cluster version 4.14.5"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CompareClusterFingerprints(backup, &tt.restore); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CompareClusterFingerprints() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckClusterCompatibility(t *testing.T) {
+	backupFingerprint := `{"version":"4.14.5","capabilities":["Build"]}`
+	fingerprint := &ClusterFingerprint{Version: "4.13.20"}
+	tests := []struct {
+		name     string
+		recorded string
+		policy   string
+		wantErr  bool
+	}{
+		{name: "backup without fingerprint", policy: ClusterCompatibilityStrict},
+		{name: "incompatible with warn", recorded: backupFingerprint, policy: ClusterCompatibilityWarn},
+		{name: "incompatible with strict", recorded: backupFingerprint, policy: ClusterCompatibilityStrict, wantErr: true},
+		{name: "disabled", recorded: "not json", policy: ClusterCompatibilityDisabled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uid := types.UID("restore-" + tt.name)
+			backup := &velero.Backup{ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "openshift-adp"}}
+			if tt.recorded != "" {
+				backup.Annotations = map[string]string{BackupClusterFingerprint: tt.recorded}
+			}
+			BackupUidMap = map[types.UID]*CommonStruct{
+				uid: {Backup: backup, ClusterFingerprint: fingerprint},
+			}
+			restore := &velero.Restore{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:        "restore",
+					Namespace:   "openshift-adp",
+					UID:         uid,
+					Annotations: map[string]string{ClusterCompatibility: tt.policy},
+				},
+				Spec: velero.RestoreSpec{BackupName: "backup"},
+			}
+			// every item of the restore is checked against the report of the first one
+			for i := 0; i < 2; i++ {
+				if err := CheckClusterCompatibility(restore, test.NewLogger()); (err != nil) != tt.wantErr {
+					t.Errorf("CheckClusterCompatibility() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1API "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// serviceDNSName matches the namespace label of in-cluster DNS names such as <service>.<namespace>.svc
+// and <service>.<namespace>.svc.cluster.local
+var serviceDNSName = regexp.MustCompile(`\.([a-z0-9](?:[-a-z0-9]*[a-z0-9])?)\.svc\b`)
+
+// GetRestoreNamespace returns the namespace itemFromBackup is restored to. Velero applies the namespace
+// mapping of the restore after the restore item actions ran, so the item still has its backed up namespace.
+func GetRestoreNamespace(itemFromBackup runtime.Unstructured, restore *velero.Restore) (string, error) {
+	metadata, err := meta.Accessor(itemFromBackup)
+	if err != nil {
+		return "", err
+	}
+	namespace := metadata.GetNamespace()
+	if restore.Spec.NamespaceMapping[namespace] != "" {
+		return restore.Spec.NamespaceMapping[namespace], nil
+	}
+	return namespace, nil
+}
+
+// NamespaceRewriter rewrites references to namespaces mapped by a restore. A nil rewriter leaves
+// everything unchanged.
+type NamespaceRewriter struct {
+	mapping map[string]string
+}
+
+// NewNamespaceRewriter returns a rewriter applying mapping, or nil if mapping is empty
+func NewNamespaceRewriter(mapping map[string]string) *NamespaceRewriter {
+	if len(mapping) == 0 {
+		return nil
+	}
+	return &NamespaceRewriter{mapping: mapping}
+}
+
+// GetNamespaceRewriter returns the rewriter of the namespace mapping of a restore, or nil if the
+// restore maps no namespaces or RestoreRewriteNamespaceReferences is false
+func GetNamespaceRewriter(restore *velero.Restore) (*NamespaceRewriter, error) {
+	if len(restore.Spec.NamespaceMapping) == 0 {
+		return nil, nil
+	}
+	rewrite, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, RestoreRewriteNamespaceReferences)
+	if err != nil || rewrite == "false" {
+		return nil, err
+	}
+	return NewNamespaceRewriter(restore.Spec.NamespaceMapping), nil
+}
+
+// Namespace returns the namespace namespace is mapped to
+func (r *NamespaceRewriter) Namespace(namespace string) string {
+	if r == nil || r.mapping[namespace] == "" {
+		return namespace
+	}
+	return r.mapping[namespace]
+}
+
+// DNSNames returns s with the namespace of in-cluster DNS names mapped
+func (r *NamespaceRewriter) DNSNames(s string) string {
+	if r == nil {
+		return s
+	}
+	return serviceDNSName.ReplaceAllStringFunc(s, func(match string) string {
+		namespace := serviceDNSName.FindStringSubmatch(match)[1]
+		return "." + r.Namespace(namespace) + ".svc"
+	})
+}
+
+// Containers maps the namespace of in-cluster DNS names in the env var values of containers
+func (r *NamespaceRewriter) Containers(containers []corev1API.Container, log logrus.FieldLogger) {
+	if r == nil {
+		return
+	}
+	for n := range containers {
+		for i, env := range containers[n].Env {
+			if value := r.DNSNames(env.Value); value != env.Value {
+				log.Infof("[namespace-rewrite] rewriting namespace references in container %s env %s", containers[n].Name, env.Name)
+				containers[n].Env[i].Value = value
+			}
+		}
+	}
+}
+
+// StringMap maps the namespace of in-cluster DNS names in the values of data. It returns true if a value changed.
+func (r *NamespaceRewriter) StringMap(data map[string]string, log logrus.FieldLogger) bool {
+	if r == nil {
+		return false
+	}
+	changed := false
+	for key, value := range data {
+		if newValue := r.DNSNames(value); newValue != value {
+			log.Infof("[namespace-rewrite] rewriting namespace references in key %s", key)
+			data[key] = newValue
+			changed = true
+		}
+	}
+	return changed
+}
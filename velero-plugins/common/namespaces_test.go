@@ -0,0 +1,72 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	corev1API "k8s.io/api/core/v1"
+)
+
+func TestNamespaceRewriterDNSNames(t *testing.T) {
+	rewriter := NewNamespaceRewriter(map[string]string{"ns1": "ns2", "db": "db-restored"})
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{
+			name:  "service DNS name",
+			value: "postgresql.db.svc",
+			want:  "postgresql.db-restored.svc",
+		},
+		{
+			name:  "fully qualified service DNS name in URL",
+			value: "postgres://user@postgresql.db.svc.cluster.local:5432/app",
+			want:  "postgres://user@postgresql.db-restored.svc.cluster.local:5432/app",
+		},
+		{
+			name:  "pod DNS name and several references",
+			value: "web-0.web.ns1.svc.cluster.local,cache.ns1.svc:6379",
+			want:  "web-0.web.ns2.svc.cluster.local,cache.ns2.svc:6379",
+		},
+		{
+			name:  "unmapped namespace",
+			value: "api.other.svc.cluster.local",
+			want:  "api.other.svc.cluster.local",
+		},
+		{
+			name:  "namespace name outside DNS names",
+			value: "ns1/app and db.svc-account",
+			want:  "ns1/app and db.svc-account",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rewriter.DNSNames(tt.value); got != tt.want {
+				t.Errorf("DNSNames() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNamespaceRewriterContainers(t *testing.T) {
+	containers := []corev1API.Container{{
+		Name: "app",
+		Env: []corev1API.EnvVar{
+			{Name: "DB_HOST", Value: "postgresql.ns1.svc"},
+			{Name: "MODE", Value: "production"},
+		},
+	}}
+	var disabled *NamespaceRewriter
+	disabled.Containers(containers, test.NewLogger())
+	if got := containers[0].Env[0].Value; got != "postgresql.ns1.svc" {
+		t.Errorf("nil rewriter changed env var to %v", got)
+	}
+	NewNamespaceRewriter(map[string]string{"ns1": "ns2"}).Containers(containers, test.NewLogger())
+	if got := containers[0].Env[0].Value; got != "postgresql.ns2.svc" {
+		t.Errorf("Containers() env var = %v, want postgresql.ns2.svc", got)
+	}
+	if got := containers[0].Env[1].Value; got != "production" {
+		t.Errorf("Containers() env var = %v, want production", got)
+	}
+}
@@ -0,0 +1,265 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/util/boolptr"
+	"github.com/vmware-tanzu/velero/pkg/util/collections"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+// values of RestoreOperatorOwned
+const (
+	OperatorOwnedRestore = "restore"
+	OperatorOwnedSkip    = "skip"
+)
+
+var crdResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// BackedUpOwner is the controller owner of an item that is a custom resource included in the backup
+type BackedUpOwner struct {
+	// Name is <resource>.<group>/<name>
+	Name   string
+	Labels map[string]string
+}
+
+// GetBackedUpControllerOwner returns the controller owner of item if it is a custom resource included in backup,
+// or nil otherwise. The result is cached per backup UID and owner UID.
+func GetBackedUpControllerOwner(item runtime.Unstructured, backup *velero.Backup, log logrus.FieldLogger) (*BackedUpOwner, error) {
+	obj := &unstructured.Unstructured{Object: item.UnstructuredContent()}
+	owner := metav1.GetControllerOf(obj)
+	if owner == nil {
+		return nil, nil
+	}
+	if BackupUidMap == nil {
+		BackupUidMap = make(map[types.UID]*CommonStruct)
+	}
+	if BackupUidMap[backup.UID] == nil {
+		BackupUidMap[backup.UID] = &CommonStruct{}
+	}
+	BackupUidMap[backup.UID].JustAccessed()
+	if BackupUidMap[backup.UID].ControllerOwners == nil {
+		BackupUidMap[backup.UID].ControllerOwners = map[types.UID]*BackedUpOwner{}
+	}
+	if result, ok := BackupUidMap[backup.UID].ControllerOwners[owner.UID]; ok {
+		return result, nil
+	}
+	result, err := readBackedUpControllerOwner(obj.GetNamespace(), owner, backup, log)
+	if err != nil {
+		return nil, err
+	}
+	BackupUidMap[backup.UID].ControllerOwners[owner.UID] = result
+	return result, nil
+}
+
+// readBackedUpControllerOwner looks up the owner of an item in namespace and checks whether it is a custom
+// resource selected by the resource filters and label selectors of backup
+func readBackedUpControllerOwner(namespace string, owner *metav1.OwnerReference, backup *velero.Backup, log logrus.FieldLogger) (*BackedUpOwner, error) {
+	gv, err := schema.ParseGroupVersion(owner.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	if gv.Group == "" {
+		// core API
+		return nil, nil
+	}
+	mapper, err := getBackupRESTMapper(backup.UID)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := mapper.RESTMapping(schema.GroupKind{Group: gv.Group, Kind: owner.Kind}, gv.Version)
+	if meta.IsNoMatchError(err) {
+		log.Infof("[owner-backup] owner %s %s is not served, skipping", owner.APIVersion, owner.Kind)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	groupResource := mapping.Resource.GroupResource()
+	dynamicClient, err := clients.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	_, err = dynamicClient.Resource(crdResource).Get(context.Background(), groupResource.String(), metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		// built-in API
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	clusterScoped := mapping.Scope.Name() == meta.RESTScopeNameRoot
+	if !backupIncludesResource(backup.Spec, groupResource, clusterScoped) {
+		log.Infof("[owner-backup] %s not included in backup", groupResource)
+		return nil, nil
+	}
+	resource := dynamicClient.Resource(mapping.Resource)
+	var cr *unstructured.Unstructured
+	if clusterScoped {
+		cr, err = resource.Get(context.Background(), owner.Name, metav1.GetOptions{})
+	} else {
+		cr, err = resource.Namespace(namespace).Get(context.Background(), owner.Name, metav1.GetOptions{})
+	}
+	if k8serrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	selected, err := selectsLabels(backup.Spec.LabelSelector, backup.Spec.OrLabelSelectors, cr.GetLabels())
+	if err != nil || !selected {
+		return nil, err
+	}
+	return &BackedUpOwner{Name: groupResource.String() + "/" + owner.Name, Labels: cr.GetLabels()}, nil
+}
+
+// getBackupRESTMapper returns the discovery based REST mapper of a backup, created once per backup UID
+func getBackupRESTMapper(uid types.UID) (meta.RESTMapper, error) {
+	if BackupUidMap[uid].RESTMapper != nil {
+		return BackupUidMap[uid].RESTMapper, nil
+	}
+	discoveryClient, err := clients.DiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	BackupUidMap[uid].RESTMapper = restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	return BackupUidMap[uid].RESTMapper, nil
+}
+
+// backupIncludesResource returns true if the resource filters of a backup include a resource, the way velero
+// applies either the old filters or the namespace and cluster scoped ones. Unless cluster scoped resources are
+// included explicitly, they are only backed up by backups of all namespaces and namespace scoped resources.
+func backupIncludesResource(spec velero.BackupSpec, groupResource schema.GroupResource, clusterScoped bool) bool {
+	allNamespaces := includesEverything(spec.IncludedNamespaces, spec.ExcludedNamespaces)
+	if collections.UseOldResourceFilters(spec) {
+		if clusterScoped && (boolptr.IsSetToFalse(spec.IncludeClusterResources) ||
+			spec.IncludeClusterResources == nil && !allNamespaces) {
+			return false
+		}
+		return includesResource(spec.IncludedResources, spec.ExcludedResources, groupResource)
+	}
+	if !clusterScoped {
+		return includesResource(spec.IncludedNamespaceScopedResources, spec.ExcludedNamespaceScopedResources, groupResource)
+	}
+	if len(spec.IncludedClusterScopedResources) == 0 && len(spec.ExcludedClusterScopedResources) == 0 {
+		return allNamespaces && includesEverything(spec.IncludedNamespaceScopedResources, spec.ExcludedNamespaceScopedResources)
+	}
+	// cluster scoped resources are not included by an empty list
+	return len(spec.IncludedClusterScopedResources) > 0 &&
+		includesResource(spec.IncludedClusterScopedResources, spec.ExcludedClusterScopedResources, groupResource)
+}
+
+// includesEverything returns true if velero style filters include everything
+func includesEverything(includes, excludes []string) bool {
+	return len(excludes) == 0 && (len(includes) == 0 || len(includes) == 1 && includes[0] == "*")
+}
+
+// includesResource returns true if a resource is included by velero style resource filters, where resources
+// are listed as <resource>.<group> or <resource>
+func includesResource(includes, excludes []string, groupResource schema.GroupResource) bool {
+	listed := func(resources []string) bool {
+		for _, resource := range resources {
+			if resource == "*" || resource == groupResource.String() || resource == groupResource.Resource {
+				return true
+			}
+		}
+		return false
+	}
+	if listed(excludes) {
+		return false
+	}
+	return len(includes) == 0 || listed(includes)
+}
+
+// selectsLabels returns true if the label selectors of a backup or restore select an object with objLabels
+func selectsLabels(labelSelector *metav1.LabelSelector, orLabelSelectors []*metav1.LabelSelector, objLabels map[string]string) (bool, error) {
+	selectors := append([]*metav1.LabelSelector{}, orLabelSelectors...)
+	if labelSelector != nil {
+		selectors = append(selectors, labelSelector)
+	}
+	if len(selectors) == 0 {
+		return true, nil
+	}
+	for _, labelSelector := range selectors {
+		selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+		if err != nil {
+			return false, err
+		}
+		if selector.Matches(labels.Set(objLabels)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetOperatorOwnedPolicy returns the RestoreOperatorOwned option of a restore
+func GetOperatorOwnedPolicy(restore *velero.Restore) (string, error) {
+	policy, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, RestoreOperatorOwned)
+	if err != nil {
+		return "", err
+	}
+	switch policy {
+	case "":
+		return OperatorOwnedRestore, nil
+	case OperatorOwnedRestore, OperatorOwnedSkip:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("invalid %s %q, must be %s or %s", RestoreOperatorOwned, policy, OperatorOwnedRestore, OperatorOwnedSkip)
+	}
+}
+
+// SkipOperatorOwned returns true if item is owned by a custom resource that was backed up with it, the restore
+// includes that resource and selects its labels, and the RestoreOperatorOwned policy is skip
+func SkipOperatorOwned(item runtime.Unstructured, restore *velero.Restore, log logrus.FieldLogger) (bool, error) {
+	_, annotations, err := getMetadataAndAnnotations(item)
+	if err != nil {
+		return false, err
+	}
+	owner := annotations[BackupControllerOwner]
+	if owner == "" {
+		return false, nil
+	}
+	policy, err := GetOperatorOwnedPolicy(restore)
+	if err != nil || policy != OperatorOwnedSkip {
+		return false, err
+	}
+	resource, _, _ := strings.Cut(owner, "/")
+	group := ""
+	if i := strings.Index(resource, "."); i >= 0 {
+		resource, group = resource[:i], resource[i+1:]
+	}
+	if !includesResource(restore.Spec.IncludedResources, restore.Spec.ExcludedResources, schema.GroupResource{Group: group, Resource: resource}) {
+		log.Infof("[owner-restore] owner %s not included in restore", owner)
+		return false, nil
+	}
+	var ownerLabels map[string]string
+	if val := annotations[BackupControllerOwnerLabels]; val != "" {
+		if err := json.Unmarshal([]byte(val), &ownerLabels); err != nil {
+			return false, fmt.Errorf("invalid %s: %v", BackupControllerOwnerLabels, err)
+		}
+	}
+	selected, err := selectsLabels(restore.Spec.LabelSelector, restore.Spec.OrLabelSelectors, ownerLabels)
+	if err != nil {
+		return false, err
+	}
+	if !selected {
+		log.Infof("[owner-restore] owner %s not selected by restore label selectors", owner)
+		return false, nil
+	}
+	return true, nil
+}
@@ -0,0 +1,215 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/util/boolptr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestIncludesResource(t *testing.T) {
+	kafkas := schema.GroupResource{Group: "kafka.strimzi.io", Resource: "kafkas"}
+	tests := []struct {
+		name     string
+		includes []string
+		excludes []string
+		want     bool
+	}{
+		{name: "no filters", want: true},
+		{name: "wildcard", includes: []string{"*"}, want: true},
+		{name: "included with group", includes: []string{"pods", "kafkas.kafka.strimzi.io"}, want: true},
+		{name: "included without group", includes: []string{"kafkas"}, want: true},
+		{name: "not included", includes: []string{"pods"}, want: false},
+		{name: "excluded", excludes: []string{"kafkas.kafka.strimzi.io"}, want: false},
+		{name: "excluded wins", includes: []string{"*"}, excludes: []string{"kafkas"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := includesResource(tt.includes, tt.excludes, kafkas); got != tt.want {
+				t.Errorf("includesResource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackupIncludesResource(t *testing.T) {
+	kafkas := schema.GroupResource{Group: "kafka.strimzi.io", Resource: "kafkas"}
+	tests := []struct {
+		name          string
+		spec          velero.BackupSpec
+		clusterScoped bool
+		want          bool
+	}{
+		{name: "namespaced, no filters", want: true},
+		{name: "namespaced, excluded", spec: velero.BackupSpec{ExcludedNamespaceScopedResources: []string{"kafkas"}}, want: false},
+		{name: "namespaced, old filters", spec: velero.BackupSpec{IncludedResources: []string{"kafkas"}}, want: true},
+		{name: "cluster scoped, all namespaces", clusterScoped: true, want: true},
+		{
+			name:          "cluster scoped, namespace filtered",
+			spec:          velero.BackupSpec{IncludedNamespaces: []string{"kafka"}},
+			clusterScoped: true,
+			want:          false,
+		},
+		{
+			name:          "cluster scoped, cluster resources included",
+			spec:          velero.BackupSpec{IncludedNamespaces: []string{"kafka"}, IncludeClusterResources: boolptr.True()},
+			clusterScoped: true,
+			want:          true,
+		},
+		{
+			name:          "cluster scoped, cluster resources excluded",
+			spec:          velero.BackupSpec{IncludeClusterResources: boolptr.False()},
+			clusterScoped: true,
+			want:          false,
+		},
+		{
+			name: "cluster scoped, included cluster scoped resources",
+			spec: velero.BackupSpec{
+				IncludedNamespaces:             []string{"kafka"},
+				IncludedClusterScopedResources: []string{"kafkas.kafka.strimzi.io"},
+			},
+			clusterScoped: true,
+			want:          true,
+		},
+		{
+			name:          "cluster scoped, excluded cluster scoped resources",
+			spec:          velero.BackupSpec{ExcludedClusterScopedResources: []string{"kafkas"}},
+			clusterScoped: true,
+			want:          false,
+		},
+		{
+			name:          "cluster scoped, only namespace scoped resources filtered",
+			spec:          velero.BackupSpec{IncludedNamespaceScopedResources: []string{"pods"}},
+			clusterScoped: true,
+			want:          false,
+		},
+		{
+			name:          "cluster scoped, namespace scoped filters do not apply",
+			spec:          velero.BackupSpec{IncludedNamespaceScopedResources: []string{"pods"}, IncludedClusterScopedResources: []string{"*"}},
+			clusterScoped: true,
+			want:          true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := backupIncludesResource(tt.spec, kafkas, tt.clusterScoped); got != tt.want {
+				t.Errorf("backupIncludesResource() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectsLabels(t *testing.T) {
+	tests := []struct {
+		name   string
+		spec   velero.BackupSpec
+		labels map[string]string
+		want   bool
+	}{
+		{name: "no selector", want: true},
+		{
+			name:   "label selector",
+			spec:   velero.BackupSpec{LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "kafka"}}},
+			labels: map[string]string{"app": "kafka"},
+			want:   true,
+		},
+		{
+			name: "or label selectors",
+			spec: velero.BackupSpec{OrLabelSelectors: []*metav1.LabelSelector{
+				{MatchLabels: map[string]string{"app": "db"}},
+				{MatchLabels: map[string]string{"app": "kafka"}},
+			}},
+			labels: map[string]string{"app": "kafka"},
+			want:   true,
+		},
+		{
+			name:   "not selected",
+			spec:   velero.BackupSpec{LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+			labels: map[string]string{"app": "kafka"},
+			want:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectsLabels(tt.spec.LabelSelector, tt.spec.OrLabelSelectors, tt.labels)
+			if err != nil {
+				t.Fatalf("selectsLabels() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("selectsLabels() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSkipOperatorOwned(t *testing.T) {
+	kafkaSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "kafka"}}
+	tests := []struct {
+		name        string
+		owner       string
+		ownerLabels string
+		policy      string
+		spec        velero.RestoreSpec
+		want        bool
+		wantErr     bool
+	}{
+		{name: "not owned", policy: OperatorOwnedSkip, want: false},
+		{name: "restore policy", owner: "kafkas.kafka.strimzi.io/cluster", policy: OperatorOwnedRestore, want: false},
+		{name: "skip policy", owner: "kafkas.kafka.strimzi.io/cluster", policy: OperatorOwnedSkip, want: true},
+		{
+			name:   "owner not restored",
+			owner:  "kafkas.kafka.strimzi.io/cluster",
+			policy: OperatorOwnedSkip,
+			spec:   velero.RestoreSpec{ExcludedResources: []string{"kafkas.kafka.strimzi.io"}},
+			want:   false,
+		},
+		{
+			name:        "owner selected by restore",
+			owner:       "kafkas.kafka.strimzi.io/cluster",
+			ownerLabels: `{"app":"kafka"}`,
+			policy:      OperatorOwnedSkip,
+			spec:        velero.RestoreSpec{LabelSelector: kafkaSelector},
+			want:        true,
+		},
+		{
+			name:        "owner not selected by restore",
+			owner:       "kafkas.kafka.strimzi.io/cluster",
+			ownerLabels: `{"app":"db"}`,
+			policy:      OperatorOwnedSkip,
+			spec:        velero.RestoreSpec{OrLabelSelectors: []*metav1.LabelSelector{kafkaSelector}},
+			want:        false,
+		},
+		{
+			name:   "owner labels not recorded",
+			owner:  "kafkas.kafka.strimzi.io/cluster",
+			policy: OperatorOwnedSkip,
+			spec:   velero.RestoreSpec{LabelSelector: kafkaSelector},
+			want:   false,
+		},
+		{name: "invalid policy", owner: "kafkas.kafka.strimzi.io/cluster", policy: "delete", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := &unstructured.Unstructured{Object: map[string]interface{}{}}
+			item.SetName("cluster-kafka-0")
+			if tt.owner != "" {
+				item.SetAnnotations(map[string]string{BackupControllerOwner: tt.owner, BackupControllerOwnerLabels: tt.ownerLabels})
+			}
+			restore := &velero.Restore{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{RestoreOperatorOwned: tt.policy}},
+				Spec:       tt.spec,
+			}
+			got, err := SkipOperatorOwned(item, restore, test.NewLogger())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SkipOperatorOwned() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("SkipOperatorOwned() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1API "k8s.io/api/core/v1"
+)
+
+// values of the placement options
+const (
+	PlacementKeep  = "keep"
+	PlacementStrip = "strip"
+	PlacementRemap = "remap"
+)
+
+// PlacementPolicy describes how the node placement of restored pods and pod templates is transformed
+type PlacementPolicy struct {
+	// keep, strip or remap node selectors
+	NodeSelector string
+	// keep, strip or remap node affinity terms
+	NodeAffinity string
+	// keep, strip or remap tolerations
+	Tolerations string
+	// node label keys or key=value pairs of the backup cluster mapped to those of the restore cluster
+	Labels map[string]string
+}
+
+// GetPlacementPolicy returns the placement policy of a restore. defaultNodeSelector is used if
+// RestoreNodeSelector is not set, node affinity and tolerations are kept by default.
+func GetPlacementPolicy(restore *velero.Restore, defaultNodeSelector string) (*PlacementPolicy, error) {
+	policy := &PlacementPolicy{}
+	for _, option := range []struct {
+		key          string
+		value        *string
+		defaultValue string
+	}{
+		{RestoreNodeSelector, &policy.NodeSelector, defaultNodeSelector},
+		{RestoreNodeAffinity, &policy.NodeAffinity, PlacementKeep},
+		{RestoreTolerations, &policy.Tolerations, PlacementKeep},
+	} {
+		value, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, option.key)
+		if err != nil {
+			return nil, err
+		}
+		switch value {
+		case "":
+			*option.value = option.defaultValue
+		case PlacementKeep, PlacementStrip, PlacementRemap:
+			*option.value = value
+		default:
+			return nil, fmt.Errorf("invalid %s %q, must be %s, %s or %s", option.key, value, PlacementKeep, PlacementStrip, PlacementRemap)
+		}
+	}
+	mapping, err := GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, RestoreNodeLabelMapping)
+	if err != nil {
+		return nil, err
+	}
+	policy.Labels, err = ParseNodeLabelMapping(mapping)
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// ParseNodeLabelMapping parses a comma separated list of old:new node label mappings, where old and new are
+// either label keys or key=value pairs
+func ParseNodeLabelMapping(value string) (map[string]string, error) {
+	mapping := map[string]string{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		oldNew := strings.Split(entry, ":")
+		if len(oldNew) != 2 || oldNew[0] == "" || oldNew[1] == "" {
+			return nil, fmt.Errorf("invalid %s entry %q, must be old:new", RestoreNodeLabelMapping, entry)
+		}
+		mapping[strings.TrimSpace(oldNew[0])] = strings.TrimSpace(oldNew[1])
+	}
+	return mapping, nil
+}
+
+// remapLabel returns the key and value a node label is mapped to. A key=value mapping takes precedence
+// over a mapping of the key alone, which keeps the value unless the mapping sets one.
+func (p *PlacementPolicy) remapLabel(key, value string) (string, string) {
+	newLabel, ok := p.Labels[key+"="+value]
+	if !ok {
+		newLabel, ok = p.Labels[key]
+	}
+	if !ok {
+		return key, value
+	}
+	if newKey, newValue, found := strings.Cut(newLabel, "="); found {
+		return newKey, newValue
+	}
+	return newLabel, value
+}
+
+// Apply transforms the node placement of a pod spec
+func (p *PlacementPolicy) Apply(spec *corev1API.PodSpec, log logrus.FieldLogger) {
+	switch p.NodeSelector {
+	case PlacementStrip:
+		if spec.NodeSelector != nil {
+			log.Info("[placement] removing node selector")
+			spec.NodeSelector = nil
+		}
+	case PlacementRemap:
+		if spec.NodeSelector == nil {
+			break
+		}
+		nodeSelector := map[string]string{}
+		for key, value := range spec.NodeSelector {
+			newKey, newValue := p.remapLabel(key, value)
+			if newKey != key || newValue != value {
+				log.Infof("[placement] node selector %s=%s -> %s=%s", key, value, newKey, newValue)
+			}
+			nodeSelector[newKey] = newValue
+		}
+		spec.NodeSelector = nodeSelector
+	}
+
+	if spec.Affinity != nil && spec.Affinity.NodeAffinity != nil {
+		switch p.NodeAffinity {
+		case PlacementStrip:
+			log.Info("[placement] removing node affinity")
+			spec.Affinity.NodeAffinity = nil
+		case PlacementRemap:
+			nodeAffinity := spec.Affinity.NodeAffinity
+			if required := nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution; required != nil {
+				for n := range required.NodeSelectorTerms {
+					p.remapNodeSelectorTerm(&required.NodeSelectorTerms[n], log)
+				}
+			}
+			for n := range nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+				p.remapNodeSelectorTerm(&nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[n].Preference, log)
+			}
+		}
+	}
+
+	switch p.Tolerations {
+	case PlacementStrip:
+		if spec.Tolerations != nil {
+			log.Info("[placement] removing tolerations")
+			spec.Tolerations = nil
+		}
+	case PlacementRemap:
+		for n, toleration := range spec.Tolerations {
+			if toleration.Key == "" {
+				// tolerates all taints
+				continue
+			}
+			newKey, newValue := p.remapLabel(toleration.Key, toleration.Value)
+			if newKey != toleration.Key || newValue != toleration.Value {
+				log.Infof("[placement] toleration %s=%s -> %s=%s", toleration.Key, toleration.Value, newKey, newValue)
+				spec.Tolerations[n].Key = newKey
+				spec.Tolerations[n].Value = newValue
+			}
+		}
+	}
+}
+
+// remapNodeSelectorTerm maps the keys and values of the label expressions of a node selector term
+func (p *PlacementPolicy) remapNodeSelectorTerm(term *corev1API.NodeSelectorTerm, log logrus.FieldLogger) {
+	for n, expression := range term.MatchExpressions {
+		newKey := expression.Key
+		if newLabel, ok := p.Labels[expression.Key]; ok {
+			newKey, _, _ = strings.Cut(newLabel, "=")
+		}
+		for i, value := range expression.Values {
+			// only key=value mappings of the expression key apply to its values
+			if newLabel, ok := p.Labels[expression.Key+"="+value]; ok {
+				if labelKey, newValue, found := strings.Cut(newLabel, "="); found {
+					newKey = labelKey
+					term.MatchExpressions[n].Values[i] = newValue
+				}
+			}
+		}
+		if newKey != expression.Key {
+			log.Infof("[placement] node affinity key %s -> %s", expression.Key, newKey)
+			term.MatchExpressions[n].Key = newKey
+		}
+	}
+}
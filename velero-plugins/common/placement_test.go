@@ -0,0 +1,122 @@
+package common
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	corev1API "k8s.io/api/core/v1"
+)
+
+func TestParseNodeLabelMapping(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{
+			name:  "empty",
+			value: "",
+			want:  map[string]string{},
+		},
+		{
+			name:  "keys and key=value pairs",
+			value: "zone:topology.kubernetes.io/zone, node-role=infra:node-role.kubernetes.io/infra=",
+			want: map[string]string{
+				"zone":            "topology.kubernetes.io/zone",
+				"node-role=infra": "node-role.kubernetes.io/infra=",
+			},
+		},
+		{
+			name:    "missing new label",
+			value:   "zone",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseNodeLabelMapping(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseNodeLabelMapping() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseNodeLabelMapping() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func testPlacementSpec() *corev1API.PodSpec {
+	return &corev1API.PodSpec{
+		NodeSelector: map[string]string{"zone": "east", "disk": "ssd", "tier": "gold"},
+		Affinity: &corev1API.Affinity{
+			NodeAffinity: &corev1API.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: &corev1API.NodeSelector{
+					NodeSelectorTerms: []corev1API.NodeSelectorTerm{{
+						MatchExpressions: []corev1API.NodeSelectorRequirement{
+							{Key: "zone", Operator: corev1API.NodeSelectorOpIn, Values: []string{"east", "west"}},
+							{Key: "disk", Operator: corev1API.NodeSelectorOpIn, Values: []string{"ssd"}},
+						},
+					}},
+				},
+			},
+		},
+		Tolerations: []corev1API.Toleration{
+			{Key: "dedicated", Operator: corev1API.TolerationOpEqual, Value: "gpu", Effect: corev1API.TaintEffectNoSchedule},
+			{Operator: corev1API.TolerationOpExists},
+		},
+	}
+}
+
+func TestPlacementPolicy_Apply(t *testing.T) {
+	labels := map[string]string{
+		"zone":          "topology.kubernetes.io/zone",
+		"disk=ssd":      "storage=fast",
+		"dedicated=gpu": "nvidia.com/gpu=true",
+	}
+	tests := []struct {
+		name   string
+		policy PlacementPolicy
+		want   func(spec *corev1API.PodSpec)
+	}{
+		{
+			name:   "keep",
+			policy: PlacementPolicy{NodeSelector: PlacementKeep, NodeAffinity: PlacementKeep, Tolerations: PlacementKeep, Labels: labels},
+			want:   func(spec *corev1API.PodSpec) {},
+		},
+		{
+			name:   "strip",
+			policy: PlacementPolicy{NodeSelector: PlacementStrip, NodeAffinity: PlacementStrip, Tolerations: PlacementStrip},
+			want: func(spec *corev1API.PodSpec) {
+				spec.NodeSelector = nil
+				spec.Affinity.NodeAffinity = nil
+				spec.Tolerations = nil
+			},
+		},
+		{
+			name:   "remap",
+			policy: PlacementPolicy{NodeSelector: PlacementRemap, NodeAffinity: PlacementRemap, Tolerations: PlacementRemap, Labels: labels},
+			want: func(spec *corev1API.PodSpec) {
+				spec.NodeSelector = map[string]string{"topology.kubernetes.io/zone": "east", "storage": "fast", "tier": "gold"}
+				expressions := spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+				expressions[0].Key = "topology.kubernetes.io/zone"
+				expressions[1].Key = "storage"
+				expressions[1].Values = []string{"fast"}
+				spec.Tolerations[0].Key = "nvidia.com/gpu"
+				spec.Tolerations[0].Value = "true"
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := testPlacementSpec()
+			want := testPlacementSpec()
+			tt.want(want)
+			tt.policy.Apply(spec, test.NewLogger())
+			if !reflect.DeepEqual(spec, want) {
+				t.Errorf("Apply() = %+v, want %+v", spec, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,286 @@
+package common
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/openshift"
+	"github.com/openshift/library-go/pkg/image/reference"
+	"github.com/sirupsen/logrus"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// discovered registry hostnames are looked up again after this long
+const registryHostnamesTTL = 10 * time.Minute
+
+// namespace and service of the image registry managed by the image registry operator
+const (
+	imageRegistryNamespace = "openshift-image-registry"
+	imageRegistryService   = "image-registry"
+)
+
+// RegistryHostnames are the hostnames of the internal registry of a cluster
+type RegistryHostnames struct {
+	// Hostname images are pushed to and referenced with
+	Hostname string
+	// Aliases are other hostnames references to images in the registry may be written with, e.g. its routes
+	Aliases []string
+}
+
+// All returns the hostname followed by the aliases
+func (r RegistryHostnames) All() []string {
+	if r.Hostname == "" {
+		return r.Aliases
+	}
+	return append([]string{r.Hostname}, r.Aliases...)
+}
+
+// RegistryDiscovery is a source of internal registry hostnames. Discover returns an empty Hostname
+// if the source does not know the hostname images are pushed to.
+type RegistryDiscovery struct {
+	Name     string
+	Discover func(namespace string, log logrus.FieldLogger) (RegistryHostnames, error)
+}
+
+// RegistryDiscoveryChain is the list of sources of the internal registry hostnames. The first source
+// returning a hostname determines it, the hostnames found by all sources are aliases.
+var RegistryDiscoveryChain = []RegistryDiscovery{
+	{Name: "override", Discover: discoverRegistryOverride},
+	{Name: "image-config", Discover: discoverImageConfigRegistry},
+	{Name: "image-registry-operator", Discover: discoverImageRegistryOperatorRoutes},
+	{Name: "route", Discover: discoverImageRegistryRoutes},
+	{Name: "legacy", Discover: discoverLegacyRegistry},
+}
+
+type registryHostnamesEntry struct {
+	hostnames RegistryHostnames
+	expires   time.Time
+}
+
+var (
+	registryHostnamesCache = map[string]registryHostnamesEntry{}
+	registryHostnamesLock  sync.Mutex
+)
+
+// GetRegistryInfo returns the hostname of the internal registry, or "" if there is none. Overrides are read from
+// the plugin ConfigMap in the Velero namespace.
+func GetRegistryInfo(namespace string, log logrus.FieldLogger) (string, error) {
+	hostnames, err := GetRegistryHostnames(namespace, log)
+	if err != nil {
+		return "", err
+	}
+	return hostnames.Hostname, nil
+}
+
+// GetRegistryHostnames returns the hostname and aliases of the internal registry. Overrides are read from the
+// plugin ConfigMap in namespace, if set. The result is cached for registryHostnamesTTL.
+func GetRegistryHostnames(namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	registryHostnamesLock.Lock()
+	defer registryHostnamesLock.Unlock()
+	if entry, ok := registryHostnamesCache[namespace]; ok && time.Now().Before(entry.expires) {
+		return entry.hostnames, nil //use cache
+	}
+	hostnames, err := discoverRegistryHostnames(RegistryDiscoveryChain, namespace, log)
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	registryHostnamesCache[namespace] = registryHostnamesEntry{hostnames: hostnames, expires: time.Now().Add(registryHostnamesTTL)}
+	return hostnames, nil
+}
+
+// discoverRegistryHostnames runs the sources of chain and merges their hostnames. A failing source is
+// skipped, its error is only returned if no source found the registry hostname.
+func discoverRegistryHostnames(chain []RegistryDiscovery, namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	result := RegistryHostnames{}
+	seen := map[string]bool{}
+	var firstErr error
+	for _, source := range chain {
+		hostnames, err := source.Discover(namespace, log)
+		if err != nil {
+			log.Infof("[GetRegistryInfo] %s: %v", source.Name, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("errors discovering registry hostname from %s: %v", source.Name, err)
+			}
+			continue
+		}
+		if result.Hostname == "" && hostnames.Hostname != "" {
+			log.Infof("[GetRegistryInfo] value from %s", source.Name)
+			result.Hostname = hostnames.Hostname
+			seen[hostnames.Hostname] = true
+		}
+		for _, hostname := range hostnames.All() {
+			if hostname != "" && !seen[hostname] {
+				seen[hostname] = true
+				result.Aliases = append(result.Aliases, hostname)
+			}
+		}
+	}
+	if result.Hostname == "" && firstErr != nil {
+		return RegistryHostnames{}, firstErr
+	}
+	// an alias found before the hostname
+	aliases := result.Aliases[:0]
+	for _, alias := range result.Aliases {
+		if alias != result.Hostname {
+			aliases = append(aliases, alias)
+		}
+	}
+	result.Aliases = aliases
+	return result, nil
+}
+
+// discoverRegistryOverride returns the hostnames set in the plugin ConfigMap
+func discoverRegistryOverride(namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	if namespace == "" {
+		return RegistryHostnames{}, nil
+	}
+	config, err := ReadPluginConfig(namespace)
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	return RegistryHostnames{
+		Hostname: strings.TrimSpace(PluginConfigValue(config, RegistryHostnameOverride)),
+		Aliases:  SplitRegistryAliases(PluginConfigValue(config, RegistryAliasesOverride)),
+	}, nil
+}
+
+// discoverImageConfigRegistry returns the hostnames in the status of the cluster Image config
+func discoverImageConfigRegistry(namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	client, err := clients.OCPConfigClient()
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	config, err := client.Images().Get(context.Background(), "cluster", metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		return RegistryHostnames{}, nil
+	}
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	return RegistryHostnames{
+		Hostname: config.Status.InternalRegistryHostname,
+		Aliases:  append(config.Status.ExternalRegistryHostnames, config.Spec.ExternalRegistryHostnames...),
+	}, nil
+}
+
+// discoverImageRegistryOperatorRoutes returns the route hostnames set in the image registry operator config
+func discoverImageRegistryOperatorRoutes(namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	config, err := openshift.GetImageRegistryConfig()
+	if k8serrors.IsNotFound(err) {
+		return RegistryHostnames{}, nil
+	}
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	hostnames := RegistryHostnames{}
+	for _, route := range config.Spec.Routes {
+		hostnames.Aliases = append(hostnames.Aliases, route.Hostname)
+	}
+	return hostnames, nil
+}
+
+// discoverImageRegistryRoutes returns the hostnames of the routes exposing the image registry, e.g. the default route
+func discoverImageRegistryRoutes(namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	client, err := clients.RouteClient()
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	routes, err := client.Routes(imageRegistryNamespace).List(context.Background(), metav1.ListOptions{})
+	if k8serrors.IsNotFound(err) {
+		return RegistryHostnames{}, nil
+	}
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	hostnames := RegistryHostnames{}
+	for _, route := range routes.Items {
+		if route.Spec.To.Name == imageRegistryService {
+			hostnames.Aliases = append(hostnames.Aliases, route.Spec.Host)
+		}
+	}
+	return hostnames, nil
+}
+
+// discoverLegacyRegistry returns the hostname found in the openshift namespace ImageStreams, the docker-registry
+// service of 3.7-3.11 clusters or the openshift-apiserver config
+func discoverLegacyRegistry(namespace string, log logrus.FieldLogger) (RegistryHostnames, error) {
+	imageClient, err := clients.ImageClient()
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	imageStreams, err := imageClient.ImageStreams("openshift").List(context.Background(), metav1.ListOptions{})
+	if err == nil && len(imageStreams.Items) > 0 {
+		if value := imageStreams.Items[0].Status.DockerImageRepository; len(value) > 0 {
+			ref, err := reference.Parse(value)
+			if err == nil {
+				log.Info("[GetRegistryInfo] value from imagestream")
+				return RegistryHostnames{Hostname: ref.Registry}, nil
+			}
+		}
+	}
+
+	major, minor, err := GetServerVersion()
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+
+	if major != 1 {
+		return RegistryHostnames{}, fmt.Errorf("server version %v.%v not supported. Must be 1.x", major, minor)
+	}
+
+	cClient, err := clients.CoreClient()
+	if err != nil {
+		return RegistryHostnames{}, err
+	}
+	if minor < 7 {
+		return RegistryHostnames{}, fmt.Errorf("Kubernetes version 1.%v not supported. Must be 1.7 or greater", minor)
+	} else if minor <= 11 {
+		registrySvc, err := cClient.Services("default").Get(context.Background(), "docker-registry", metav1.GetOptions{})
+		if err != nil {
+			// Return empty registry host but no error; registry not found
+			return RegistryHostnames{}, nil
+		}
+		log.Info("[GetRegistryInfo] value from clusterIP")
+		return RegistryHostnames{Hostname: registrySvc.Spec.ClusterIP + ":" + strconv.Itoa(int(registrySvc.Spec.Ports[0].Port))}, nil
+	} else {
+		config, err := cClient.ConfigMaps("openshift-apiserver").Get(context.Background(), "config", metav1.GetOptions{})
+		if err != nil {
+			return RegistryHostnames{}, err
+		}
+		serverConfig := APIServerConfig{}
+		err = json.Unmarshal([]byte(config.Data["config.yaml"]), &serverConfig)
+		if err != nil {
+			return RegistryHostnames{}, err
+		}
+		log.Info("[GetRegistryInfo] value from apiserver config")
+		return RegistryHostnames{Hostname: serverConfig.ImagePolicyConfig.InternalRegistryHostname}, nil
+	}
+}
+
+// SplitRegistryAliases returns the hostnames of a comma separated list
+func SplitRegistryAliases(value string) []string {
+	var aliases []string
+	for _, alias := range strings.Split(value, ",") {
+		if alias = strings.TrimSpace(alias); alias != "" {
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// GetBackupRegistryAliases returns the other hostnames of the backup cluster registry recorded on an item
+func GetBackupRegistryAliases(item runtime.Unstructured) []string {
+	_, annotations, err := getMetadataAndAnnotations(item)
+	if err != nil {
+		return nil
+	}
+	return SplitRegistryAliases(annotations[BackupRegistryAliases])
+}
@@ -0,0 +1,96 @@
+package common
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	"github.com/sirupsen/logrus"
+)
+
+func Test_discoverRegistryHostnames(t *testing.T) {
+	source := func(name string, hostnames RegistryHostnames, err error) RegistryDiscovery {
+		return RegistryDiscovery{Name: name, Discover: func(string, logrus.FieldLogger) (RegistryHostnames, error) {
+			return hostnames, err
+		}}
+	}
+	const (
+		service = "image-registry.openshift-image-registry.svc:5000"
+		route   = "default-route-openshift-image-registry.apps.example.com"
+	)
+	tests := []struct {
+		name          string
+		chain         []RegistryDiscovery
+		want          RegistryHostnames
+		wantErrString string
+	}{
+		{
+			name: "first hostname wins, others are aliases",
+			chain: []RegistryDiscovery{
+				source("image-config", RegistryHostnames{Hostname: service, Aliases: []string{"registry.example.com"}}, nil),
+				source("route", RegistryHostnames{Aliases: []string{route}}, nil),
+				source("legacy", RegistryHostnames{Hostname: service}, nil),
+			},
+			want: RegistryHostnames{Hostname: service, Aliases: []string{"registry.example.com", route}},
+		},
+		{
+			name: "override takes precedence",
+			chain: []RegistryDiscovery{
+				source("override", RegistryHostnames{Hostname: "registry.internal:5000"}, nil),
+				source("image-config", RegistryHostnames{Hostname: service}, nil),
+			},
+			want: RegistryHostnames{Hostname: "registry.internal:5000", Aliases: []string{service}},
+		},
+		{
+			name: "alias found before the hostname",
+			chain: []RegistryDiscovery{
+				source("route", RegistryHostnames{Aliases: []string{service, route}}, nil),
+				source("legacy", RegistryHostnames{Hostname: service}, nil),
+			},
+			want: RegistryHostnames{Hostname: service, Aliases: []string{route}},
+		},
+		{
+			name: "failing source is skipped",
+			chain: []RegistryDiscovery{
+				source("image-config", RegistryHostnames{}, errors.New("forbidden")),
+				source("legacy", RegistryHostnames{Hostname: service}, nil),
+			},
+			want: RegistryHostnames{Hostname: service},
+		},
+		{
+			name: "no registry",
+			chain: []RegistryDiscovery{
+				source("image-config", RegistryHostnames{}, nil),
+				source("legacy", RegistryHostnames{}, nil),
+			},
+			want: RegistryHostnames{},
+		},
+		{
+			name: "error when no source found the hostname",
+			chain: []RegistryDiscovery{
+				source("route", RegistryHostnames{Aliases: []string{route}}, nil),
+				source("legacy", RegistryHostnames{}, errors.New("connection refused")),
+			},
+			wantErrString: "errors discovering registry hostname from legacy: connection refused",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := discoverRegistryHostnames(tt.chain, "openshift-adp", test.NewLogger())
+			if tt.wantErrString != "" {
+				if err == nil || err.Error() != tt.wantErrString {
+					t.Errorf("discoverRegistryHostnames() error = %v, wantErrString %v", err, tt.wantErrString)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("discoverRegistryHostnames() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("discoverRegistryHostnames() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
@@ -63,11 +63,19 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	}
 
 	annotations[RestoreServerVersion] = fmt.Sprintf("%v.%v", major, minor)
-	registryHostname, err := GetRegistryInfo(p.Log)
+	err = CheckClusterCompatibility(input.Restore, p.Log)
 	if err != nil {
-		p.Log.Infof("[common-restore] common restore plugin GetRegistryInfo() failed with err %s", err.Error())
+		p.Log.Infof("[common-restore] common restore plugin CheckClusterCompatibility() failed with err %s", err.Error())
 		return nil, err
 	}
+	registryHostname, external, err := GetRestoreRegistryInfo(input.Restore, p.Log)
+	if err != nil {
+		p.Log.Infof("[common-restore] common restore plugin GetRestoreRegistryInfo() failed with err %s", err.Error())
+		return nil, err
+	}
+	if external {
+		p.Log.Infof("[common-restore] using external registry %s for %s", registryHostname, name)
+	}
 	annotations[RestoreRegistryHostname] = registryHostname
 
 	metadata.SetAnnotations(annotations)
@@ -4,19 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
-	"github.com/migtools/udistribution/pkg/image/udistribution"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
 	"github.com/openshift/client-go/route/clientset/versioned/scheme"
-	"github.com/openshift/library-go/pkg/image/reference"
 	"github.com/openshift/oadp-operator/api/v1alpha1"
 	"github.com/openshift/oadp-operator/pkg/credentials"
-	"github.com/sirupsen/logrus"
 	velero "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/meta"
@@ -30,7 +27,6 @@ import (
 )
 
 var (
-	registryInfo  *string
 	serverVersion *serverVersionStruct
 	BackupUidMap  map[types.UID]*CommonStruct
 	lastGarbageCollect time.Time
@@ -38,7 +34,18 @@ var (
 // common cache for backup UIDs
 type CommonStruct struct {
 	Backup *velero.Backup
-	Ut *udistribution.UdistributionTransport
+	PluginConfig map[string]string
+	DigestMapping map[string]string
+	ImagePreflight *imagecopy.PreflightReport
+	// fingerprint of this cluster and, on restore, its comparison with the backup cluster
+	ClusterFingerprint *ClusterFingerprint
+	Compatibility *CompatibilityReport
+	// controller owners of backed up items, nil if not a custom resource included in the backup
+	ControllerOwners map[types.UID]*BackedUpOwner
+	// REST mapper used to look up the controller owners of backed up items
+	RESTMapper meta.RESTMapper
+	// annotations set on the Backup CR by the plugin
+	BackupAnnotations map[string]string
 	lastAccessed time.Time
 }
 
@@ -67,73 +74,6 @@ type serverVersionStruct struct {
 	Minor int
 }
 
-func GetRegistryInfo(log logrus.FieldLogger) (string, error) {
-	if registryInfo != nil {
-		return *registryInfo, nil //use cache
-	}
-
-	imageClient, err := clients.ImageClient()
-	if err != nil {
-		return "", err
-	}
-	imageStreams, err := imageClient.ImageStreams("openshift").List(context.Background(), metav1.ListOptions{})
-	if err == nil && len(imageStreams.Items) > 0 {
-		if value := imageStreams.Items[0].Status.DockerImageRepository; len(value) > 0 {
-			ref, err := reference.Parse(value)
-			if err == nil {
-				log.Info("[GetRegistryInfo] value from imagestream")
-				registryInfo = StringPtr(ref.Registry) //save cache
-				return ref.Registry, nil
-			}
-		}
-	}
-
-	major, minor, err := GetServerVersion()
-	if err != nil {
-		return "", err
-	}
-
-	if major != 1 {
-		return "", fmt.Errorf("server version %v.%v not supported. Must be 1.x", major, minor)
-	}
-
-	cClient, err := clients.CoreClient()
-	if err != nil {
-		return "", err
-	}
-	if minor < 7 {
-		return "", fmt.Errorf("Kubernetes version 1.%v not supported. Must be 1.7 or greater", minor)
-	} else if minor <= 11 {
-		registrySvc, err := cClient.Services("default").Get(context.Background(), "docker-registry", metav1.GetOptions{})
-		if err != nil {
-			// Return empty registry host but no error; registry not found
-			registryInfo = StringPtr("")
-			return "", nil
-		}
-		internalRegistry := registrySvc.Spec.ClusterIP + ":" + strconv.Itoa(int(registrySvc.Spec.Ports[0].Port))
-		registryInfo = &internalRegistry //save cache
-		log.Info("[GetRegistryInfo] value from clusterIP")
-		return internalRegistry, nil
-	} else {
-		config, err := cClient.ConfigMaps("openshift-apiserver").Get(context.Background(), "config", metav1.GetOptions{})
-		if err != nil {
-			return "", err
-		}
-		serverConfig := APIServerConfig{}
-		err = json.Unmarshal([]byte(config.Data["config.yaml"]), &serverConfig)
-		if err != nil {
-			return "", err
-		}
-		internalRegistry := serverConfig.ImagePolicyConfig.InternalRegistryHostname
-		registryInfo = &internalRegistry //save cache
-		if len(internalRegistry) == 0 {
-			return "", nil
-		}
-		log.Info("[GetRegistryInfo] value from clusterIP")
-		return internalRegistry, nil
-	}
-}
-
 func getMetadataAndAnnotations(item runtime.Unstructured) (metav1.Object, map[string]string, error) {
 	metadata, err := meta.Accessor(item)
 	if err != nil {
@@ -251,6 +191,51 @@ func GetBackup(uid types.UID, name string, namespace string) (*velero.Backup, er
 	return &result, nil
 }
 
+// AnnotateBackup sets an annotation on the Backup CR once per backup UID. Velero uploads the Backup CR to the BSL
+// when the backup is finalized, so restores in any cluster read the annotation.
+func AnnotateBackup(backup *velero.Backup, key, value string) error {
+	if BackupUidMap == nil {
+		BackupUidMap = make(map[types.UID]*CommonStruct)
+	}
+	if BackupUidMap[backup.UID] == nil {
+		BackupUidMap[backup.UID] = &CommonStruct{}
+	}
+	BackupUidMap[backup.UID].JustAccessed()
+	if current, ok := BackupUidMap[backup.UID].BackupAnnotations[key]; ok && current == value {
+		return nil
+	}
+
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{key: value},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	client, err := GetVeleroV1Client()
+	if err != nil {
+		return err
+	}
+	err = client.
+		Patch(types.MergePatchType).
+		Namespace(backup.Namespace).
+		Resource("backups").
+		Name(backup.Name).
+		Body(patch).
+		Do(context.Background()).
+		Error()
+	if err != nil {
+		return err
+	}
+
+	if BackupUidMap[backup.UID].BackupAnnotations == nil {
+		BackupUidMap[backup.UID].BackupAnnotations = map[string]string{}
+	}
+	BackupUidMap[backup.UID].BackupAnnotations[key] = value
+	return nil
+}
+
 func StringInSlice(a string, list []string) bool {
 	for _, b := range list {
 		if b == a {
@@ -18,6 +18,7 @@ const (
 	BackupServerVersion     string = "openshift.io/backup-server-version"
 	RestoreServerVersion    string = "openshift.io/restore-server-version"
 	BackupRegistryHostname  string = "openshift.io/backup-registry-hostname"
+	BackupRegistryAliases   string = "openshift.io/backup-registry-aliases" // comma separated other hostnames of the backup registry
 	RestoreRegistryHostname string = "openshift.io/restore-registry-hostname"
 	MigrationRegistry       string = "openshift.io/migration-registry"
 	PausedOwnerRef          string = "openshift.io/paused-owner-ref"
@@ -77,6 +78,7 @@ const (
 // Other annotations
 const (
 	StagePodImageAnnotation   string = "migration.openshift.io/stage-pod-image"     // Stage pod (sleep) image
+	StagePodTemplate          string = "migration.openshift.io/stage-pod-template"  // Restore annotation or ConfigMap key, YAML stage pod template
 	RelatedIsTagNsAnnotation  string = "migration.openshift.io/related-istag-ns"    // Related istag ns
 	RelatedIsTagAnnotation    string = "migration.openshift.io/related-istag"       // Related istag name
 	PVCSelectedNodeAnnotation string = "volume.kubernetes.io/selected-node"         // kubernetes PVC annotations
@@ -97,6 +99,124 @@ const (
 	DCPodLabels                string = "oadp.openshift.io/pod-labels"            // labels from DC pod
 )
 
+// External registry restore options, set as Restore annotations or plugin ConfigMap keys
+const (
+	RestoreExternalRegistry         string = "oadp.openshift.io/restore-external-registry"          // registry (host[/prefix]) images are restored into
+	RestoreExternalRegistrySecret   string = "oadp.openshift.io/restore-external-registry-secret"   // dockerconfigjson secret in the velero namespace
+	RestoreExternalRegistryInsecure string = "oadp.openshift.io/restore-external-registry-insecure" // skip TLS verification for the external registry
+)
+
+// External registry backup options, set as Backup annotations or plugin ConfigMap keys.
+// The secret and insecure options are also read by restores of images from the external registry.
+const (
+	BackupExternalRegistry           string = "oadp.openshift.io/backup-external-registry"            // registry (host[/prefix]) images are backed up to instead of the BSL
+	BackupExternalRegistrySecret     string = "oadp.openshift.io/backup-external-registry-secret"     // dockerconfigjson secret in the velero namespace
+	BackupExternalRegistryInsecure   string = "oadp.openshift.io/backup-external-registry-insecure"   // skip TLS verification for the external registry
+	BackupExternalRegistryRepository string = "oadp.openshift.io/backup-external-registry-repository" // repository template, {{.Backup}}, {{.Namespace}} and {{.Name}}
+	ImageBackupRegistry              string = "oadp.openshift.io/image-backup-registry"               // set on imagestreams whose images were backed up to an external registry
+	ImageBackupRepository            string = "oadp.openshift.io/image-backup-repository"             // repository in the external registry the images were backed up to
+)
+
+// Direct image transfer, images are pulled from the source cluster by the restore instead of being copied by the backup
+const (
+	BackupDirectImageTransfer        string = "oadp.openshift.io/backup-direct-image-transfer"         // Backup annotation or ConfigMap key, true to skip copying images
+	RestoreDirectImageTransferSecret string = "oadp.openshift.io/restore-direct-image-transfer-secret" // Restore annotation or ConfigMap key, secret with the source registry route host and token
+	DirectImageTransfer              string = "oadp.openshift.io/direct-image-transfer"                // set on imagestreams whose images were not copied by the backup
+)
+
+// Cluster fingerprint, recorded on backup and compared with the restoring cluster
+const (
+	BackupClusterFingerprint string = "oadp.openshift.io/backup-cluster-fingerprint" // set on backups, JSON of the backup cluster fingerprint
+	ClusterCompatibility     string = "oadp.openshift.io/cluster-compatibility"      // Backup/Restore annotation or ConfigMap key, warn (default), strict or disabled
+)
+
+// values of ClusterCompatibility
+const (
+	ClusterCompatibilityWarn     = "warn"
+	ClusterCompatibilityStrict   = "strict"
+	ClusterCompatibilityDisabled = "disabled"
+)
+
+// Restore annotation or plugin ConfigMap key, false to keep references to namespaces mapped by the restore,
+// e.g. <service>.<namespace>.svc in env vars and ConfigMaps, unchanged
+const RestoreRewriteNamespaceReferences string = "oadp.openshift.io/restore-rewrite-namespace-references"
+
+// Pod pull secret restore options, set as Restore annotations or plugin ConfigMap keys
+const (
+	RestorePullSecretTimeout  string = "oadp.openshift.io/restore-pull-secret-timeout"  // time the dockercfg secrets may take to be created after the namespace, default 5m
+	RestorePullSecretFallback string = "oadp.openshift.io/restore-pull-secret-fallback" // true to restore pods with their pull secrets unchanged instead of failing
+	PullSecretsNotRemapped    string = "oadp.openshift.io/pull-secrets-not-remapped"    // set on pods restored with their pull secrets unchanged
+)
+
+// Node placement restore options, set as Restore annotations or plugin ConfigMap keys
+const (
+	RestoreNodeSelector     string = "oadp.openshift.io/restore-node-selector"      // keep, strip or remap, default strip for pods and keep for workloads
+	RestoreNodeAffinity     string = "oadp.openshift.io/restore-node-affinity"      // keep (default), strip or remap
+	RestoreTolerations      string = "oadp.openshift.io/restore-tolerations"        // keep (default), strip or remap
+	RestoreNodeLabelMapping string = "oadp.openshift.io/restore-node-label-mapping" // comma separated old:new label keys or key=value pairs
+)
+
+// Objects owned by operator custom resources
+const (
+	BackupControllerOwner       string = "oadp.openshift.io/backup-controller-owner"        // set on items whose controller owner is a backed up custom resource, <resource>.<group>/<name>
+	BackupControllerOwnerLabels string = "oadp.openshift.io/backup-controller-owner-labels" // JSON labels of the backed up controller owner
+	RestoreOperatorOwned        string = "oadp.openshift.io/restore-operator-owned"         // Restore annotation or ConfigMap key, restore (default) or skip items owned by backed up custom resources
+)
+
+// DeploymentConfig conversion, DCs are restored as apps/v1 Deployments
+const (
+	RestoreConvertDeploymentConfigs string = "oadp.openshift.io/restore-convert-deploymentconfigs" // Restore annotation or ConfigMap key, true to restore DCs as Deployments
+	ConvertedFromDeploymentConfig   string = "oadp.openshift.io/converted-from-deploymentconfig"   // set on Deployments converted from a DC, name of the DC
+)
+
+// Build and rollout trigger restore options
+const (
+	RestoreTriggers string = "oadp.openshift.io/restore-triggers" // Restore annotation or ConfigMap key, remap (default) or pause
+	PausedTriggers  string = "oadp.openshift.io/paused-triggers"  // set on BuildConfigs and DeploymentConfigs whose triggers were paused or removed on restore, comma separated trigger types
+)
+
+// values of RestoreTriggers
+const (
+	TriggersRemap = "remap"
+	TriggersPause = "pause"
+)
+
+// Image digest mappings, recorded in the BSL when copying images changed their digests
+const ImageDigestMappings string = "oadp.openshift.io/image-digest-mappings" // set on backups with recorded digest mappings
+
+// Internal registry hostname overrides, set as plugin ConfigMap keys
+const (
+	RegistryHostnameOverride string = "oadp.openshift.io/registry-hostname" // internal registry hostname used instead of the discovered one
+	RegistryAliasesOverride  string = "oadp.openshift.io/registry-aliases"  // comma separated additional hostnames of the internal registry
+)
+
+// Image backup replication, images copied to the BSL of a backup are also copied to the replica BSLs
+const (
+	ImageBackupReplicaLocations string = "oadp.openshift.io/image-backup-replica-locations" // Backup annotation or ConfigMap key, comma separated BSL names
+	ImageBackupReplicas         string = "oadp.openshift.io/image-backup-replicas"          // set on imagestreams, BSLs the images were also copied to
+)
+
+// Image backup tuning options, set as Backup annotations or plugin ConfigMap keys
+const (
+	ImageBackupCompression      string = "oadp.openshift.io/image-backup-compression"       // gzip, zstd or zstd:chunked
+	ImageBackupCompressionLevel string = "oadp.openshift.io/image-backup-compression-level" // compression level of the algorithm
+	ImageBackupMaxImageSize     string = "oadp.openshift.io/image-backup-max-image-size"    // quantity, larger images are skipped
+	ImageBackupBandwidthLimit   string = "oadp.openshift.io/image-backup-bandwidth-limit"   // quantity per second
+	ImageBackupPreflight        string = "oadp.openshift.io/image-backup-preflight"         // Backup annotation, true to report image sizes without copying
+)
+
+// Image encryption, ImageEncryptionSecret is set as Backup/Restore annotation or plugin ConfigMap key
+const (
+	ImageEncryptionSecret string = "oadp.openshift.io/image-encryption-secret" // secret in the velero namespace with the encryption keys
+	ImageEncryption       string = "oadp.openshift.io/image-encryption"        // set on imagestreams whose images were encrypted on backup
+)
+
+// Image registry storage, set as plugin ConfigMap keys for all BSLs
+const (
+	ImageRegistryStorage       string = "oadp.openshift.io/image-registry-storage"        // filesystem to store images in a directory instead of the BSL object storage
+	ImageRegistryRootDirectory string = "oadp.openshift.io/image-registry-root-directory" // directory, e.g. a mounted PV, images are stored in
+)
+
 // Configmap Name
 const RegistryConfigMap string = "oadp-registry-config"
 
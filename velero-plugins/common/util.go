@@ -9,6 +9,7 @@ import (
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
 	"github.com/sirupsen/logrus"
 	corev1API "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -88,80 +89,165 @@ func ParseLocalImageReference(s, prefix string) (*LocalImageReference, error) {
 }
 
 // SwapContainerImageRefs updates internal image references from
-// backup registry to restore registry pathnames
-func SwapContainerImageRefs(containers []corev1API.Container, oldRegistry, newRegistry string, log logrus.FieldLogger, namespaceMapping map[string]string) {
+// backup registry, or one of its aliases, to restore registry pathnames
+func SwapContainerImageRefs(containers []corev1API.Container, oldRegistry, newRegistry string, log logrus.FieldLogger, namespaceMapping map[string]string, oldAliases ...string) {
 	if oldRegistry == "" || newRegistry == "" {
 		return
 	}
 	for n, container := range containers {
 		imageRef := container.Image
 		log.Infof("[util] container image ref %s", imageRef)
-		newImageRef, err := ReplaceImageRefPrefix(imageRef, oldRegistry, newRegistry, namespaceMapping)
-		if err == nil {
-			// Replace local image
-			log.Infof("[util] replacing container image ref %s with %s", imageRef, newImageRef)
-			containers[n].Image = newImageRef
+		for _, registry := range append([]string{oldRegistry}, oldAliases...) {
+			newImageRef, err := ReplaceImageRefPrefix(imageRef, registry, newRegistry, namespaceMapping)
+			if err == nil {
+				// Replace local image
+				log.Infof("[util] replacing container image ref %s with %s", imageRef, newImageRef)
+				containers[n].Image = newImageRef
+				break
+			}
 		}
 	}
 
 }
 
+// RemapContainerImageDigests updates container image references pinned to a digest which
+// changed when the image was copied, using the mapping of old to new digests.
+// Only references into one of the given registries are updated.
+func RemapContainerImageDigests(containers []corev1API.Container, digestMapping map[string]string, log logrus.FieldLogger, registries ...string) {
+	if len(digestMapping) == 0 {
+		return
+	}
+	for n, container := range containers {
+		refSplit := strings.SplitN(container.Image, "@", 2)
+		if len(refSplit) != 2 {
+			continue
+		}
+		newDigest, ok := digestMapping[refSplit[1]]
+		if !ok {
+			continue
+		}
+		for _, registry := range registries {
+			if registry != "" && HasImageRefPrefix(container.Image, registry) {
+				newImageRef := refSplit[0] + "@" + newDigest
+				log.Infof("[util] remapping container image digest %s to %s", container.Image, newImageRef)
+				containers[n].Image = newImageRef
+				break
+			}
+		}
+	}
+}
+
+// RemapImageRef updates a single internal image reference, e.g. the last image a trigger fired for, the same way
+// SwapContainerImageRefs and RemapContainerImageDigests update container images
+func RemapImageRef(imageRef, oldRegistry, newRegistry string, digestMapping map[string]string, log logrus.FieldLogger, namespaceMapping map[string]string, oldAliases ...string) string {
+	containers := []corev1API.Container{{Image: imageRef}}
+	SwapContainerImageRefs(containers, oldRegistry, newRegistry, log, namespaceMapping, oldAliases...)
+	RemapContainerImageDigests(containers, digestMapping, log, oldRegistry, newRegistry)
+	return containers[0].Image
+}
+
+// dockercfg secrets of service accounts are named <service account>-dockercfg-<suffix>
+const dockercfgSecretInfix = "-dockercfg-"
+
+// ErrPullSecretNotFound is returned by UpdatePullSecret if the service account of a dockercfg secret
+// has no dockercfg secret in the dest cluster
+var ErrPullSecretNotFound = errors.New("secret not found")
+
+// DockercfgServiceAccount returns the name of the service account of a dockercfg secret, or "" if
+// secretName is not the name of a service account dockercfg secret
+func DockercfgServiceAccount(secretName string) string {
+	i := strings.LastIndex(secretName, dockercfgSecretInfix)
+	if i <= 0 || i+len(dockercfgSecretInfix) == len(secretName) {
+		return ""
+	}
+	return secretName[:i]
+}
+
 // UpdatePullSecret updates registry pull (or push) secret
 // with a secret found in the dest cluster
 func UpdatePullSecret(
 	secretRef *corev1API.LocalObjectReference,
+	namespace string,
 	secretList *corev1API.SecretList,
 	log logrus.FieldLogger,
 ) (*corev1API.LocalObjectReference, error) {
-	// If secret is empty or doesn't begin with "{builder|default|deployer}-dockercfg-"
+	// If secret is empty or isn't a "<service account>-dockercfg-" secret
 	// then leave it as-is. Either there's no secret or there's a custom one that
 	// should be migrated
 	if secretRef == nil {
 		return secretRef, nil
 	}
-
-	for _, prefix := range []string{"builder-dockercfg-", "default-dockercfg-", "deployer-dockercfg-"} {
-		if strings.HasPrefix(secretRef.Name, prefix) {
-			for _, secret := range secretList.Items {
-				if strings.HasPrefix(secret.Name, prefix) {
-					// get serviceAccount
-					c1cc, err := clients.CoreClient()
-					if err != nil {
-						log.Infof("[util] CoreClient() failed with err %s", err.Error())
-						return nil, err
-					}
-					// use 4.16+ annotations
-					if saName := secret.Annotations[RegistrySANameAnnotation]; saName != "" {
-						sa, err := c1cc.ServiceAccounts(secret.Namespace).Get(context.TODO(), saName, metav1.GetOptions{})
-						if err != nil {
-							log.Infof("[util] client.ServiceAccounts(ns) for ns %s failed with err %s", secret.Namespace, err.Error())
-							return nil, err
-						}
-						// check if secret is associated with serviceAccount in dest cluster by comparing SA pull secret annotation with secret name
-						if sa.Annotations[RegistryPullSecretAnnotation] == secret.Name {
-							log.Info(fmt.Sprintf("[util] Found new dockercfg secret: %v", secret))
-							newSecret := corev1API.LocalObjectReference{Name: secret.Name}
-							return &newSecret, nil
-						}
-					} else if saName := secret.Annotations[LegacySANameAnnotation]; saName != "" {
-						sa, err := c1cc.ServiceAccounts(secret.Namespace).Get(context.TODO(), saName, metav1.GetOptions{})
-						if err != nil {
-							log.Infof("[util] client.ServiceAccounts(ns) for ns %s failed with err %s", secret.Namespace, err.Error())
-							return nil, err
-						}
-						// check if secret is associated with serviceAccount in dest cluster by comparing uids
-						if secret.Annotations[LegacySAUIDAnnotation] == string(sa.UID) {
-							log.Info(fmt.Sprintf("[util] Found new dockercfg secret: %v", secret))
-							newSecret := corev1API.LocalObjectReference{Name: secret.Name}
-							return &newSecret, nil
-						}
-					}
-				}
+	saName := DockercfgServiceAccount(secretRef.Name)
+	if saName == "" {
+		return secretRef, nil
+	}
+	c1cc, err := clients.CoreClient()
+	if err != nil {
+		log.Infof("[util] CoreClient() failed with err %s", err.Error())
+		return nil, err
+	}
+	sa, err := c1cc.ServiceAccounts(namespace).Get(context.TODO(), saName, metav1.GetOptions{})
+	if k8serrors.IsNotFound(err) {
+		// not a service account secret, restored with the other secrets
+		log.Infof("[util] service account %s not found in ns %s, keeping secret %s", saName, namespace, secretRef.Name)
+		return secretRef, nil
+	}
+	if err != nil {
+		log.Infof("[util] client.ServiceAccounts(ns) for ns %s failed with err %s", namespace, err.Error())
+		return nil, err
+	}
+	prefix := saName + dockercfgSecretInfix
+	for _, secret := range secretList.Items {
+		if !strings.HasPrefix(secret.Name, prefix) {
+			continue
+		}
+		// use 4.16+ annotations
+		if secret.Annotations[RegistrySANameAnnotation] == saName {
+			// check if secret is associated with serviceAccount in dest cluster by comparing SA pull secret annotation with secret name
+			if sa.Annotations[RegistryPullSecretAnnotation] == secret.Name {
+				log.Info(fmt.Sprintf("[util] Found new dockercfg secret: %v", secret))
+				return &corev1API.LocalObjectReference{Name: secret.Name}, nil
+			}
+		} else if secret.Annotations[LegacySANameAnnotation] == saName {
+			// check if secret is associated with serviceAccount in dest cluster by comparing uids
+			if secret.Annotations[LegacySAUIDAnnotation] == string(sa.UID) {
+				log.Info(fmt.Sprintf("[util] Found new dockercfg secret: %v", secret))
+				return &corev1API.LocalObjectReference{Name: secret.Name}, nil
 			}
-			return nil, errors.New("secret not found")
 		}
 	}
-	return secretRef, nil
+	return nil, ErrPullSecretNotFound
+}
+
+// UpdatePodSpecPullSecrets updates the service account dockercfg pull secrets of a pod template spec with
+// the secrets of the service accounts in namespace. References to secrets not created yet are removed; the
+// pods get the dockercfg secret of their service account when they are created.
+func UpdatePodSpecPullSecrets(spec *corev1API.PodSpec, namespace string, log logrus.FieldLogger) error {
+	if len(spec.ImagePullSecrets) == 0 {
+		return nil
+	}
+	client, err := clients.CoreClient()
+	if err != nil {
+		return err
+	}
+	secretList, err := client.Secrets(namespace).List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return err
+	}
+	pullSecrets := []corev1API.LocalObjectReference{}
+	for _, secret := range spec.ImagePullSecrets {
+		newSecret, err := UpdatePullSecret(&secret, namespace, secretList, log)
+		if err == ErrPullSecretNotFound {
+			log.Infof("[util] removing pull secret %s, no dockercfg secret of its service account found", secret.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		pullSecrets = append(pullSecrets, *newSecret)
+	}
+	spec.ImagePullSecrets = pullSecrets
+	return nil
 }
 
 // GetSrcAndDestRegistryInfo returns the Registry hostname for both src and dest clusters
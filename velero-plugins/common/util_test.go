@@ -0,0 +1,127 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	corev1API "k8s.io/api/core/v1"
+)
+
+func TestRemapContainerImageDigests(t *testing.T) {
+	digestMapping := map[string]string{
+		"sha256:old": "sha256:new",
+	}
+	tests := []struct {
+		name       string
+		image      string
+		registries []string
+		want       string
+	}{
+		{
+			name:       "pinned internal image is remapped",
+			image:      "image-registry.openshift-image-registry.svc:5000/ns1/app@sha256:old",
+			registries: []string{"docker-registry.default.svc:5000", "image-registry.openshift-image-registry.svc:5000"},
+			want:       "image-registry.openshift-image-registry.svc:5000/ns1/app@sha256:new",
+		},
+		{
+			name:       "digest without mapping is unchanged",
+			image:      "image-registry.openshift-image-registry.svc:5000/ns1/app@sha256:other",
+			registries: []string{"image-registry.openshift-image-registry.svc:5000"},
+			want:       "image-registry.openshift-image-registry.svc:5000/ns1/app@sha256:other",
+		},
+		{
+			name:       "external image is unchanged",
+			image:      "quay.io/ns1/app@sha256:old",
+			registries: []string{"image-registry.openshift-image-registry.svc:5000"},
+			want:       "quay.io/ns1/app@sha256:old",
+		},
+		{
+			name:       "tagged image is unchanged",
+			image:      "image-registry.openshift-image-registry.svc:5000/ns1/app:latest",
+			registries: []string{"image-registry.openshift-image-registry.svc:5000"},
+			want:       "image-registry.openshift-image-registry.svc:5000/ns1/app:latest",
+		},
+		{
+			name:       "empty registry does not match",
+			image:      "image-registry.openshift-image-registry.svc:5000/ns1/app@sha256:old",
+			registries: []string{""},
+			want:       "image-registry.openshift-image-registry.svc:5000/ns1/app@sha256:old",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containers := []corev1API.Container{{Name: "c", Image: tt.image}}
+			RemapContainerImageDigests(containers, digestMapping, test.NewLogger(), tt.registries...)
+			if containers[0].Image != tt.want {
+				t.Errorf("RemapContainerImageDigests() image = %v, want %v", containers[0].Image, tt.want)
+			}
+		})
+	}
+}
+
+func TestSwapContainerImageRefs(t *testing.T) {
+	const (
+		backupRegistry  = "image-registry.openshift-image-registry.svc:5000"
+		restoreRegistry = "image-registry.openshift-image-registry.svc:5000"
+		route           = "default-route-openshift-image-registry.apps.source.example.com"
+	)
+	tests := []struct {
+		name    string
+		image   string
+		aliases []string
+		want    string
+	}{
+		{
+			name:  "internal image is swapped and its namespace mapped",
+			image: backupRegistry + "/ns1/app:latest",
+			want:  restoreRegistry + "/ns2/app:latest",
+		},
+		{
+			name:    "image referenced by registry route is swapped",
+			image:   route + "/ns1/app@sha256:abc",
+			aliases: []string{route},
+			want:    restoreRegistry + "/ns2/app@sha256:abc",
+		},
+		{
+			name:  "image referenced by unknown route is unchanged",
+			image: route + "/ns1/app:latest",
+			want:  route + "/ns1/app:latest",
+		},
+		{
+			name:    "external image is unchanged",
+			image:   "quay.io/ns1/app:latest",
+			aliases: []string{route},
+			want:    "quay.io/ns1/app:latest",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			containers := []corev1API.Container{{Name: "c", Image: tt.image}}
+			SwapContainerImageRefs(containers, backupRegistry, restoreRegistry, test.NewLogger(), map[string]string{"ns1": "ns2"}, tt.aliases...)
+			if containers[0].Image != tt.want {
+				t.Errorf("SwapContainerImageRefs() image = %v, want %v", containers[0].Image, tt.want)
+			}
+		})
+	}
+}
+
+func TestDockercfgServiceAccount(t *testing.T) {
+	tests := []struct {
+		secretName string
+		want       string
+	}{
+		{secretName: "default-dockercfg-abcde", want: "default"},
+		{secretName: "myapp-dockercfg-abcde", want: "myapp"},
+		{secretName: "my-dockercfg-app-dockercfg-abcde", want: "my-dockercfg-app"},
+		{secretName: "custom-secret", want: ""},
+		{secretName: "-dockercfg-abcde", want: ""},
+		{secretName: "myapp-dockercfg-", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.secretName, func(t *testing.T) {
+			if got := DockercfgServiceAccount(tt.secretName); got != tt.want {
+				t.Errorf("DockercfgServiceAccount() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
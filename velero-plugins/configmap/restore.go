@@ -7,6 +7,7 @@ import (
 	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
 	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 // RestorePlugin is a restore item action plugin for Velero
@@ -31,9 +32,6 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
 	}
 	annotations := metadata.GetAnnotations()
-	if annotations == nil {
-		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
-	}
 	if boolVal, ok := annotations[common.SkipBuildConfigConfigMapRestore]; ok {
 		shouldSkip, _ := strconv.ParseBool(boolVal)
 		if shouldSkip {
@@ -41,5 +39,18 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 			return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
 		}
 	}
+
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	data, found, err := unstructured.NestedStringMap(input.Item.UnstructuredContent(), "data")
+	if err == nil && found && rewriter.StringMap(data, p.Log) {
+		p.Log.Infof("[cm-restore] Mapped namespace references in ConfigMap %s", metadata.GetName())
+		err = unstructured.SetNestedStringMap(input.Item.UnstructuredContent(), data, "data")
+		if err != nil {
+			return nil, err
+		}
+	}
 	return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
 }
@@ -35,8 +35,25 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	if err != nil {
 		return nil, err
 	}
-	common.SwapContainerImageRefs(cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
-	common.SwapContainerImageRefs(cronjob.Spec.JobTemplate.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	common.SwapContainerImageRefs(cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	common.SwapContainerImageRefs(cronjob.Spec.JobTemplate.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	rewriter.Containers(cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers, p.Log)
+	rewriter.Containers(cronjob.Spec.JobTemplate.Spec.Template.Spec.InitContainers, p.Log)
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	common.RemapContainerImageDigests(cronjob.Spec.JobTemplate.Spec.Template.Spec.Containers, digestMapping, p.Log, backupRegistry, registry)
+	common.RemapContainerImageDigests(cronjob.Spec.JobTemplate.Spec.Template.Spec.InitContainers, digestMapping, p.Log, backupRegistry, registry)
+	namespace, err := common.GetRestoreNamespace(input.ItemFromBackup, input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.UpdatePodSpecPullSecrets(&cronjob.Spec.JobTemplate.Spec.Template.Spec, namespace, p.Log); err != nil {
+		return nil, err
+	}
 
 	var out map[string]interface{}
 	objrec, _ := json.Marshal(cronjob)
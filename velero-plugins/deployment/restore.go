@@ -35,8 +35,30 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	if err != nil {
 		return nil, err
 	}
-	common.SwapContainerImageRefs(deployment.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
-	common.SwapContainerImageRefs(deployment.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	common.SwapContainerImageRefs(deployment.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	common.SwapContainerImageRefs(deployment.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	rewriter.Containers(deployment.Spec.Template.Spec.Containers, p.Log)
+	rewriter.Containers(deployment.Spec.Template.Spec.InitContainers, p.Log)
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	common.RemapContainerImageDigests(deployment.Spec.Template.Spec.Containers, digestMapping, p.Log, backupRegistry, registry)
+	common.RemapContainerImageDigests(deployment.Spec.Template.Spec.InitContainers, digestMapping, p.Log, backupRegistry, registry)
+	namespace, err := common.GetRestoreNamespace(input.ItemFromBackup, input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.UpdatePodSpecPullSecrets(&deployment.Spec.Template.Spec, namespace, p.Log); err != nil {
+		return nil, err
+	}
+	placement, err := common.GetPlacementPolicy(input.Restore, common.PlacementKeep)
+	if err != nil {
+		return nil, err
+	}
+	placement.Apply(&deployment.Spec.Template.Spec, p.Log)
 
 	var out map[string]interface{}
 	objrec, _ := json.Marshal(deployment)
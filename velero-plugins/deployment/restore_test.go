@@ -0,0 +1,93 @@
+package deployment
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	appsv1API "k8s.io/api/apps/v1"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+// serviceAccountObjects returns the builder service account and its dockercfg secret in namespace
+func serviceAccountObjects(namespace, secret string) map[string]interface{} {
+	return map[string]interface{}{
+		"/api/v1/namespaces/" + namespace + "/serviceaccounts/builder": corev1API.ServiceAccount{
+			ObjectMeta: metav1.ObjectMeta{Name: "builder", Namespace: namespace, Annotations: map[string]string{common.RegistryPullSecretAnnotation: secret}},
+		},
+		"/api/v1/namespaces/" + namespace + "/secrets": corev1API.SecretList{Items: []corev1API.Secret{{
+			ObjectMeta: metav1.ObjectMeta{Name: secret, Namespace: namespace, Annotations: map[string]string{common.RegistrySANameAnnotation: "builder"}},
+		}}},
+	}
+}
+
+func TestRestorePluginExecutePullSecrets(t *testing.T) {
+	// the source namespace still exists on the cluster, restored with a namespace mapping
+	objects := serviceAccountObjects("app", "builder-dockercfg-old")
+	for path, object := range serviceAccountObjects("app-copy", "builder-dockercfg-new") {
+		objects[path] = object
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		object, ok := objects[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(object)
+	}))
+	defer server.Close()
+	client, err := corev1.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clients.SetCoreClient(client))
+
+	deployment := appsv1API.Deployment{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "app"},
+		Spec: appsv1API.DeploymentSpec{Template: corev1API.PodTemplateSpec{Spec: corev1API.PodSpec{
+			ImagePullSecrets: []corev1API.LocalObjectReference{{Name: "builder-dockercfg-backup"}},
+		}}},
+	}
+	content, err := json.Marshal(deployment)
+	if err != nil {
+		t.Fatal(err)
+	}
+	item := &unstructured.Unstructured{}
+	if err := item.UnmarshalJSON(content); err != nil {
+		t.Fatal(err)
+	}
+	restore := &velerov1.Restore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "openshift-adp", UID: "restore-pull-secrets"},
+		Spec:       velerov1.RestoreSpec{NamespaceMapping: map[string]string{"app": "app-copy"}},
+	}
+	if common.BackupUidMap == nil {
+		common.BackupUidMap = map[types.UID]*common.CommonStruct{}
+	}
+	common.BackupUidMap[restore.UID] = &common.CommonStruct{PluginConfig: map[string]string{}}
+
+	plugin := &RestorePlugin{Log: test.NewLogger()}
+	output, err := plugin.Execute(&velero.RestoreItemActionExecuteInput{Item: item, ItemFromBackup: item.DeepCopy(), Restore: restore})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	restored := appsv1API.Deployment{}
+	content, _ = json.Marshal(output.UpdatedItem)
+	json.Unmarshal(content, &restored)
+	want := []corev1API.LocalObjectReference{{Name: "builder-dockercfg-new"}}
+	if got := restored.Spec.Template.Spec.ImagePullSecrets; len(got) != 1 || got[0] != want[0] {
+		t.Errorf("Execute() pull secrets = %v, want %v", got, want)
+	}
+}
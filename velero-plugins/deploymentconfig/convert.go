@@ -0,0 +1,169 @@
+package deploymentconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	appsv1API "github.com/openshift/api/apps/v1"
+	"github.com/openshift/library-go/pkg/image/trigger"
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// convertToDeployment returns the apps/v1 Deployment replacing a DeploymentConfig on restore. Features of the
+// DC that Deployments lack, lifecycle hooks, custom strategies and triggers other than image change, are logged
+// and dropped.
+func convertToDeployment(dc *appsv1API.DeploymentConfig, log logrus.FieldLogger) (*appsv1.Deployment, error) {
+	if dc.Spec.Template == nil {
+		return nil, fmt.Errorf("deploymentconfig %s/%s has no pod template", dc.Namespace, dc.Name)
+	}
+	template := dc.Spec.Template.DeepCopy()
+	selector := dc.Spec.Selector
+	if len(selector) == 0 {
+		// DCs default their selector to the template labels
+		selector = template.Labels
+	}
+	replicas := dc.Spec.Replicas
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: appsv1.SchemeGroupVersion.String(), Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        dc.Name,
+			Namespace:   dc.Namespace,
+			Labels:      dc.Labels,
+			Annotations: map[string]string{},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas:             &replicas,
+			Selector:             &metav1.LabelSelector{MatchLabels: selector},
+			Template:             *template,
+			MinReadySeconds:      dc.Spec.MinReadySeconds,
+			RevisionHistoryLimit: dc.Spec.RevisionHistoryLimit,
+			Paused:               dc.Spec.Paused,
+		},
+	}
+	for key, value := range dc.Annotations {
+		deployment.Annotations[key] = value
+	}
+	deployment.Annotations[common.ConvertedFromDeploymentConfig] = dc.Name
+
+	if dc.Spec.Test {
+		log.Warnf("[deploymentconfig-restore] deploymentconfig %s is a test deployment, converted deployment keeps its replicas running", dc.Name)
+	}
+	if err := convertStrategy(dc, deployment, log); err != nil {
+		return nil, err
+	}
+	triggers, err := convertTriggers(dc, &deployment.Spec.Template.Spec, log)
+	if err != nil {
+		return nil, err
+	}
+	if len(triggers) > 0 {
+		value, err := json.Marshal(triggers)
+		if err != nil {
+			return nil, err
+		}
+		deployment.Annotations[trigger.TriggerAnnotationKey] = string(value)
+	}
+	return deployment, nil
+}
+
+// convertStrategy sets the strategy of deployment from the one of dc
+func convertStrategy(dc *appsv1API.DeploymentConfig, deployment *appsv1.Deployment, log logrus.FieldLogger) error {
+	strategy := dc.Spec.Strategy
+	var timeoutSeconds *int64
+	switch strategy.Type {
+	case appsv1API.DeploymentStrategyTypeRecreate:
+		deployment.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		if params := strategy.RecreateParams; params != nil {
+			timeoutSeconds = params.TimeoutSeconds
+			if params.Pre != nil || params.Mid != nil || params.Post != nil {
+				log.Warnf("[deploymentconfig-restore] lifecycle hooks of deploymentconfig %s are not supported by deployments, dropping them", dc.Name)
+			}
+		}
+	case appsv1API.DeploymentStrategyTypeRolling, "":
+		deployment.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+		if params := strategy.RollingParams; params != nil {
+			timeoutSeconds = params.TimeoutSeconds
+			deployment.Spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{
+				MaxUnavailable: params.MaxUnavailable,
+				MaxSurge:       params.MaxSurge,
+			}
+			if params.Pre != nil || params.Post != nil {
+				log.Warnf("[deploymentconfig-restore] lifecycle hooks of deploymentconfig %s are not supported by deployments, dropping them", dc.Name)
+			}
+		}
+	case appsv1API.DeploymentStrategyTypeCustom:
+		log.Warnf("[deploymentconfig-restore] custom strategy of deploymentconfig %s is not supported by deployments, using rolling update", dc.Name)
+		deployment.Spec.Strategy.Type = appsv1.RollingUpdateDeploymentStrategyType
+	default:
+		return fmt.Errorf("deploymentconfig %s has unknown strategy type %q", dc.Name, strategy.Type)
+	}
+	if timeoutSeconds != nil {
+		progressDeadlineSeconds := int32(*timeoutSeconds)
+		deployment.Spec.ProgressDeadlineSeconds = &progressDeadlineSeconds
+	}
+	return nil
+}
+
+// convertTriggers returns the image trigger annotation entries of the ImageChange triggers of dc. Containers
+// whose image was only ever set by a trigger get its last triggered image.
+func convertTriggers(dc *appsv1API.DeploymentConfig, spec *corev1API.PodSpec, log logrus.FieldLogger) ([]trigger.ObjectFieldTrigger, error) {
+	triggers := []trigger.ObjectFieldTrigger{}
+	configChange := len(dc.Spec.Triggers) == 0
+	for _, dcTrigger := range dc.Spec.Triggers {
+		switch dcTrigger.Type {
+		case appsv1API.DeploymentTriggerOnConfigChange:
+			configChange = true
+			continue
+		case appsv1API.DeploymentTriggerOnImageChange:
+		default:
+			log.Warnf("[deploymentconfig-restore] trigger %s of deploymentconfig %s is not supported by deployments, dropping it", dcTrigger.Type, dc.Name)
+			continue
+		}
+		params := dcTrigger.ImageChangeParams
+		if params == nil {
+			continue
+		}
+		if params.From.Kind != "ImageStreamTag" {
+			return nil, fmt.Errorf("image change trigger of deploymentconfig %s references unsupported kind %s", dc.Name, params.From.Kind)
+		}
+		from := trigger.ObjectReference{Kind: params.From.Kind, Name: params.From.Name}
+		if params.From.Namespace != dc.Namespace {
+			from.Namespace = params.From.Namespace
+		}
+		for _, name := range params.ContainerNames {
+			fieldPath, container := containerFieldPath(spec, name)
+			if container == nil {
+				log.Warnf("[deploymentconfig-restore] container %s of image change trigger of deploymentconfig %s not found, dropping it", name, dc.Name)
+				continue
+			}
+			if strings.TrimSpace(container.Image) == "" {
+				container.Image = params.LastTriggeredImage
+			}
+			triggers = append(triggers, trigger.ObjectFieldTrigger{From: from, FieldPath: fieldPath, Paused: !params.Automatic})
+		}
+	}
+	if !configChange {
+		log.Warnf("[deploymentconfig-restore] deploymentconfig %s has no config change trigger, converted deployment rolls out on every template change", dc.Name)
+	}
+	return triggers, nil
+}
+
+// containerFieldPath returns the trigger field path of the image of the container or init container name of
+// spec, and the container
+func containerFieldPath(spec *corev1API.PodSpec, name string) (string, *corev1API.Container) {
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == name {
+			return fmt.Sprintf("spec.template.spec.containers[?(@.name==%q)].image", name), &spec.Containers[i]
+		}
+	}
+	for i := range spec.InitContainers {
+		if spec.InitContainers[i].Name == name {
+			return fmt.Sprintf("spec.template.spec.initContainers[?(@.name==%q)].image", name), &spec.InitContainers[i]
+		}
+	}
+	return "", nil
+}
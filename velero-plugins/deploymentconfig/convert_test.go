@@ -0,0 +1,140 @@
+package deploymentconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	appsv1API "github.com/openshift/api/apps/v1"
+	"github.com/openshift/library-go/pkg/image/trigger"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func testDeploymentConfig() *appsv1API.DeploymentConfig {
+	return &appsv1API.DeploymentConfig{
+		ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "app", Labels: map[string]string{"app": "frontend"}},
+		Spec: appsv1API.DeploymentConfigSpec{
+			Replicas: 2,
+			Selector: map[string]string{"deploymentconfig": "frontend"},
+			Template: &corev1API.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{"deploymentconfig": "frontend"}},
+				Spec: corev1API.PodSpec{
+					InitContainers: []corev1API.Container{{Name: "init", Image: "init:latest"}},
+					Containers:     []corev1API.Container{{Name: "web", Image: " "}},
+				},
+			},
+			Triggers: appsv1API.DeploymentTriggerPolicies{
+				{Type: appsv1API.DeploymentTriggerOnConfigChange},
+				{
+					Type: appsv1API.DeploymentTriggerOnImageChange,
+					ImageChangeParams: &appsv1API.DeploymentTriggerImageChangeParams{
+						Automatic:          true,
+						ContainerNames:     []string{"web"},
+						From:               corev1API.ObjectReference{Kind: "ImageStreamTag", Name: "web:latest", Namespace: "app"},
+						LastTriggeredImage: "image-registry.openshift-image-registry.svc:5000/app/web@sha256:1234",
+					},
+				},
+				{
+					Type: appsv1API.DeploymentTriggerOnImageChange,
+					ImageChangeParams: &appsv1API.DeploymentTriggerImageChangeParams{
+						ContainerNames: []string{"init"},
+						From:           corev1API.ObjectReference{Kind: "ImageStreamTag", Name: "init:latest", Namespace: "shared"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestConvertToDeployment(t *testing.T) {
+	deployment, err := convertToDeployment(testDeploymentConfig(), test.NewLogger())
+	if err != nil {
+		t.Fatalf("convertToDeployment() error = %v", err)
+	}
+	if deployment.APIVersion != "apps/v1" || deployment.Kind != "Deployment" {
+		t.Errorf("convertToDeployment() type = %s %s, want apps/v1 Deployment", deployment.APIVersion, deployment.Kind)
+	}
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("convertToDeployment() replicas = %d, want 2", *deployment.Spec.Replicas)
+	}
+	wantSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"deploymentconfig": "frontend"}}
+	if !reflect.DeepEqual(deployment.Spec.Selector, wantSelector) {
+		t.Errorf("convertToDeployment() selector = %v, want %v", deployment.Spec.Selector, wantSelector)
+	}
+	if deployment.Annotations[common.ConvertedFromDeploymentConfig] != "frontend" {
+		t.Errorf("convertToDeployment() did not set %s", common.ConvertedFromDeploymentConfig)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "image-registry.openshift-image-registry.svc:5000/app/web@sha256:1234" {
+		t.Errorf("convertToDeployment() image = %q, want last triggered image", image)
+	}
+	wantTriggers := `[{"from":{"kind":"ImageStreamTag","name":"web:latest"},"fieldPath":"spec.template.spec.containers[?(@.name==\"web\")].image"},` +
+		`{"from":{"kind":"ImageStreamTag","name":"init:latest","namespace":"shared"},"fieldPath":"spec.template.spec.initContainers[?(@.name==\"init\")].image","paused":true}]`
+	if triggers := deployment.Annotations[trigger.TriggerAnnotationKey]; triggers != wantTriggers {
+		t.Errorf("convertToDeployment() triggers = %s, want %s", triggers, wantTriggers)
+	}
+}
+
+func TestConvertStrategy(t *testing.T) {
+	timeoutSeconds := int64(600)
+	maxSurge := intstr.FromString("50%")
+	progressDeadlineSeconds := int32(600)
+	tests := []struct {
+		name     string
+		strategy appsv1API.DeploymentStrategy
+		want     appsv1.DeploymentStrategy
+		deadline *int32
+		wantErr  bool
+	}{
+		{name: "default", want: appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType}},
+		{
+			name: "rolling",
+			strategy: appsv1API.DeploymentStrategy{
+				Type:          appsv1API.DeploymentStrategyTypeRolling,
+				RollingParams: &appsv1API.RollingDeploymentStrategyParams{MaxSurge: &maxSurge, TimeoutSeconds: &timeoutSeconds},
+			},
+			want: appsv1.DeploymentStrategy{
+				Type:          appsv1.RollingUpdateDeploymentStrategyType,
+				RollingUpdate: &appsv1.RollingUpdateDeployment{MaxSurge: &maxSurge},
+			},
+			deadline: &progressDeadlineSeconds,
+		},
+		{
+			name: "recreate with hooks",
+			strategy: appsv1API.DeploymentStrategy{
+				Type:           appsv1API.DeploymentStrategyTypeRecreate,
+				RecreateParams: &appsv1API.RecreateDeploymentStrategyParams{Pre: &appsv1API.LifecycleHook{}},
+			},
+			want: appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType},
+		},
+		{
+			name:     "custom",
+			strategy: appsv1API.DeploymentStrategy{Type: appsv1API.DeploymentStrategyTypeCustom},
+			want:     appsv1.DeploymentStrategy{Type: appsv1.RollingUpdateDeploymentStrategyType},
+		},
+		{name: "unknown", strategy: appsv1API.DeploymentStrategy{Type: "Blue"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc := testDeploymentConfig()
+			dc.Spec.Strategy = tt.strategy
+			deployment := &appsv1.Deployment{}
+			err := convertStrategy(dc, deployment, test.NewLogger())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("convertStrategy() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(deployment.Spec.Strategy, tt.want) {
+				t.Errorf("convertStrategy() = %+v, want %+v", deployment.Spec.Strategy, tt.want)
+			}
+			if !reflect.DeepEqual(deployment.Spec.ProgressDeadlineSeconds, tt.deadline) {
+				t.Errorf("convertStrategy() progressDeadlineSeconds = %v, want %v", deployment.Spec.ProgressDeadlineSeconds, tt.deadline)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package deploymentconfig
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	appsv1API "github.com/openshift/api/apps/v1"
+	v1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/label"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	corev1API "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+	"k8s.io/utils/pointer"
+)
+
+var podVolumeRestoreResource = schema.GroupVersionResource{Group: "velero.io", Version: "v1", Resource: "podvolumerestores"}
+
+// reconcileOperationID returns the ID of the operation restoring the original scale of a DC, or of the
+// Deployment it was converted to
+func reconcileOperationID(resource, namespace, name string) string {
+	return resource + "/" + namespace + "/" + name
+}
+
+// parseReconcileOperationID returns the resource, namespace and name of an operation ID
+func parseReconcileOperationID(operationID string) (string, string, string, error) {
+	parts := strings.Split(operationID, "/")
+	if len(parts) != 3 || (parts[0] != "deploymentconfigs" && parts[0] != "deployments") || parts[1] == "" || parts[2] == "" {
+		return "", "", "", fmt.Errorf("invalid operation ID %q, must be deploymentconfigs|deployments/<namespace>/<name>", operationID)
+	}
+	return parts[0], parts[1], parts[2], nil
+}
+
+// Progress restores the original replicas and paused state of a DC scaled down on restore once the pod volume
+// restores of its disconnected pods are done, then deletes the disconnected pods so that the DC replaces them
+func (p *RestorePlugin) Progress(operationID string, restore *v1.Restore) (velero.OperationProgress, error) {
+	progress := velero.OperationProgress{OperationUnits: "PodVolumeRestores", Updated: time.Now()}
+	resource, namespace, name, err := parseReconcileOperationID(operationID)
+	if err != nil {
+		return progress, err
+	}
+	pods, err := disconnectedPods(namespace, name, restore)
+	if err != nil {
+		return progress, err
+	}
+	podVolumeRestores, err := listPodVolumeRestores(restore)
+	if err != nil {
+		return progress, err
+	}
+	podNames := map[string]bool{}
+	for _, pod := range pods {
+		podNames[pod.Name] = true
+	}
+	pending, total := countPendingPodVolumeRestores(podVolumeRestores, namespace, podNames)
+	progress.NTotal = total
+	progress.NCompleted = total - pending
+	if pending > 0 {
+		progress.Description = fmt.Sprintf("waiting for %d pod volume restores", pending)
+		return progress, nil
+	}
+	if resource == "deployments" {
+		err = p.restoreDeploymentOriginalScale(namespace, name)
+	} else {
+		err = p.restoreOriginalScale(namespace, name)
+	}
+	if err != nil {
+		return progress, err
+	}
+	client, err := clients.CoreClient()
+	if err != nil {
+		return progress, err
+	}
+	for _, pod := range pods {
+		p.Log.Infof("[deploymentconfig-restore] deleting disconnected pod %s/%s", namespace, pod.Name)
+		err := client.Pods(namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{})
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return progress, err
+		}
+	}
+	progress.Completed = true
+	progress.Description = "restored original replicas"
+	return progress, nil
+}
+
+// Cancel leaves the DC scaled down
+func (p *RestorePlugin) Cancel(operationID string, restore *v1.Restore) error {
+	p.Log.Infof("[deploymentconfig-restore] operation %s canceled, deploymentconfig stays scaled down", operationID)
+	return nil
+}
+
+func (p *RestorePlugin) AreAdditionalItemsReady(additionalItems []velero.ResourceIdentifier, restore *v1.Restore) (bool, error) {
+	return true, nil
+}
+
+// disconnectedPods returns the pods of a DC disconnected from it by restore
+func disconnectedPods(namespace, name string, restore *v1.Restore) ([]corev1API.Pod, error) {
+	client, err := clients.CoreClient()
+	if err != nil {
+		return nil, err
+	}
+	podList, err := client.Pods(namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: common.DCPodDisconnectedLabel + "=" + label.GetValidName(restore.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	pods := []corev1API.Pod{}
+	for _, pod := range podList.Items {
+		// the DC labels were removed, the annotation is kept
+		if pod.Annotations[appsv1API.DeploymentConfigAnnotation] == name {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// listPodVolumeRestores returns the pod volume restores of restore
+func listPodVolumeRestores(restore *v1.Restore) ([]v1.PodVolumeRestore, error) {
+	client, err := clients.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	list, err := client.Resource(podVolumeRestoreResource).Namespace(restore.Namespace).List(context.Background(), metav1.ListOptions{
+		LabelSelector: v1.RestoreNameLabel + "=" + label.GetValidName(restore.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	podVolumeRestores := []v1.PodVolumeRestore{}
+	for _, item := range list.Items {
+		podVolumeRestore := v1.PodVolumeRestore{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &podVolumeRestore); err != nil {
+			return nil, err
+		}
+		podVolumeRestores = append(podVolumeRestores, podVolumeRestore)
+	}
+	return podVolumeRestores, nil
+}
+
+// countPendingPodVolumeRestores returns the number of pod volume restores of pods in namespace that are not
+// completed or failed, and their total number
+func countPendingPodVolumeRestores(podVolumeRestores []v1.PodVolumeRestore, namespace string, pods map[string]bool) (int64, int64) {
+	var pending, total int64
+	for _, podVolumeRestore := range podVolumeRestores {
+		if podVolumeRestore.Spec.Pod.Namespace != namespace || !pods[podVolumeRestore.Spec.Pod.Name] {
+			continue
+		}
+		total++
+		if podVolumeRestore.Status.Phase != v1.PodVolumeRestorePhaseCompleted && podVolumeRestore.Status.Phase != v1.PodVolumeRestorePhaseFailed {
+			pending++
+		}
+	}
+	return pending, total
+}
+
+// restoreOriginalScale sets the replicas and paused state of a DC from the annotations set on restore and removes
+// them and the replicas-modified label
+func (p *RestorePlugin) restoreOriginalScale(namespace, name string) error {
+	client, err := clients.OCPAppsClient()
+	if err != nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deploymentConfig, err := client.DeploymentConfigs(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := deploymentConfig.Labels[common.DCReplicasModifiedLabel]; !ok {
+			// already restored
+			return nil
+		}
+		replicas, paused, err := originalScale(deploymentConfig.Annotations)
+		if err != nil {
+			return err
+		}
+		if replicas != nil {
+			deploymentConfig.Spec.Replicas = *replicas
+		}
+		if paused != nil {
+			deploymentConfig.Spec.Paused = *paused
+		}
+		clearScaleTracking(&deploymentConfig.ObjectMeta)
+		p.Log.Infof("[deploymentconfig-restore] restoring deploymentconfig %s/%s to %d replicas, paused %v", namespace, name, deploymentConfig.Spec.Replicas, deploymentConfig.Spec.Paused)
+		_, err = client.DeploymentConfigs(namespace).Update(context.Background(), deploymentConfig, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// restoreDeploymentOriginalScale is restoreOriginalScale for a DC converted to a Deployment
+func (p *RestorePlugin) restoreDeploymentOriginalScale(namespace, name string) error {
+	client, err := clients.AppsClient()
+	if err != nil {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		deployment, err := client.Deployments(namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if _, ok := deployment.Labels[common.DCReplicasModifiedLabel]; !ok {
+			// already restored
+			return nil
+		}
+		replicas, paused, err := originalScale(deployment.Annotations)
+		if err != nil {
+			return err
+		}
+		if replicas != nil {
+			deployment.Spec.Replicas = replicas
+		}
+		if paused != nil {
+			deployment.Spec.Paused = *paused
+		}
+		clearScaleTracking(&deployment.ObjectMeta)
+		p.Log.Infof("[deploymentconfig-restore] restoring converted deployment %s/%s to %d replicas, paused %v", namespace, name, *deployment.Spec.Replicas, deployment.Spec.Paused)
+		_, err = client.Deployments(namespace).Update(context.Background(), deployment, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// originalScale returns the replicas and paused state stored in annotations on restore, nil if not stored
+func originalScale(annotations map[string]string) (*int32, *bool, error) {
+	var replicas *int32
+	var paused *bool
+	if value, ok := annotations[common.DCOriginalReplicas]; ok {
+		parsed, err := strconv.ParseInt(value, 10, 32)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid %s %q: %v", common.DCOriginalReplicas, value, err)
+		}
+		replicas = pointer.Int32(int32(parsed))
+	}
+	if value, ok := annotations[common.DCOriginalPaused]; ok {
+		paused = pointer.Bool(value == "true")
+	}
+	return replicas, paused, nil
+}
+
+// clearScaleTracking removes the annotations and label set on scale down
+func clearScaleTracking(objectMeta *metav1.ObjectMeta) {
+	delete(objectMeta.Annotations, common.DCOriginalReplicas)
+	delete(objectMeta.Annotations, common.DCOriginalPaused)
+	delete(objectMeta.Labels, common.DCReplicasModifiedLabel)
+}
@@ -0,0 +1,217 @@
+package deploymentconfig
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	appsv1API "github.com/openshift/api/apps/v1"
+	ocpappsv1 "github.com/openshift/client-go/apps/clientset/versioned/typed/apps/v1"
+	v1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func TestParseReconcileOperationID(t *testing.T) {
+	tests := []struct {
+		name          string
+		operationID   string
+		wantResource  string
+		wantNamespace string
+		wantName      string
+		wantErr       bool
+	}{
+		{
+			name:          "deploymentconfig",
+			operationID:   reconcileOperationID("deploymentconfigs", "app", "frontend"),
+			wantResource:  "deploymentconfigs",
+			wantNamespace: "app",
+			wantName:      "frontend",
+		},
+		{
+			name:          "converted deployment",
+			operationID:   reconcileOperationID("deployments", "app", "frontend"),
+			wantResource:  "deployments",
+			wantNamespace: "app",
+			wantName:      "frontend",
+		},
+		{name: "missing name", operationID: "deploymentconfigs/app/", wantErr: true},
+		{name: "missing resource", operationID: "app/frontend", wantErr: true},
+		{name: "unknown resource", operationID: "statefulsets/app/frontend", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resource, namespace, name, err := parseReconcileOperationID(tt.operationID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseReconcileOperationID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if resource != tt.wantResource || namespace != tt.wantNamespace || name != tt.wantName {
+				t.Errorf("parseReconcileOperationID() = %s, %s, %s, want %s, %s, %s", resource, namespace, name, tt.wantResource, tt.wantNamespace, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestCountPendingPodVolumeRestores(t *testing.T) {
+	podVolumeRestore := func(namespace, pod string, phase v1.PodVolumeRestorePhase) v1.PodVolumeRestore {
+		return v1.PodVolumeRestore{
+			Spec:   v1.PodVolumeRestoreSpec{Pod: corev1API.ObjectReference{Namespace: namespace, Name: pod}},
+			Status: v1.PodVolumeRestoreStatus{Phase: phase},
+		}
+	}
+	tests := []struct {
+		name              string
+		podVolumeRestores []v1.PodVolumeRestore
+		wantPending       int64
+		wantTotal         int64
+	}{
+		{name: "none"},
+		{
+			name: "in progress",
+			podVolumeRestores: []v1.PodVolumeRestore{
+				podVolumeRestore("app", "frontend-1-abcde", v1.PodVolumeRestorePhaseCompleted),
+				podVolumeRestore("app", "frontend-1-abcde", v1.PodVolumeRestorePhaseInProgress),
+				podVolumeRestore("app", "frontend-1-fghij", v1.PodVolumeRestorePhaseNew),
+			},
+			wantPending: 2,
+			wantTotal:   3,
+		},
+		{
+			name: "done",
+			podVolumeRestores: []v1.PodVolumeRestore{
+				podVolumeRestore("app", "frontend-1-abcde", v1.PodVolumeRestorePhaseCompleted),
+				podVolumeRestore("app", "frontend-1-fghij", v1.PodVolumeRestorePhaseFailed),
+			},
+			wantTotal: 2,
+		},
+		{
+			name: "other pods",
+			podVolumeRestores: []v1.PodVolumeRestore{
+				podVolumeRestore("app", "backend-1-abcde", v1.PodVolumeRestorePhaseInProgress),
+				podVolumeRestore("other", "frontend-1-abcde", v1.PodVolumeRestorePhaseInProgress),
+			},
+		},
+	}
+	pods := map[string]bool{"frontend-1-abcde": true, "frontend-1-fghij": true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pending, total := countPendingPodVolumeRestores(tt.podVolumeRestores, "app", pods)
+			if pending != tt.wantPending || total != tt.wantTotal {
+				t.Errorf("countPendingPodVolumeRestores() = %d, %d, want %d, %d", pending, total, tt.wantPending, tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestProgressNamespaceMapping(t *testing.T) {
+	restore := &v1.Restore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore", Namespace: "openshift-adp", UID: "restore-dc-mapping"},
+		Spec:       v1.RestoreSpec{BackupName: "backup", NamespaceMapping: map[string]string{"app": "app-copy"}},
+	}
+	if common.BackupUidMap == nil {
+		common.BackupUidMap = map[types.UID]*common.CommonStruct{}
+	}
+	// the backup recorded no digest mappings
+	common.BackupUidMap[restore.UID] = &common.CommonStruct{PluginConfig: map[string]string{}, DigestMapping: map[string]string{}}
+	deploymentConfig := appsv1API.DeploymentConfig{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps.openshift.io/v1", Kind: "DeploymentConfig"},
+		ObjectMeta: metav1.ObjectMeta{Name: "frontend", Namespace: "app", Annotations: map[string]string{
+			common.DCIncludesDMFix:   "true",
+			common.DCPodsHaveVolumes: "true",
+		}},
+		Spec: appsv1API.DeploymentConfigSpec{Replicas: 2, Template: &corev1API.PodTemplateSpec{}},
+	}
+	content, _ := json.Marshal(deploymentConfig)
+	item := &unstructured.Unstructured{}
+	if err := item.UnmarshalJSON(content); err != nil {
+		t.Fatal(err)
+	}
+	plugin := &RestorePlugin{Log: test.NewLogger()}
+	output, err := plugin.Execute(&velero.RestoreItemActionExecuteInput{Item: item, ItemFromBackup: item.DeepCopy(), Restore: restore})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	restored := appsv1API.DeploymentConfig{}
+	content, _ = json.Marshal(output.UpdatedItem)
+	json.Unmarshal(content, &restored)
+	// velero creates the scaled down DC in the mapped namespace
+	restored.Namespace = "app-copy"
+
+	// serves the restored DC, its disconnected pod and the completed pod volume restore in the mapped namespace
+	pod := corev1API.Pod{ObjectMeta: metav1.ObjectMeta{Name: "frontend-1-abcde", Namespace: "app-copy", Annotations: map[string]string{
+		appsv1API.DeploymentConfigAnnotation: "frontend",
+	}}}
+	podVolumeRestores := map[string]interface{}{
+		"apiVersion": "velero.io/v1",
+		"kind":       "PodVolumeRestoreList",
+		"items": []interface{}{map[string]interface{}{
+			"apiVersion": "velero.io/v1",
+			"kind":       "PodVolumeRestore",
+			"metadata":   map[string]interface{}{"name": "pvr", "namespace": "openshift-adp"},
+			"spec":       map[string]interface{}{"pod": map[string]interface{}{"namespace": "app-copy", "name": pod.Name}},
+			"status":     map[string]interface{}{"phase": string(v1.PodVolumeRestorePhaseCompleted)},
+		}},
+	}
+	deletedPods := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v1/namespaces/app-copy/pods":
+			json.NewEncoder(w).Encode(corev1API.PodList{Items: []corev1API.Pod{pod}})
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v1/namespaces/app-copy/pods/"+pod.Name:
+			deletedPods = append(deletedPods, pod.Name)
+			json.NewEncoder(w).Encode(metav1.Status{Status: metav1.StatusSuccess})
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/velero.io/v1/namespaces/openshift-adp/podvolumerestores":
+			json.NewEncoder(w).Encode(podVolumeRestores)
+		case r.Method == http.MethodGet && r.URL.Path == "/apis/apps.openshift.io/v1/namespaces/app-copy/deploymentconfigs/frontend":
+			json.NewEncoder(w).Encode(restored)
+		case r.Method == http.MethodPut && r.URL.Path == "/apis/apps.openshift.io/v1/namespaces/app-copy/deploymentconfigs/frontend":
+			restored = appsv1API.DeploymentConfig{}
+			json.NewDecoder(r.Body).Decode(&restored)
+			json.NewEncoder(w).Encode(restored)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	config := &rest.Config{Host: server.URL}
+	coreClient, err := corev1.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clients.SetCoreClient(coreClient))
+	ocpAppsClient, err := ocpappsv1.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clients.SetOCPAppsClient(ocpAppsClient))
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clients.SetDynamicClient(dynamicClient))
+
+	progress, err := plugin.Progress(output.OperationID, restore)
+	if err != nil {
+		t.Fatalf("Progress() error = %v", err)
+	}
+	if !progress.Completed {
+		t.Errorf("Progress() = %+v, want completed", progress)
+	}
+	if restored.Spec.Replicas != 2 {
+		t.Errorf("Progress() restored %d replicas, want 2", restored.Spec.Replicas)
+	}
+	if len(deletedPods) != 1 {
+		t.Errorf("Progress() deleted pods %v, want [%s]", deletedPods, pod.Name)
+	}
+}
@@ -21,6 +21,11 @@ type RestorePlugin struct {
 	Log logrus.FieldLogger
 }
 
+// This won't be called but is needed to implement interface
+func (p *RestorePlugin) Name() string {
+	return "deploymentconfig-restore"
+}
+
 // AppliesTo returns a velero.ResourceSelector that applies to deploymentconfigs
 func (p *RestorePlugin) AppliesTo() (velero.ResourceSelector, error) {
 	return velero.ResourceSelector{
@@ -41,11 +46,32 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	if err != nil {
 		return nil, err
 	}
-	common.SwapContainerImageRefs(deploymentConfig.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
-	common.SwapContainerImageRefs(deploymentConfig.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	common.SwapContainerImageRefs(deploymentConfig.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	common.SwapContainerImageRefs(deploymentConfig.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	rewriter.Containers(deploymentConfig.Spec.Template.Spec.Containers, p.Log)
+	rewriter.Containers(deploymentConfig.Spec.Template.Spec.InitContainers, p.Log)
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	common.RemapContainerImageDigests(deploymentConfig.Spec.Template.Spec.Containers, digestMapping, p.Log, backupRegistry, registry)
+	common.RemapContainerImageDigests(deploymentConfig.Spec.Template.Spec.InitContainers, digestMapping, p.Log, backupRegistry, registry)
+	namespace, err := common.GetRestoreNamespace(input.ItemFromBackup, input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.UpdatePodSpecPullSecrets(&deploymentConfig.Spec.Template.Spec, namespace, p.Log); err != nil {
+		return nil, err
+	}
+	placement, err := common.GetPlacementPolicy(input.Restore, common.PlacementKeep)
+	if err != nil {
+		return nil, err
+	}
+	placement.Apply(&deploymentConfig.Spec.Template.Spec, p.Log)
 
 	namespaceMapping := input.Restore.Spec.NamespaceMapping
-	newNamespace := namespaceMapping[deploymentConfig.Namespace]
 	if len(input.Restore.Spec.NamespaceMapping) > 0 {
 		for i := range deploymentConfig.Spec.Triggers {
 			if deploymentConfig.Spec.Triggers[i].ImageChangeParams == nil {
@@ -55,16 +81,24 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 			// if trigger namespace is mapped to new one, swap it
 			triggerNamespace := deploymentConfig.Spec.Triggers[i].ImageChangeParams.From.Namespace
 			if namespaceMapping[triggerNamespace] != "" {
-				deploymentConfig.Spec.Triggers[i].ImageChangeParams.From.Namespace = newNamespace
+				deploymentConfig.Spec.Triggers[i].ImageChangeParams.From.Namespace = namespaceMapping[triggerNamespace]
 			}
 		}
 	}
 
+	triggerPolicy, err := common.GetTriggerPolicy(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	updateTriggers(&deploymentConfig, triggerPolicy, func(imageRef string) string {
+		return common.RemapImageRef(imageRef, backupRegistry, registry, digestMapping, p.Log, namespaceMapping, backupAliases...)
+	}, p.Log)
+
 	// Set replicas to 0 if restoring pods
 	// This is because the pods are being restored with the DC labels removed to prevent the DC from
 	// killing them and launching new pods on restore. If replicas isn't set to 0 here, then the DC
-	// will launch another application pod here. The dc post-restore script will restore original
-	// replicas and delete the disconnected pods if run after restore.
+	// will launch another application pod here. The async operation returned for the DC restores
+	// original replicas and deletes the disconnected pods once their pod volume restores are done.
 	disconnectIfDC := false
 	if deploymentConfig.Annotations != nil && len(deploymentConfig.Annotations[common.DCIncludesDMFix]) > 0 {
 		hasVolumes, ok := deploymentConfig.Annotations[common.DCPodsHaveVolumes]
@@ -110,9 +144,30 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		p.Log.Infof("[deploymentconfig-restore] scaling down deploymentconfig, setting original-replicas, original-paused annotations to %ss,%s, setting replicas-modified label to %s", deploymentConfig.Annotations[common.DCOriginalReplicas], deploymentConfig.Annotations[common.DCOriginalPaused], labelVal)
 	}
 
+	convert, err := common.ConvertDeploymentConfigs(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	var restored interface{} = deploymentConfig
+	resource := "deploymentconfigs"
+	if convert {
+		p.Log.Infof("[deploymentconfig-restore] converting deploymentconfig %s to deployment", deploymentConfig.Name)
+		deployment, err := convertToDeployment(&deploymentConfig, p.Log)
+		if err != nil {
+			return nil, err
+		}
+		restored = deployment
+		resource = "deployments"
+	}
+
 	var out map[string]interface{}
-	objrec, _ := json.Marshal(deploymentConfig)
+	objrec, _ := json.Marshal(restored)
 	json.Unmarshal(objrec, &out)
 
-	return velero.NewRestoreItemActionExecuteOutput(&unstructured.Unstructured{Object: out}), nil
+	output := velero.NewRestoreItemActionExecuteOutput(&unstructured.Unstructured{Object: out})
+	if deploymentConfig.Labels[common.DCReplicasModifiedLabel] == label.GetValidName(input.Restore.Name) {
+		// the DC is restored to the mapped namespace
+		output = output.WithOperationID(reconcileOperationID(resource, namespace, deploymentConfig.Name))
+	}
+	return output, nil
 }
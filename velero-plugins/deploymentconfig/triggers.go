@@ -0,0 +1,33 @@
+package deploymentconfig
+
+import (
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	appsv1API "github.com/openshift/api/apps/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// updateTriggers remaps the last triggered image of the ImageChange triggers of dc to the restored image, so that
+// the restored ImageStreamTags don't start a rollout, and makes them manual with the pause policy
+func updateTriggers(dc *appsv1API.DeploymentConfig, policy string, remapImage func(string) string, log logrus.FieldLogger) {
+	paused := false
+	for i := range dc.Spec.Triggers {
+		params := dc.Spec.Triggers[i].ImageChangeParams
+		if dc.Spec.Triggers[i].Type != appsv1API.DeploymentTriggerOnImageChange || params == nil {
+			continue
+		}
+		if params.LastTriggeredImage != "" {
+			params.LastTriggeredImage = remapImage(params.LastTriggeredImage)
+		}
+		if policy == common.TriggersPause && params.Automatic {
+			log.Infof("[deploymentconfig-restore] pausing image change trigger from %s of deploymentconfig %s", params.From.Name, dc.Name)
+			params.Automatic = false
+			paused = true
+		}
+	}
+	if paused {
+		if dc.Annotations == nil {
+			dc.Annotations = map[string]string{}
+		}
+		dc.Annotations[common.PausedTriggers] = string(appsv1API.DeploymentTriggerOnImageChange)
+	}
+}
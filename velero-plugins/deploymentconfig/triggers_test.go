@@ -0,0 +1,43 @@
+package deploymentconfig
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+)
+
+func TestUpdateTriggers(t *testing.T) {
+	remapImage := func(imageRef string) string {
+		return strings.Replace(imageRef, "image-registry.openshift-image-registry.svc:5000", "new-registry", 1)
+	}
+	tests := []struct {
+		name          string
+		policy        string
+		wantAutomatic bool
+		annotation    string
+	}{
+		{name: "remap", policy: common.TriggersRemap, wantAutomatic: true},
+		{name: "pause", policy: common.TriggersPause, wantAutomatic: false, annotation: "ImageChange"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dc := testDeploymentConfig()
+			updateTriggers(dc, tt.policy, remapImage, test.NewLogger())
+			params := dc.Spec.Triggers[1].ImageChangeParams
+			if params.LastTriggeredImage != "new-registry/app/web@sha256:1234" {
+				t.Errorf("updateTriggers() lastTriggeredImage = %q, want remapped image", params.LastTriggeredImage)
+			}
+			if params.Automatic != tt.wantAutomatic {
+				t.Errorf("updateTriggers() automatic = %v, want %v", params.Automatic, tt.wantAutomatic)
+			}
+			if dc.Spec.Triggers[2].ImageChangeParams.Automatic {
+				t.Errorf("updateTriggers() made a manual trigger automatic")
+			}
+			if annotation := dc.Annotations[common.PausedTriggers]; annotation != tt.annotation {
+				t.Errorf("updateTriggers() %s = %q, want %q", common.PausedTriggers, annotation, tt.annotation)
+			}
+		})
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
 	appsv1API "github.com/openshift/api/apps/v1"
 	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/autoscaling/v2beta1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
 )
@@ -26,6 +28,13 @@ func (p *RestorePlugin) AppliesTo() (velero.ResourceSelector, error) {
 // Execute fixes apiVersion in ScaleTargetRef of HPA
 func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
 	p.Log.Info("[hpa-restore] Entering HorizontalPodAutoscaler restore plugin")
+	convert, err := common.ConvertDeploymentConfigs(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if convert {
+		return p.retargetConvertedDeploymentConfig(input)
+	}
 	hpa := v2beta1.HorizontalPodAutoscaler{}
 	itemMarshal, _ := json.Marshal(input.Item)
 	json.Unmarshal(itemMarshal, &hpa)
@@ -53,3 +62,23 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 
 	return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
 }
+
+// retargetConvertedDeploymentConfig points HPAs scaling a DeploymentConfig at the Deployment it is converted to.
+// The item is edited in place since the scale target ref is the same in all autoscaling versions.
+func (p *RestorePlugin) retargetConvertedDeploymentConfig(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	item := input.Item.UnstructuredContent()
+	kind, _, _ := unstructured.NestedString(item, "spec", "scaleTargetRef", "kind")
+	if kind != "DeploymentConfig" {
+		p.Log.Info("[hpa-restore] ScaleTargetRef not a DeploymentConfig, leaving as-is")
+		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+	}
+	p.Log.Info("[hpa-restore] Retargeting ScaleTargetRef from converted DeploymentConfig to Deployment")
+	if err := unstructured.SetNestedField(item, appsv1.SchemeGroupVersion.String(), "spec", "scaleTargetRef", "apiVersion"); err != nil {
+		return nil, err
+	}
+	if err := unstructured.SetNestedField(item, "Deployment", "spec", "scaleTargetRef", "kind"); err != nil {
+		return nil, err
+	}
+	input.Item.SetUnstructuredContent(item)
+	return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+}
@@ -3,10 +3,14 @@ package horizontalpodautoscaler
 import (
 	"testing"
 
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 )
 
 func TestRestorePluginAppliesTo(t *testing.T) {
@@ -15,3 +19,21 @@ func TestRestorePluginAppliesTo(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, velero.ResourceSelector{IncludedResources: []string{"horizontalpodautoscalers"}}, actual)
 }
+
+func TestRestorePluginExecuteConvertedDeploymentConfig(t *testing.T) {
+	restorePlugin := &RestorePlugin{Log: test.NewLogger()}
+	item := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "autoscaling/v2",
+		"kind":       "HorizontalPodAutoscaler",
+		"spec": map[string]interface{}{
+			"scaleTargetRef": map[string]interface{}{"apiVersion": "apps.openshift.io/v1", "kind": "DeploymentConfig", "name": "frontend"},
+			"behavior":       map[string]interface{}{"scaleDown": map[string]interface{}{"stabilizationWindowSeconds": int64(60)}},
+		},
+	}}
+	restore := &velerov1.Restore{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{common.RestoreConvertDeploymentConfigs: "true"}}}
+	output, err := restorePlugin.Execute(&velero.RestoreItemActionExecuteInput{Item: item, Restore: restore})
+	require.NoError(t, err)
+	spec := output.UpdatedItem.UnstructuredContent()["spec"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"apiVersion": "apps/v1", "kind": "Deployment", "name": "frontend"}, spec["scaleTargetRef"])
+	assert.Contains(t, spec, "behavior")
+}
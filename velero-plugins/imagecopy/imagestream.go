@@ -13,10 +13,12 @@ import (
 	"github.com/containers/image/v5/manifest"
 	"github.com/containers/image/v5/signature"
 	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/containers/image/v5/types"
 	"github.com/go-logr/logr"
+	"github.com/opencontainers/go-digest"
 	"github.com/migtools/udistribution/pkg/image/udistribution"
 	imagev1API "github.com/openshift/api/image/v1"
-	//"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus"
 )
 
 const (
@@ -37,13 +39,39 @@ func UsePluginRegistry() bool {
 // Using struct for options clarity when specifying options
 type CopyLocalImageStreamImagesOptions struct {
 	InternalRegistryPath string
+	// InternalRegistryAliases are other hostnames references to images in the internal registry may be written with
+	InternalRegistryAliases []string
 	SrcRegistry          string
 	DestRegistry         string
 	DestNamespace        string
+	// SrcRepository, if set, is the repository in SrcRegistry images are read from by digest
+	SrcRepository        string
+	// DestRepository, if set, is the repository in DestRegistry images are copied to instead of DestNamespace/<imagestream name>
+	DestRepository       string
 	CopyOptions          *copy.Options
 	Log                  logr.Logger
+	// WarnLog, if set, receives warnings such as skipped images, which are logged to Log otherwise
+	WarnLog              logrus.FieldLogger
 	UpdateDigest         bool
+	// DigestMapping, if not nil, records source to destination digests of images whose digest changed
+	DigestMapping        map[string]string
+	// MaxImageSize, if > 0, is the size in bytes above which images are skipped
+	MaxImageSize         int64
+	// BandwidthLimit, if > 0, limits reading image blobs to the given bytes per second
+	BandwidthLimit       int64
+	// Checkpoints, if not nil, records copied images so that images copied by an earlier attempt are not copied again
+	Checkpoints          CheckpointStore
 	Ut                   *udistribution.UdistributionTransport
+	// SrcUt, if set, is the udistribution transport images are read from instead of Ut
+	SrcUt                *udistribution.UdistributionTransport
+}
+
+// CheckpointStore records which images have been copied to a destination
+type CheckpointStore interface {
+	// GetCheckpoint returns the digest the image with srcDigest was copied to at destPath, or "" if there is none
+	GetCheckpoint(srcDigest, destPath string) (string, error)
+	// PutCheckpoint records that the image with srcDigest was copied to destPath with destDigest
+	PutCheckpoint(srcDigest, destPath, destDigest string) error
 }
 
 func (o CopyLocalImageStreamImagesOptions) GetSrcRegistry() string {
@@ -58,18 +86,28 @@ func (o CopyLocalImageStreamImagesOptions) GetDestRegistry() string {
 // is: ImageStream resource that images are being copied for
 // options: CopyLocalImageStreamImagesOptions struct contains options for this function.
 //   internalRegistryPath: The internal registry path for the cluster in which is comes from, used to determine which images are local
+//   internalRegistryAliases: other hostnames of the internal registry, e.g. its route, images are also local when referenced with them
 //   srcRegistry: the registry to copy the images from
 //   destRegistry: the registry to copy the images to
 //   destNamespace: the namespace to copy to
+//   srcRepository: the repository to copy the images from instead of their path in the internal registry
+//   destRepository: the repository to copy the images to instead of destNamespace/<imagestream name>
 //   log: the logger to log to
 //   updateDigest: whether to update the input imageStream if the digest changes on pushing to the new registry
+//   digestMapping: records the digests changed on pushing to the new registry
+//   maxImageSize: size in bytes above which images are skipped and removed from the imageStream status
+//   bandwidthLimit: bytes per second to limit reading image blobs to
+//   checkpoints: the store of images already copied, images found there and at the destination are not copied again
 //   ut: the udistribution transport to use
+//   srcUt: the udistribution transport to read images from, if it differs from ut
 func CopyLocalImageStreamImages(
 	imageStream imagev1API.ImageStream,
 	o CopyLocalImageStreamImagesOptions,
 ) error {
 	localImageCopied := false
 	localImageCopiedByTag := false
+	total := o.countLocalImages(imageStream)
+	copied, resumed, skipped := 0, 0, 0
 	for tagIndex, tag := range imageStream.Status.Tags {
 		o.Log.Info(fmt.Sprintf("[imagecopy] Copying tag: %#v", tag.Tag))
 		specTag := findSpecTag(imageStream.Spec.Tags, tag.Tag)
@@ -86,7 +124,7 @@ func CopyLocalImageStreamImages(
 		// Iterate over items in reverse order so most recently tagged is copied last
 		for i := len(tag.Items) - 1; i >= 0; i-- {
 			dockerImageReference := tag.Items[i].DockerImageReference
-			if len(o.InternalRegistryPath) > 0 && strings.HasPrefix(dockerImageReference, o.InternalRegistryPath) {
+			if internalRegistryPath := o.localRegistryPath(dockerImageReference); internalRegistryPath != "" {
 				if len(o.SrcRegistry) == 0 {
 					return errors.New("copy source registry not found but ImageStream has internal images")
 				}
@@ -108,11 +146,15 @@ func CopyLocalImageStreamImages(
 				destPathRegistry := o.GetDestRegistry()
 
 				if strings.HasPrefix(srcPathRegistry, BSLRoutePrefix) {
-					if o.Ut == nil {
+					srcUt := o.Ut
+					if o.SrcUt != nil {
+						srcUt = o.SrcUt
+					}
+					if srcUt == nil {
 						return errors.New("udistribution transport not found")
 					}
-					o.Log.Info(fmt.Sprintf("[imagecopy] copying image from BSL registry: %s", o.Ut.Name()))
-					srcPath += o.Ut.Name() + "://"
+					o.Log.Info(fmt.Sprintf("[imagecopy] copying image from BSL registry: %s", srcUt.Name()))
+					srcPath += srcUt.Name() + "://"
 					srcPathRegistry = strings.TrimPrefix(srcPathRegistry, BSLRoutePrefix)
 				} else {
 					srcPath += dockerTransport
@@ -127,29 +169,75 @@ func CopyLocalImageStreamImages(
 				} else {
 					destPath += dockerTransport
 				}
-				srcPath += fmt.Sprintf("%s%s", srcPathRegistry, strings.TrimPrefix(dockerImageReference, o.InternalRegistryPath))
-				destPath += fmt.Sprintf("%s/%s/%s%s", destPathRegistry, o.DestNamespace, imageStream.Name, destTag)
+				srcImage := strings.TrimPrefix(dockerImageReference, internalRegistryPath)
+				if o.SrcRepository != "" {
+					srcImage = fmt.Sprintf("/%s@%s", o.SrcRepository, tag.Items[i].Image)
+				}
+				srcPath += fmt.Sprintf("%s%s", srcPathRegistry, srcImage)
+				destRepo := destPath + fmt.Sprintf("%s/%s/%s", destPathRegistry, o.DestNamespace, imageStream.Name)
+				if o.DestRepository != "" {
+					destRepo = destPath + fmt.Sprintf("%s/%s", destPathRegistry, o.DestRepository)
+				}
 
 				// if src or dest registry is empty (ie. when using udistribution), remove extra '/'
 				srcPath = strings.Replace(srcPath, ":///", "://", -1)
-				destPath = strings.Replace(destPath, ":///", "://", -1)
+				destRepo = strings.Replace(destRepo, ":///", "://", -1)
+				destPath = destRepo + destTag
 
 				o.Log.Info(fmt.Sprintf("[imagecopy] copying from: %s", srcPath))
 				o.Log.Info(fmt.Sprintf("[imagecopy] copying to: %s", destPath))
 
-				imgManifest, err := copyImage(o.Log, srcPath, destPath, o.CopyOptions)
-				if err != nil {
-					o.Log.Info(fmt.Sprintf("[imagecopy] Error copying image: %v", err))
-					return err
-				}
-				newDigest, err := manifest.Digest(imgManifest)
-				if err != nil {
-					o.Log.Info(fmt.Sprintf("[imagecopy] Error computing image digest for manifest: %v", err))
-					return err
+				// the most recent image of a tag is copied last and must be found by tag, older ones by digest
+				verifyTag := destTag != "" && i == 0
+				var newDigest digest.Digest
+				if checkpoint := o.checkpointedDigest(tag.Items[i].Image, destRepo, destPath, verifyTag); checkpoint != "" {
+					o.Log.Info(fmt.Sprintf("[imagecopy] image already copied to %s by an earlier attempt, skipping copy", destPath))
+					newDigest = digest.Digest(checkpoint)
+					resumed++
+				} else {
+					if o.MaxImageSize > 0 {
+						size, err := imageSize(srcPath, o.CopyOptions.SourceCtx)
+						if err != nil {
+							o.Log.Info(fmt.Sprintf("[imagecopy] Error getting image size: %v", err))
+							return err
+						}
+						if size > o.MaxImageSize {
+							o.warn(fmt.Sprintf("[imagecopy] skipping image %s, size %s (%d bytes) exceeds maximum image size %s (%d bytes)",
+								dockerImageReference, FormatBytes(size), size, FormatBytes(o.MaxImageSize), o.MaxImageSize))
+							// drop the skipped image so it is not looked for on restore
+							items := imageStream.Status.Tags[tagIndex].Items
+							imageStream.Status.Tags[tagIndex].Items = append(items[:i], items[i+1:]...)
+							skipped++
+							o.logProgress(total, copied, resumed, skipped)
+							continue
+						}
+					}
+
+					imgManifest, err := copyImage(o.Log, srcPath, destPath, o.CopyOptions, o.BandwidthLimit)
+					if err != nil {
+						o.Log.Info(fmt.Sprintf("[imagecopy] Error copying image: %v", err))
+						return err
+					}
+					newDigest, err = manifest.Digest(imgManifest)
+					if err != nil {
+						o.Log.Info(fmt.Sprintf("[imagecopy] Error computing image digest for manifest: %v", err))
+						return err
+					}
+					o.Log.V(4).Info(fmt.Sprintf("[imagecopy] manifest of copied image: %s", imgManifest))
+					if o.Checkpoints != nil {
+						if err := o.Checkpoints.PutCheckpoint(tag.Items[i].Image, destPath, string(newDigest)); err != nil {
+							// the image is copied again by a retried backup
+							o.Log.Info(fmt.Sprintf("[imagecopy] Error recording checkpoint: %v", err))
+						}
+					}
+					copied++
 				}
 				o.Log.V(4).Info(fmt.Sprintf("[imagecopy] src image digest: %s", tag.Items[i].Image))
 				if o.UpdateDigest && string(newDigest) != tag.Items[i].Image {
 					o.Log.V(4).Info(fmt.Sprintf("[imagecopy] migration registry image digest: %s", newDigest))
+					if o.DigestMapping != nil {
+						o.DigestMapping[tag.Items[i].Image] = string(newDigest)
+					}
 					imageStream.Status.Tags[tagIndex].Items[i].Image = string(newDigest)
 					digestSplit := strings.Split(dockerImageReference, "@")
 					// update sha in dockerImageRef found
@@ -158,7 +246,7 @@ func CopyLocalImageStreamImages(
 							"@" + string(newDigest)
 					}
 				}
-				o.Log.V(4).Info(fmt.Sprintf("[imagecopy] manifest of copied image: %s", imgManifest))
+				o.logProgress(total, copied, resumed, skipped)
 			}
 		}
 	}
@@ -167,7 +255,79 @@ func CopyLocalImageStreamImages(
 	return nil
 }
 
-func copyImage(log logr.Logger, src, dest string, copyOptions *copy.Options) ([]byte, error) {
+// checkpointedDigest returns the digest the image with srcDigest was copied to by an earlier attempt, or ""
+// if it has to be copied. The checkpoint is only used if the image is still found at the destination,
+// by tag at destPath if verifyTag is set or by digest in destRepo otherwise.
+func (o CopyLocalImageStreamImagesOptions) checkpointedDigest(srcDigest, destRepo, destPath string, verifyTag bool) string {
+	if o.Checkpoints == nil {
+		return ""
+	}
+	checkpoint, err := o.Checkpoints.GetCheckpoint(srcDigest, destPath)
+	if err != nil {
+		o.Log.Info(fmt.Sprintf("[imagecopy] Error reading checkpoint: %v", err))
+		return ""
+	}
+	if checkpoint == "" {
+		return ""
+	}
+	verifyPath := destRepo + "@" + checkpoint
+	if verifyTag {
+		verifyPath = destPath
+	}
+	var destCtx *types.SystemContext
+	if o.CopyOptions != nil {
+		destCtx = o.CopyOptions.DestinationCtx
+	}
+	found, err := manifestDigest(verifyPath, destCtx)
+	if err != nil || string(found) != checkpoint {
+		o.Log.V(4).Info(fmt.Sprintf("[imagecopy] checkpointed image %s not found at destination: %v", verifyPath, err))
+		return ""
+	}
+	return checkpoint
+}
+
+// warn logs msg at warning level to WarnLog, or to Log if it is not set
+func (o CopyLocalImageStreamImagesOptions) warn(msg string) {
+	if o.WarnLog != nil {
+		o.WarnLog.Warn(msg)
+		return
+	}
+	o.Log.Info("WARNING: " + msg)
+}
+
+func (o CopyLocalImageStreamImagesOptions) logProgress(total, copied, resumed, skipped int) {
+	o.Log.Info(fmt.Sprintf("[imagecopy] progress: %d/%d local images done (%d copied, %d already copied, %d skipped)",
+		copied+resumed+skipped, total, copied, resumed, skipped))
+}
+
+// countLocalImages returns the number of images of imageStream in the internal registry
+func (o CopyLocalImageStreamImagesOptions) countLocalImages(imageStream imagev1API.ImageStream) int {
+	count := 0
+	for _, tag := range imageStream.Status.Tags {
+		for _, item := range tag.Items {
+			if o.localRegistryPath(item.DockerImageReference) != "" {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// localRegistryPath returns the internal registry path or alias dockerImageReference starts with, or "" if the
+// image is not in the internal registry
+func (o CopyLocalImageStreamImagesOptions) localRegistryPath(dockerImageReference string) string {
+	if len(o.InternalRegistryPath) == 0 {
+		return ""
+	}
+	for _, path := range append([]string{o.InternalRegistryPath}, o.InternalRegistryAliases...) {
+		if len(path) > 0 && strings.HasPrefix(dockerImageReference, path) {
+			return path
+		}
+	}
+	return ""
+}
+
+func copyImage(log logr.Logger, src, dest string, copyOptions *copy.Options, bandwidthLimit int64) ([]byte, error) {
 	policyContext, err := getPolicyContext()
 	if err != nil {
 		return []byte{}, fmt.Errorf("Error loading trust policy: %v", err)
@@ -181,6 +341,9 @@ func copyImage(log logr.Logger, src, dest string, copyOptions *copy.Options) ([]
 	if err != nil {
 		return []byte{}, fmt.Errorf("Invalid destination name %s: %v", dest, err)
 	}
+	if bandwidthLimit > 0 {
+		srcRef = newThrottledReference(srcRef, bandwidthLimit)
+	}
 	// Let's retry the image copy up to 10 times
 	// Each retry will wait 5 seconds longer
 	// Let's log a warning if we encounter `blob unknown to registry`
@@ -202,6 +365,57 @@ func copyImage(log logr.Logger, src, dest string, copyOptions *copy.Options) ([]
 	return []byte{}, err
 }
 
+// imageSize returns the total size of the config and layers of an image
+func imageSize(src string, sys *types.SystemContext) (int64, error) {
+	blobs, err := imageBlobs(src, sys)
+	if err != nil {
+		return 0, err
+	}
+	var size int64
+	for _, blob := range blobs {
+		size += blob.Size
+	}
+	return size, nil
+}
+
+// imageBlobs returns the config and layers of an image
+func imageBlobs(src string, sys *types.SystemContext) ([]types.BlobInfo, error) {
+	srcRef, err := alltransports.ParseImageName(src)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid source name %s: %v", src, err)
+	}
+	img, err := srcRef.NewImage(context.Background(), sys)
+	if err != nil {
+		return nil, err
+	}
+	defer img.Close()
+	blobs := append([]types.BlobInfo{img.ConfigInfo()}, img.LayerInfos()...)
+	for _, blob := range blobs {
+		if blob.Size < 0 {
+			return nil, fmt.Errorf("unknown size of blob %s", blob.Digest)
+		}
+	}
+	return blobs, nil
+}
+
+// manifestDigest returns the digest of the manifest of an image
+func manifestDigest(src string, sys *types.SystemContext) (digest.Digest, error) {
+	srcRef, err := alltransports.ParseImageName(src)
+	if err != nil {
+		return "", fmt.Errorf("Invalid source name %s: %v", src, err)
+	}
+	imageSource, err := srcRef.NewImageSource(context.Background(), sys)
+	if err != nil {
+		return "", err
+	}
+	defer imageSource.Close()
+	imgManifest, _, err := imageSource.GetManifest(context.Background(), nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest(imgManifest)
+}
+
 func getPolicyContext() (*signature.PolicyContext, error) {
 	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
 	return signature.NewPolicyContext(policy)
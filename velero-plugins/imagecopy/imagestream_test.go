@@ -0,0 +1,36 @@
+package imagecopy
+
+import (
+	"testing"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/sirupsen/logrus"
+	logrustest "github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestWarn(t *testing.T) {
+	logger, hook := logrustest.NewNullLogger()
+	tests := []struct {
+		name      string
+		warnLog   logrus.FieldLogger
+		wantLevel logrus.Level
+		wantMsg   string
+	}{
+		{name: "warning log", warnLog: logger, wantLevel: logrus.WarnLevel, wantMsg: "skipping image"},
+		{name: "no warning log", wantLevel: logrus.InfoLevel, wantMsg: "WARNING: skipping image"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook.Reset()
+			o := CopyLocalImageStreamImagesOptions{Log: logrusr.New(logger), WarnLog: tt.warnLog}
+			o.warn("skipping image")
+			entry := hook.LastEntry()
+			if entry == nil {
+				t.Fatal("warn() logged nothing")
+			}
+			if entry.Level != tt.wantLevel || entry.Message != tt.wantMsg {
+				t.Errorf("warn() logged %q at %v, want %q at %v", entry.Message, entry.Level, tt.wantMsg, tt.wantLevel)
+			}
+		})
+	}
+}
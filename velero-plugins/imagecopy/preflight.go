@@ -0,0 +1,124 @@
+package imagecopy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/containers/image/v5/types"
+	imagev1API "github.com/openshift/api/image/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// number of largest images kept in a PreflightReport
+const preflightLargestImages = 10
+
+// ImageSize is the size of an image found by a preflight
+type ImageSize struct {
+	Reference string
+	Size      int64
+}
+
+// PreflightReport accumulates the size of the local images CopyLocalImageStreamImages would copy,
+// without copying anything. Blobs shared between images are only counted once.
+type PreflightReport struct {
+	mu           sync.Mutex
+	ImageStreams int
+	Images       int
+	// TotalBytes is the size of all unique blobs
+	TotalBytes int64
+	images     map[string]bool
+	blobs      map[string]int64
+	largest    []ImageSize
+}
+
+func NewPreflightReport() *PreflightReport {
+	return &PreflightReport{
+		images: map[string]bool{},
+		blobs:  map[string]int64{},
+	}
+}
+
+// AddLocalImageStreamImages resolves the manifests of the local images of imageStream and adds them to the report
+// internalRegistryPath: The internal registry path of the cluster, used to determine which images are local
+// internalRegistryAliases: other hostnames references to local images may be written with
+// srcRegistry: the registry to read the manifests from
+// sys: the system context for srcRegistry
+func (r *PreflightReport) AddLocalImageStreamImages(imageStream imagev1API.ImageStream, internalRegistryPath string, internalRegistryAliases []string, srcRegistry string, sys *types.SystemContext) error {
+	if len(internalRegistryPath) == 0 {
+		return nil
+	}
+	r.mu.Lock()
+	r.ImageStreams++
+	r.mu.Unlock()
+	// local images are found the same way the image copy finds them
+	o := CopyLocalImageStreamImagesOptions{InternalRegistryPath: internalRegistryPath, InternalRegistryAliases: internalRegistryAliases}
+	for _, tag := range imageStream.Status.Tags {
+		for _, item := range tag.Items {
+			localPath := o.localRegistryPath(item.DockerImageReference)
+			if localPath == "" || r.seen(item.Image) {
+				continue
+			}
+			srcPath := fmt.Sprintf("docker://%s%s", srcRegistry, strings.TrimPrefix(item.DockerImageReference, localPath))
+			blobs, err := imageBlobs(srcPath, sys)
+			if err != nil {
+				return fmt.Errorf("error resolving image %s: %v", srcPath, err)
+			}
+			r.add(item.Image, item.DockerImageReference, blobs)
+		}
+	}
+	return nil
+}
+
+func (r *PreflightReport) seen(image string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.images[image]
+}
+
+// add records an image and its blobs, image is the image digest used to skip images referenced by several tags
+func (r *PreflightReport) add(image, reference string, blobs []types.BlobInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.images[image] {
+		return
+	}
+	r.images[image] = true
+	r.Images++
+	var size int64
+	for _, blob := range blobs {
+		size += blob.Size
+		if _, found := r.blobs[blob.Digest.String()]; !found {
+			r.blobs[blob.Digest.String()] = blob.Size
+			r.TotalBytes += blob.Size
+		}
+	}
+	r.largest = append(r.largest, ImageSize{Reference: reference, Size: size})
+	sort.SliceStable(r.largest, func(i, j int) bool {
+		return r.largest[i].Size > r.largest[j].Size
+	})
+	if len(r.largest) > preflightLargestImages {
+		r.largest = r.largest[:preflightLargestImages]
+	}
+}
+
+// Largest returns the largest images added to the report, largest first
+func (r *PreflightReport) Largest() []ImageSize {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]ImageSize{}, r.largest...)
+}
+
+// Summary returns a one line summary of the report
+func (r *PreflightReport) Summary() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return fmt.Sprintf("%d images in %d imagestreams, %d unique blobs, %d bytes (%s)",
+		r.Images, r.ImageStreams, len(r.blobs), r.TotalBytes, FormatBytes(r.TotalBytes))
+}
+
+// FormatBytes returns size as a binary quantity, e.g. 512Mi
+func FormatBytes(size int64) string {
+	return resource.NewQuantity(size, resource.BinarySI).String()
+}
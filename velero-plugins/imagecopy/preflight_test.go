@@ -0,0 +1,52 @@
+package imagecopy
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/opencontainers/go-digest"
+)
+
+func TestPreflightReport(t *testing.T) {
+	r := NewPreflightReport()
+	r.add("sha256:app1", "registry/ns/app@sha256:app1", []types.BlobInfo{
+		{Digest: digest.Digest("sha256:config1"), Size: 10},
+		{Digest: digest.Digest("sha256:base"), Size: 1000},
+		{Digest: digest.Digest("sha256:layer1"), Size: 100},
+	})
+	r.add("sha256:app2", "registry/ns/app@sha256:app2", []types.BlobInfo{
+		{Digest: digest.Digest("sha256:config2"), Size: 10},
+		{Digest: digest.Digest("sha256:base"), Size: 1000},
+		{Digest: digest.Digest("sha256:layer2"), Size: 500},
+	})
+	// same image referenced by another tag
+	r.add("sha256:app1", "registry/ns/app@sha256:app1", []types.BlobInfo{
+		{Digest: digest.Digest("sha256:config1"), Size: 10},
+	})
+
+	if r.Images != 2 {
+		t.Errorf("Images = %v, want 2", r.Images)
+	}
+	if r.TotalBytes != 1620 {
+		t.Errorf("TotalBytes = %v, want 1620", r.TotalBytes)
+	}
+	largest := r.Largest()
+	if len(largest) != 2 || largest[0].Reference != "registry/ns/app@sha256:app2" || largest[0].Size != 1510 {
+		t.Errorf("Largest() = %v, want app2 (1510) first", largest)
+	}
+}
+
+func TestPreflightReportLargestIsBounded(t *testing.T) {
+	r := NewPreflightReport()
+	for i := 0; i < preflightLargestImages+5; i++ {
+		d := digest.FromString(string(rune('a' + i)))
+		r.add(d.String(), d.String(), []types.BlobInfo{{Digest: d, Size: int64(i)}})
+	}
+	largest := r.Largest()
+	if len(largest) != preflightLargestImages {
+		t.Fatalf("len(Largest()) = %v, want %v", len(largest), preflightLargestImages)
+	}
+	if largest[0].Size != int64(preflightLargestImages+4) {
+		t.Errorf("Largest()[0].Size = %v, want %v", largest[0].Size, preflightLargestImages+4)
+	}
+}
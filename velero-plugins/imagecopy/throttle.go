@@ -0,0 +1,65 @@
+package imagecopy
+
+import (
+	"context"
+	"io"
+
+	"github.com/containers/image/v5/types"
+	"golang.org/x/time/rate"
+)
+
+// throttledReference wraps an image reference so that blobs read from image sources created
+// from it are rate limited
+type throttledReference struct {
+	types.ImageReference
+	limiter *rate.Limiter
+}
+
+// newThrottledReference returns ref with blob reads limited to bytesPerSecond
+func newThrottledReference(ref types.ImageReference, bytesPerSecond int64) types.ImageReference {
+	return throttledReference{
+		ImageReference: ref,
+		limiter:        rate.NewLimiter(rate.Limit(bytesPerSecond), int(bytesPerSecond)),
+	}
+}
+
+func (r throttledReference) NewImageSource(ctx context.Context, sys *types.SystemContext) (types.ImageSource, error) {
+	src, err := r.ImageReference.NewImageSource(ctx, sys)
+	if err != nil {
+		return nil, err
+	}
+	return throttledSource{ImageSource: src, limiter: r.limiter}, nil
+}
+
+type throttledSource struct {
+	types.ImageSource
+	limiter *rate.Limiter
+}
+
+func (s throttledSource) GetBlob(ctx context.Context, info types.BlobInfo, cache types.BlobInfoCache) (io.ReadCloser, int64, error) {
+	reader, size, err := s.ImageSource.GetBlob(ctx, info, cache)
+	if err != nil {
+		return nil, size, err
+	}
+	return &throttledReader{ctx: ctx, ReadCloser: reader, limiter: s.limiter}, size, nil
+}
+
+type throttledReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// never read more than the limiter allows at once
+	if burst := r.limiter.Burst(); len(p) > burst {
+		p = p[:burst]
+	}
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		if waitErr := r.limiter.WaitN(r.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/bombsimon/logrusr/v3"
 	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/types"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
 	"github.com/migtools/udistribution/pkg/image/udistribution"
@@ -42,9 +44,33 @@ func (p *BackupPlugin) Execute(item runtime.Unstructured, backup *v1.Backup) (ru
 		annotations = make(map[string]string)
 	}
 	var ut *udistribution.UdistributionTransport
-	if backup.Labels[common.MigrationApplicationLabelKey] != common.MigrationApplicationLabelValue {
+	var externalRegistry *common.ExternalRegistry
+	var externalRepository string
+	directTransfer, err := isDirectImageTransfer(backup)
+	if err != nil {
+		return nil, nil, err
+	}
+	if directTransfer {
+		// the restore pulls the images from the source cluster registry, the backup only keeps the references
+		p.Log.Info("[is-backup] direct image transfer, images are pulled from this cluster on restore")
+		annotations[common.DirectImageTransfer] = "true"
+	} else if backup.Labels[common.MigrationApplicationLabelKey] != common.MigrationApplicationLabelValue {
+		externalRegistry, err = common.GetBackupExternalRegistry(backup)
+		if err != nil {
+			return nil, nil, err
+		}
 		// if the current workflow is not CAM(i.e B/R) then get the backup registry route and set the same on annotation to use in plugins.
-		if imagecopy.UsePluginRegistry(){
+		if externalRegistry != nil {
+			externalRepository, err = backupRepository(externalRegistry, backup.Name, imageStream.Namespace, imageStream.Name)
+			if err != nil {
+				return nil, nil, err
+			}
+			p.Log.Info(fmt.Sprintf("[is-backup] backing up images to external registry: %s/%s", externalRegistry.Registry, externalRepository))
+			annotations[common.MigrationRegistry] = externalRegistry.Registry
+			// the restore reads the images from the same location
+			annotations[common.ImageBackupRegistry] = externalRegistry.Registry
+			annotations[common.ImageBackupRepository] = externalRepository
+		} else if imagecopy.UsePluginRegistry(){
 			var err error
 			p.Log.Info(fmt.Sprintf("[is-backup] Getting UdistributionTransportForLocation(%s, namespace: %s)", backup.Spec.StorageLocation, backup.Namespace))
 			ut, err = GetUdistributionTransportForLocation(backup.GetUID(), backup.Spec.StorageLocation, backup.Namespace, p.Log)
@@ -53,6 +79,7 @@ func (p *BackupPlugin) Execute(item runtime.Unstructured, backup *v1.Backup) (ru
 				p.Log.Error(fmt.Sprintf("[is-backup] Error getting UdistributionTransportForLocation: %v", err))
 				return nil, nil, err
 			}
+			defer acquireTransport(ut)()
 			p.Log.Info(fmt.Sprintf("[is-backup] migrationRegistry: %s)", fmt.Sprintf("%s%s", imagecopy.BSLRoutePrefix,  GetUdistributionKey(backup.Spec.StorageLocation, backup.Namespace))))
 			annotations[common.MigrationRegistry] = imagecopy.BSLRoutePrefix
 		} else {
@@ -83,6 +110,16 @@ func (p *BackupPlugin) Execute(item runtime.Unstructured, backup *v1.Backup) (ru
 		return item, nil, nil
 	}
 
+	if directTransfer {
+		imageStream.Annotations = annotations
+		var out map[string]interface{}
+		objrec, _ := json.Marshal(imageStream)
+		json.Unmarshal(objrec, &out)
+		item.SetUnstructuredContent(out)
+		p.Log.Info("[is-backup] Direct image transfer; skipping image copy.")
+		return item, nil, nil
+	}
+
 	internalRegistry := annotations[common.BackupRegistryHostname]
 	migrationRegistry := annotations[common.MigrationRegistry]
 	if len(migrationRegistry) == 0 {
@@ -94,28 +131,118 @@ func (p *BackupPlugin) Execute(item runtime.Unstructured, backup *v1.Backup) (ru
 	if err != nil {
 		return nil, nil, err
 	}
-	destinationCtx, err := migrationRegistrySystemContext()
+	if isImageBackupPreflight(backup) {
+		// only report how much image data would be copied
+		report := getPreflightReport(backup.UID)
+		err = report.AddLocalImageStreamImages(imageStream, internalRegistry, common.SplitRegistryAliases(annotations[common.BackupRegistryAliases]), internalRegistry, sourceCtx)
+		if err != nil {
+			return nil, nil, err
+		}
+		logPreflightReport(p.Log, report)
+		annotations[common.ImageBackupPreflight] = "true"
+		imageStream.Annotations = annotations
+		var out map[string]interface{}
+		objrec, _ := json.Marshal(imageStream)
+		json.Unmarshal(objrec, &out)
+		item.SetUnstructuredContent(out)
+		p.Log.Info("[is-backup] Image backup preflight; skipping image copy.")
+		return item, nil, nil
+	}
+	var destinationCtx *types.SystemContext
+	if externalRegistry != nil {
+		destinationCtx, err = externalRegistrySystemContext(externalRegistry, backup.Namespace)
+	} else {
+		destinationCtx, err = migrationRegistrySystemContext()
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	tuning, err := getImageBackupTuning(backup)
+	if err != nil {
+		return nil, nil, err
+	}
+	digestMapping := map[string]string{}
+	copyOptions := imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		InternalRegistryAliases: common.SplitRegistryAliases(annotations[common.BackupRegistryAliases]),
+		SrcRegistry: internalRegistry,
+		DestRegistry: migrationRegistry,
+		DestNamespace: imageStream.Namespace,
+		DestRepository: externalRepository,
+		CopyOptions: &copy.Options{
+						SourceCtx:      sourceCtx,
+						DestinationCtx: destinationCtx,
+					},
+		Log: logrusr.New(p.Log),
+		WarnLog: p.Log,
+		UpdateDigest: true,
+		DigestMapping: digestMapping,
+		Ut: ut,
+	}
+	tuning.apply(&copyOptions)
+	encryptionSecret, err := getImageEncryptionSecret(backup.UID, backup.Namespace, backup.Annotations)
 	if err != nil {
 		return nil, nil, err
 	}
-	err = imagecopy.CopyLocalImageStreamImages(
-		imageStream,
-		imagecopy.CopyLocalImageStreamImagesOptions{
-			InternalRegistryPath: internalRegistry,
-			SrcRegistry: internalRegistry,
-			DestRegistry: migrationRegistry,
-			DestNamespace: imageStream.Namespace,
-			CopyOptions: &copy.Options{
-							SourceCtx:      sourceCtx,
-							DestinationCtx: destinationCtx,
-						},
-			Log: logrusr.New(p.Log),
-			UpdateDigest: true,
-			Ut: ut,
-		})
+	if encryptionSecret != nil {
+		p.Log.Info(fmt.Sprintf("[is-backup] encrypting image layers with keys from secret %s", encryptionSecret.Name))
+		copyOptions.CopyOptions.OciEncryptConfig, err = encryptConfig(encryptionSecret)
+		if err != nil {
+			return nil, nil, err
+		}
+		// an empty list encrypts all layers
+		copyOptions.CopyOptions.OciEncryptLayers = &[]int{}
+		annotations[common.ImageEncryption] = jweEncryption
+		imageStream.Annotations = annotations
+	}
+	var checkpoints *bslCheckpoints
+	if ut != nil {
+		// resume from images copied by an earlier attempt, images encrypted or compressed differently are kept apart
+		checkpoints = newBSLCheckpoints(ut, checkpointScope(annotations[common.ImageEncryption], tuning))
+		copyOptions.Checkpoints = checkpoints
+	}
+	err = imagecopy.CopyLocalImageStreamImages(imageStream, copyOptions)
 	if err != nil {
 		return nil, nil, err
 	}
+	if checkpoints != nil {
+		if err := checkpoints.cleanup(); err != nil {
+			p.Log.Warn(fmt.Sprintf("[is-backup] error removing image copy checkpoints: %v", err))
+		}
+	}
+	// record changed digests so workloads pinned to them can be updated on restore
+	if len(digestMapping) > 0 && (ut != nil || externalRegistry != nil) {
+		mappingUt := ut
+		if mappingUt == nil {
+			// images backed up to an external registry change digests too, their mappings are kept in the BSL
+			mappingUt, err = GetUdistributionTransportForLocation(backup.GetUID(), backup.Spec.StorageLocation, backup.Namespace, p.Log)
+			if err != nil {
+				return nil, nil, err
+			}
+			defer acquireTransport(mappingUt)()
+		}
+		p.Log.Info(fmt.Sprintf("[is-backup] recording %d changed image digests", len(digestMapping)))
+		err = putDigestMapping(mappingUt, backup.Name, imageStream.Namespace, imageStream.Name, digestMapping)
+		if err != nil {
+			return nil, nil, err
+		}
+		// restores only read the mappings of backups marked as having them
+		err = common.AnnotateBackup(backup, common.ImageDigestMappings, "true")
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if ut != nil {
+		replicas, err := getImageBackupReplicaLocations(backup)
+		if err != nil {
+			return nil, nil, err
+		}
+		if replicated := replicateImageStreamImages(imageStream, backup, replicas, ut, copyOptions, checkpoints.scope, digestMapping, p.Log); len(replicated) > 0 {
+			// the restore falls back to these when the images can not be read from the backup storage location
+			annotations[common.ImageBackupReplicas] = strings.Join(replicated, ",")
+			imageStream.Annotations = annotations
+		}
+	}
 
 	var out map[string]interface{}
 	objrec, _ := json.Marshal(imageStream)
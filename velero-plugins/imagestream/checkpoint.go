@@ -0,0 +1,88 @@
+package imagestream
+
+import (
+	"path"
+	"strconv"
+
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	"github.com/opencontainers/go-digest"
+)
+
+// checkpointRoot is the path, relative to the registry storage root in the BSL, below which the
+// plugin records the images it copied. Checkpoints are shared by all backups to the BSL so that
+// a retried backup does not copy images again, and are removed once all images of the ImageStream
+// are copied.
+const checkpointRoot = "/openshift-velero-plugin/checkpoints"
+
+// imageCheckpoint records that an image was copied to the BSL registry
+type imageCheckpoint struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	Digest      string `json:"digest"`
+}
+
+// bslCheckpoints is an imagecopy.CheckpointStore keeping checkpoints in the BSL of a udistribution transport
+type bslCheckpoints struct {
+	ut *udistribution.UdistributionTransport
+	// scope separates checkpoints of copies that produce different images, e.g. encrypted ones
+	scope string
+	// checkpoints read or written, removed by cleanup
+	paths []string
+}
+
+func newBSLCheckpoints(ut *udistribution.UdistributionTransport, scope string) *bslCheckpoints {
+	return &bslCheckpoints{ut: ut, scope: scope}
+}
+
+// checkpointScope returns the checkpoint scope of images copied with the given encryption and tuning,
+// which change the images written to the BSL
+func checkpointScope(encryption string, tuning imageBackupTuning) string {
+	if tuning.compression == nil {
+		return encryption
+	}
+	scope := encryption + "/" + tuning.compression.Name()
+	if tuning.compressionLevel != nil {
+		scope += ":" + strconv.Itoa(*tuning.compressionLevel)
+	}
+	return scope
+}
+
+// checkpointPath returns the storage path of the checkpoint of an image copied to destPath
+func checkpointPath(scope, srcDigest, destPath string) string {
+	return path.Join(checkpointRoot, digest.FromString(scope+"\n"+srcDigest+"\n"+destPath).Encoded()+".json")
+}
+
+func (c *bslCheckpoints) GetCheckpoint(srcDigest, destPath string) (string, error) {
+	checkpoint := imageCheckpoint{}
+	storagePath := checkpointPath(c.scope, srcDigest, destPath)
+	found, err := getMetadata(c.ut, storagePath, &checkpoint)
+	if err != nil || !found {
+		return "", err
+	}
+	c.paths = append(c.paths, storagePath)
+	if checkpoint.Source != srcDigest || checkpoint.Destination != destPath {
+		return "", nil
+	}
+	return checkpoint.Digest, nil
+}
+
+func (c *bslCheckpoints) PutCheckpoint(srcDigest, destPath, destDigest string) error {
+	storagePath := checkpointPath(c.scope, srcDigest, destPath)
+	c.paths = append(c.paths, storagePath)
+	return putMetadata(c.ut, storagePath, imageCheckpoint{
+		Source:      srcDigest,
+		Destination: destPath,
+		Digest:      destDigest,
+	})
+}
+
+// cleanup removes the checkpoints read or written, once the images they record are all copied
+func (c *bslCheckpoints) cleanup() error {
+	for len(c.paths) > 0 {
+		if err := deleteMetadata(c.ut, c.paths[0]); err != nil {
+			return err
+		}
+		c.paths = c.paths[1:]
+	}
+	return nil
+}
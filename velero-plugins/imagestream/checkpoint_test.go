@@ -0,0 +1,84 @@
+package imagestream
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+)
+
+func Test_checkpointPath(t *testing.T) {
+	base := checkpointPath("", "sha256:abc", "bsl://ns/app:latest")
+	if !strings.HasPrefix(base, checkpointRoot+"/") || !strings.HasSuffix(base, ".json") {
+		t.Errorf("checkpointPath() = %v, want a json object below %v", base, checkpointRoot)
+	}
+	if strings.Contains(strings.TrimPrefix(base, checkpointRoot), ":") {
+		t.Errorf("checkpointPath() = %v, want no characters invalid in storage paths", base)
+	}
+	if got := checkpointPath("", "sha256:abc", "bsl://ns/app:latest"); got != base {
+		t.Errorf("checkpointPath() = %v, want stable path %v", got, base)
+	}
+	for _, other := range [][]string{
+		{"jwe", "sha256:abc", "bsl://ns/app:latest"},
+		{"", "sha256:def", "bsl://ns/app:latest"},
+		{"", "sha256:abc", "bsl://ns/app:v1"},
+	} {
+		if got := checkpointPath(other[0], other[1], other[2]); got == base {
+			t.Errorf("checkpointPath(%v) = %v, want different path than %v", other, got, base)
+		}
+	}
+}
+
+func Test_checkpointScope(t *testing.T) {
+	zstd := compression.Zstd
+	level := 3
+	tests := []struct {
+		name       string
+		encryption string
+		tuning     imageBackupTuning
+		want       string
+	}{
+		{name: "plain"},
+		{name: "encrypted", encryption: "jwe", want: "jwe"},
+		{name: "compressed", tuning: imageBackupTuning{compression: &zstd}, want: "/zstd"},
+		{name: "compression level", encryption: "jwe", tuning: imageBackupTuning{compression: &zstd, compressionLevel: &level}, want: "jwe/zstd:3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := checkpointScope(tt.encryption, tt.tuning); got != tt.want {
+				t.Errorf("checkpointScope() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_bslCheckpointsCleanup(t *testing.T) {
+	ut, err := udistribution.NewTransportFromNewConfig("", []string{
+		RegistryStorageEnvVarKey + "=" + Filesystem,
+		RegistryStorageFilesystemRootdirectoryEnvVarKey + "=" + t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("NewTransportFromNewConfig() error = %v", err)
+	}
+	defer ut.Deregister()
+	earlier := newBSLCheckpoints(ut, "")
+	if err := earlier.PutCheckpoint("sha256:abc", "bsl://ns/app:latest", "sha256:def"); err != nil {
+		t.Fatalf("PutCheckpoint() error = %v", err)
+	}
+	checkpoints := newBSLCheckpoints(ut, "")
+	if got, err := checkpoints.GetCheckpoint("sha256:abc", "bsl://ns/app:latest"); err != nil || got != "sha256:def" {
+		t.Fatalf("GetCheckpoint() = %v, %v, want sha256:def", got, err)
+	}
+	if err := checkpoints.PutCheckpoint("sha256:123", "bsl://ns/app:v1", "sha256:456"); err != nil {
+		t.Fatalf("PutCheckpoint() error = %v", err)
+	}
+	if err := checkpoints.cleanup(); err != nil {
+		t.Fatalf("cleanup() error = %v", err)
+	}
+	for _, image := range [][]string{{"sha256:abc", "bsl://ns/app:latest"}, {"sha256:123", "bsl://ns/app:v1"}} {
+		if got, err := checkpoints.GetCheckpoint(image[0], image[1]); err != nil || got != "" {
+			t.Errorf("GetCheckpoint(%v) after cleanup = %v, %v, want none", image, got, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package imagestream
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	oadpv1alpha1 "github.com/openshift/oadp-operator/api/v1alpha1"
+	oadpCreds "github.com/openshift/oadp-operator/pkg/credentials"
+	"github.com/pkg/errors"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// registryConfigDir is the directory files generated for the registry storage drivers are written to
+var registryConfigDir = filepath.Join(os.TempDir(), "openshift-velero-plugin")
+
+// default AWS profile
+const defaultProfile = "default"
+
+// keys of cloud credentials
+const (
+	awsAccessKeyID          = "aws_access_key_id"
+	awsSecretAccessKey      = "aws_secret_access_key"
+	awsRoleARN              = "role_arn"
+	awsWebIdentityTokenFile = "web_identity_token_file"
+	azureClientID           = "AZURE_CLIENT_ID"
+	azureTenantID           = "AZURE_TENANT_ID"
+	azureClientSecret       = "AZURE_CLIENT_SECRET"
+	azureStorageAccountKey  = "AZURE_STORAGE_ACCOUNT_ACCESS_KEY"
+	gcpExternalAccount      = "external_account"
+)
+
+// bslSecretKeySelector returns the secret and key holding the credentials of a BSL,
+// inheriting OADP defaults for each provider if not set
+func bslSecretKeySelector(bsl *velerov1.BackupStorageLocation) *corev1.SecretKeySelector {
+	var secretName, secretKey string
+	if bsl.Spec.Credential != nil {
+		secretName = bsl.Spec.Credential.LocalObjectReference.Name
+		secretKey = bsl.Spec.Credential.Key
+	}
+	if secretName == "" {
+		secretName = oadpCreds.PluginSpecificFields[oadpv1alpha1.DefaultPlugin(bsl.Spec.Provider)].SecretName
+	}
+	if secretKey == "" {
+		secretKey = oadpCreds.PluginSpecificFields[oadpv1alpha1.DefaultPlugin(bsl.Spec.Provider)].PluginSecretKey
+	}
+	return &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+		Key:                  secretKey,
+	}
+}
+
+// getBslCredentials returns the content of the credentials secret of a BSL
+func getBslCredentials(bsl *velerov1.BackupStorageLocation) ([]byte, error) {
+	selector := bslSecretKeySelector(bsl)
+	if selector.Name == "" || selector.Key == "" {
+		return nil, errors.Errorf("no credential found for backupstoragelocation %s", bsl.Name)
+	}
+	data, err := getSecretKeyRefData(selector, bsl.Namespace)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get credential of backupstoragelocation %s", bsl.Name)
+	}
+	if len(data) == 0 {
+		return nil, errors.Errorf("key %s not found in secret %s", selector.Key, selector.Name)
+	}
+	return data, nil
+}
+
+// parseCredentialsINI parses an AWS style credentials or config file into its profiles.
+// Keys before the first profile belong to the default profile.
+func parseCredentialsINI(data []byte) map[string]map[string]string {
+	profiles := map[string]map[string]string{}
+	profile := defaultProfile
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			profile = strings.TrimSpace(strings.TrimPrefix(strings.Trim(line, "[]"), "profile "))
+			continue
+		}
+		key, value, found := strings.Cut(line, "=")
+		if !found {
+			continue
+		}
+		if profiles[profile] == nil {
+			profiles[profile] = map[string]string{}
+		}
+		profiles[profile][strings.TrimSpace(key)] = trimCredentialValue(value)
+	}
+	return profiles
+}
+
+// parseCredentialsEnv parses a KEY=value credentials file, such as the Azure or Alibaba Cloud
+// credentials of Velero. Section headers are ignored.
+func parseCredentialsEnv(data []byte) map[string]string {
+	values := map[string]string{}
+	for _, profile := range parseCredentialsINI(data) {
+		for key, value := range profile {
+			values[key] = value
+		}
+	}
+	return values
+}
+
+// isAWSWebIdentity returns true if an AWS profile assumes a role with a web identity token (STS)
+func isAWSWebIdentity(profile map[string]string) bool {
+	return profile[awsRoleARN] != "" && profile[awsWebIdentityTokenFile] != ""
+}
+
+// isAzureWorkloadIdentity returns true if credentials have a client and tenant id but neither a client secret
+// nor a storage account key, i.e. the identity is federated with a projected service account token
+func isAzureWorkloadIdentity(credentials map[string]string) bool {
+	return credentials[azureClientID] != "" && credentials[azureTenantID] != "" &&
+		credentials[azureClientSecret] == "" && credentials[azureStorageAccountKey] == ""
+}
+
+// validateGCPCredentials checks that the token file of a GCP workload identity federation
+// (external_account) credential is present in the plugin container
+func validateGCPCredentials(credentials []byte) error {
+	account := struct {
+		Type             string `json:"type"`
+		CredentialSource struct {
+			File string `json:"file"`
+		} `json:"credential_source"`
+	}{}
+	if err := json.Unmarshal(credentials, &account); err != nil {
+		return errors.Wrap(err, "failed to parse gcp credential")
+	}
+	if account.Type != gcpExternalAccount || account.CredentialSource.File == "" {
+		return nil
+	}
+	if _, err := os.Stat(account.CredentialSource.File); err != nil {
+		return errors.Wrapf(err, "token file of gcp workload identity federation credential not found")
+	}
+	return nil
+}
+
+func trimCredentialValue(value string) string {
+	return strings.TrimSpace(strings.Trim(strings.TrimSpace(value), `"'`))
+}
+
+// getS3CredentialsConfigPath writes the AWS shared config file for the S3 storage driver of a BSL
+// and returns its path
+func getS3CredentialsConfigPath(bsl *velerov1.BackupStorageLocation) (string, error) {
+	credentials, err := getBslCredentials(bsl)
+	if err != nil {
+		return "", err
+	}
+	return writeS3CredentialsConfig(bsl, credentials)
+}
+
+// writeS3CredentialsConfig writes an AWS shared config file with the profile of the BSL from credentials
+// as default profile and the CA certificate of the BSL as CA bundle, and returns its path.
+// A separate file is used as the storage driver always reads the default profile.
+func writeS3CredentialsConfig(bsl *velerov1.BackupStorageLocation, credentials []byte) (string, error) {
+	profile := bsl.Spec.Config[Profile]
+	if profile == "" {
+		profile = defaultProfile
+	}
+	values, found := parseCredentialsINI(credentials)[profile]
+	if !found {
+		return "", errors.Errorf("profile %s not found in credential of backupstoragelocation %s", profile, bsl.Name)
+	}
+	dir := filepath.Join(registryConfigDir, GetUdistributionKey(bsl.Name, bsl.Namespace))
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	var keys []string
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	config := &strings.Builder{}
+	fmt.Fprintf(config, "[%s]\n", defaultProfile)
+	for _, key := range keys {
+		fmt.Fprintf(config, "%s = %s\n", key, values[key])
+	}
+	if bsl.Spec.ObjectStorage != nil && len(bsl.Spec.ObjectStorage.CACert) > 0 {
+		caBundlePath := filepath.Join(dir, "ca-bundle.pem")
+		if err := os.WriteFile(caBundlePath, bsl.Spec.ObjectStorage.CACert, 0600); err != nil {
+			return "", err
+		}
+		fmt.Fprintf(config, "ca_bundle = %s\n", caBundlePath)
+	}
+	configPath := filepath.Join(dir, "credentials")
+	if err := os.WriteFile(configPath, []byte(config.String()), 0600); err != nil {
+		return "", err
+	}
+	return configPath, nil
+}
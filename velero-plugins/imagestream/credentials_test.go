@@ -0,0 +1,393 @@
+package imagestream
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_parseCredentialsINI(t *testing.T) {
+	tests := []struct {
+		name string
+		data map[string][]byte
+		want map[string]map[string]string
+	}{
+		{
+			name: "profiles",
+			data: secretData,
+			want: map[string]map[string]string{
+				testBslProfile: {"aws_access_key_id": testBslAccessKey, "aws_secret_access_key": testBslSecretAccessKey},
+				"default":      {"aws_access_key_id": testAccessKey, "aws_secret_access_key": testSecretAccessKey},
+				"test-profile": {"aws_access_key_id": testAccessKey, "aws_secret_access_key": testSecretAccessKey},
+			},
+		},
+		{
+			name: "equal sign in secret",
+			data: secretDataWithEqualInSecret,
+			want: map[string]map[string]string{
+				testBslProfile: {"aws_access_key_id": testBslAccessKey, "aws_secret_access_key": testBslSecretAccessKey + "=" + testBslSecretAccessKey},
+				"default":      {"aws_access_key_id": testAccessKey, "aws_secret_access_key": testSecretAccessKey + "=" + testSecretAccessKey},
+				"test-profile": {"aws_access_key_id": testAccessKey, "aws_secret_access_key": testSecretAccessKey + "=" + testSecretAccessKey},
+			},
+		},
+		{
+			name: "carriage returns, quotes and spaces",
+			data: secretDataWithMixedQuotesAndSpacesInSecret,
+			want: map[string]map[string]string{
+				testBslProfile: {"aws_access_key_id": testBslAccessKey, "aws_secret_access_key": testBslSecretAccessKey},
+				"default":      {"aws_access_key_id": testAccessKey, "aws_secret_access_key": testSecretAccessKey},
+				"test-profile": {"aws_access_key_id": testAccessKey, "aws_secret_access_key": testSecretAccessKey},
+			},
+		},
+		{
+			name: "keys without profile belong to default",
+			data: awsStsRegistrySecretData,
+			want: map[string]map[string]string{
+				"default": {"role_arn": "testBslRoleArn", "web_identity_token_file": "/var/run/secrets/some/path"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseCredentialsINI(tt.data["cloud"]); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseCredentialsINI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_writeS3CredentialsConfig(t *testing.T) {
+	registryConfigDir = t.TempDir()
+	bsl := &velerov1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-bsl", Namespace: "test-ns"},
+		Spec: velerov1.BackupStorageLocationSpec{
+			Provider: "noobaa",
+			StorageType: velerov1.StorageType{
+				ObjectStorage: &velerov1.ObjectStorageLocation{Bucket: "bucket", CACert: []byte("ca")},
+			},
+			Config: map[string]string{Profile: testBslProfile},
+		},
+	}
+	configPath, err := writeS3CredentialsConfig(bsl, secretData["cloud"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caBundlePath := filepath.Join(filepath.Dir(configPath), "ca-bundle.pem")
+	want := "[default]\n" +
+		"aws_access_key_id = " + testBslAccessKey + "\n" +
+		"aws_secret_access_key = " + testBslSecretAccessKey + "\n" +
+		"ca_bundle = " + caBundlePath + "\n"
+	if string(config) != want {
+		t.Errorf("writeS3CredentialsConfig() config = %q, want %q", config, want)
+	}
+	if ca, _ := os.ReadFile(caBundlePath); string(ca) != "ca" {
+		t.Errorf("writeS3CredentialsConfig() ca bundle = %q, want %q", ca, "ca")
+	}
+
+	bsl.Spec.Config[Profile] = "missing"
+	if _, err := writeS3CredentialsConfig(bsl, secretData["cloud"]); err == nil {
+		t.Errorf("writeS3CredentialsConfig() with missing profile, want error")
+	}
+}
+
+func Test_s3CompatibleRegistryEnvVars(t *testing.T) {
+	bsl := &velerov1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-bsl", Namespace: "test-ns"},
+		Spec: velerov1.BackupStorageLocationSpec{
+			Provider: "minio",
+			StorageType: velerov1.StorageType{
+				ObjectStorage: &velerov1.ObjectStorageLocation{Bucket: "bucket"},
+			},
+			Config: map[string]string{S3URL: "http://minio:9000", S3ForcePathStyle: "true"},
+		},
+	}
+	envs, err := coreV1EnvVarArrToStringArr(s3CompatibleRegistryEnvVars(bsl, "/tmp/credentials"), bsl.Namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"REGISTRY_STORAGE=s3",
+		"REGISTRY_STORAGE_S3_BUCKET=bucket",
+		"REGISTRY_STORAGE_S3_REGION=us-east-1",
+		"REGISTRY_STORAGE_S3_REGIONENDPOINT=http://minio:9000",
+		"REGISTRY_STORAGE_S3_FORCEPATHSTYLE=true",
+		"REGISTRY_STORAGE_S3_CREDENTIALSCONFIGPATH=/tmp/credentials",
+	}
+	if !reflect.DeepEqual(envs, want) {
+		t.Errorf("s3CompatibleRegistryEnvVars() = %v, want %v", envs, want)
+	}
+}
+
+func Test_ossRegistryEnvVars(t *testing.T) {
+	credentials := parseCredentialsEnv([]byte("ALIBABA_CLOUD_ACCESS_KEY_ID=" + testAccessKey + "\nALIBABA_CLOUD_ACCESS_KEY_SECRET=" + testSecretAccessKey + "\n"))
+	tests := []struct {
+		name        string
+		config      map[string]string
+		credentials map[string]string
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "region and internal network",
+			config:      map[string]string{Region: "cn-hangzhou", Network: "internal"},
+			credentials: credentials,
+			want: []string{
+				"REGISTRY_STORAGE=oss",
+				"REGISTRY_STORAGE_OSS_BUCKET=bucket",
+				"REGISTRY_STORAGE_OSS_REGION=oss-cn-hangzhou",
+				"REGISTRY_STORAGE_OSS_INTERNAL=true",
+				"REGISTRY_STORAGE_OSS_ACCESSKEYID=" + testAccessKey,
+				"REGISTRY_STORAGE_OSS_ACCESSKEYSECRET=" + testSecretAccessKey,
+			},
+		},
+		{
+			name:        "missing region",
+			config:      map[string]string{},
+			credentials: credentials,
+			wantErr:     true,
+		},
+		{
+			name:        "missing credentials",
+			config:      map[string]string{Region: "cn-hangzhou"},
+			credentials: map[string]string{},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bsl := &velerov1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-bsl", Namespace: "test-ns"},
+				Spec: velerov1.BackupStorageLocationSpec{
+					Provider:    OSSProvider,
+					StorageType: velerov1.StorageType{ObjectStorage: &velerov1.ObjectStorageLocation{Bucket: "bucket"}},
+					Config:      tt.config,
+				},
+			}
+			envVars, err := ossRegistryEnvVars(bsl, tt.credentials)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ossRegistryEnvVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			envs, err := coreV1EnvVarArrToStringArr(envVars, bsl.Namespace)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(envs, tt.want) {
+				t.Errorf("ossRegistryEnvVars() = %v, want %v", envs, tt.want)
+			}
+		})
+	}
+}
+
+func Test_getRegistryEnvVarsUnsupportedProvider(t *testing.T) {
+	bsl := &velerov1.BackupStorageLocation{
+		Spec: velerov1.BackupStorageLocationSpec{
+			Provider:    "example.com/custom",
+			StorageType: velerov1.StorageType{ObjectStorage: &velerov1.ObjectStorageLocation{Bucket: "bucket"}},
+			Credential:  &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "creds"}, Key: "cloud"},
+		},
+	}
+	if _, err := getRegistryEnvVars(bsl); err == nil || !strings.Contains(err.Error(), "example.com/custom") {
+		t.Errorf("getRegistryEnvVars() error = %v, want unsupported provider example.com/custom", err)
+	}
+}
+
+func Test_isAWSWebIdentity(t *testing.T) {
+	if !isAWSWebIdentity(parseCredentialsINI(awsStsRegistrySecretData["cloud"])[defaultProfile]) {
+		t.Errorf("isAWSWebIdentity() = false for role_arn and web_identity_token_file, want true")
+	}
+	if isAWSWebIdentity(parseCredentialsINI(secretData["cloud"])[defaultProfile]) {
+		t.Errorf("isAWSWebIdentity() = true for static keys, want false")
+	}
+}
+
+func Test_isAzureWorkloadIdentity(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{
+			name: "workload identity",
+			data: []byte("AZURE_SUBSCRIPTION_ID=" + testSubscriptionID + "\nAZURE_TENANT_ID=" + testTenantID + "\nAZURE_CLIENT_ID=" + testClientID + "\nAZURE_CLOUD_NAME=AzurePublicCloud"),
+			want: true,
+		},
+		{
+			name: "storage account key",
+			data: secretAzureData["cloud"],
+		},
+		{
+			name: "service principal",
+			data: secretAzureServicePrincipalData["cloud"],
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isAzureWorkloadIdentity(parseCredentialsEnv(tt.data)); got != tt.want {
+				t.Errorf("isAzureWorkloadIdentity() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateGCPCredentials(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		credentials string
+		wantErr     bool
+	}{
+		{
+			name:        "service account key",
+			credentials: `{"type": "service_account", "private_key": "key"}`,
+		},
+		{
+			name:        "workload identity federation",
+			credentials: `{"type": "external_account", "credential_source": {"file": "` + tokenFile + `"}}`,
+		},
+		{
+			name:        "workload identity federation without token",
+			credentials: `{"type": "external_account", "credential_source": {"file": "` + tokenFile + `-missing"}}`,
+			wantErr:     true,
+		},
+		{
+			name:        "invalid json",
+			credentials: `[default]`,
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateGCPCredentials([]byte(tt.credentials)); (err != nil) != tt.wantErr {
+				t.Errorf("validateGCPCredentials() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_awsCredentialsEnvVars(t *testing.T) {
+	tests := []struct {
+		name        string
+		profile     string
+		credentials []byte
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "default profile",
+			credentials: secretData["cloud"],
+			want:        []string{"REGISTRY_STORAGE_S3_ACCESSKEY=" + testAccessKey, "REGISTRY_STORAGE_S3_SECRETKEY=" + testSecretAccessKey},
+		},
+		{
+			name:        "bsl profile",
+			profile:     testBslProfile,
+			credentials: secretData["cloud"],
+			want:        []string{"REGISTRY_STORAGE_S3_ACCESSKEY=" + testBslAccessKey, "REGISTRY_STORAGE_S3_SECRETKEY=" + testBslSecretAccessKey},
+		},
+		{
+			name:        "missing profile",
+			profile:     testBslProfile,
+			credentials: awsSecretDataWithMissingProfile["cloud"],
+			wantErr:     true,
+		},
+		{
+			name:        "no keys",
+			credentials: awsStsRegistrySecretData["cloud"],
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bsl := &velerov1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-bsl", Namespace: "test-ns"},
+				Spec:       velerov1.BackupStorageLocationSpec{Provider: AWSProvider, Config: map[string]string{Profile: tt.profile}},
+			}
+			envVars, err := awsCredentialsEnvVars(bsl, tt.credentials)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("awsCredentialsEnvVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			envs, _ := coreV1EnvVarArrToStringArr(envVars, bsl.Namespace)
+			if !reflect.DeepEqual(envs, tt.want) {
+				t.Errorf("awsCredentialsEnvVars() = %v, want %v", envs, tt.want)
+			}
+		})
+	}
+}
+
+func Test_azureCredentialsEnvVars(t *testing.T) {
+	tests := []struct {
+		name        string
+		credentials []byte
+		want        []string
+		wantErr     bool
+	}{
+		{
+			name:        "storage account key",
+			credentials: secretAzureData["cloud"],
+			want: []string{
+				"REGISTRY_STORAGE=azure",
+				"REGISTRY_STORAGE_AZURE_CONTAINER=azure-bucket",
+				"REGISTRY_STORAGE_AZURE_ACCOUNTNAME=velero-azure-account",
+				"REGISTRY_STORAGE_AZURE_ACCOUNTKEY=" + testStoragekey,
+			},
+		},
+		{
+			name:        "service principal",
+			credentials: []byte("AZURE_TENANT_ID=" + testTenantID + "\nAZURE_CLIENT_ID=" + testClientID + "\nAZURE_CLIENT_SECRET=" + testClientSecret),
+			want: []string{
+				"REGISTRY_STORAGE=azure",
+				"REGISTRY_STORAGE_AZURE_CONTAINER=azure-bucket",
+				"REGISTRY_STORAGE_AZURE_ACCOUNTNAME=velero-azure-account",
+				"REGISTRY_STORAGE_AZURE_SPN_CLIENT_ID=" + testClientID,
+				"REGISTRY_STORAGE_AZURE_SPN_CLIENT_SECRET=" + testClientSecret,
+				"REGISTRY_STORAGE_AZURE_SPN_TENANT_ID=" + testTenantID,
+			},
+		},
+		{
+			name:        "no key",
+			credentials: []byte("AZURE_CLOUD_NAME=" + testCloudName),
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bsl := &velerov1.BackupStorageLocation{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-bsl", Namespace: "test-ns"},
+				Spec: velerov1.BackupStorageLocationSpec{
+					Provider:    AzureProvider,
+					StorageType: velerov1.StorageType{ObjectStorage: &velerov1.ObjectStorageLocation{Bucket: "azure-bucket"}},
+					Config:      map[string]string{StorageAccount: "velero-azure-account"},
+				},
+			}
+			envVars, err := azureCredentialsEnvVars(bsl, parseCredentialsEnv(tt.credentials))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("azureCredentialsEnvVars() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			envs, _ := coreV1EnvVarArrToStringArr(envVars, bsl.Namespace)
+			if !reflect.DeepEqual(envs, tt.want) {
+				t.Errorf("azureCredentialsEnvVars() = %v, want %v", envs, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package imagestream
+
+import (
+	"fmt"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// directory below the backup metadata holding the digest mapping of each ImageStream
+const digestMappingDir = "digest-mappings"
+
+// putDigestMapping records which digests of an ImageStream changed when its images were
+// copied to the BSL registry, mapping the source digest to the digest in the BSL.
+func putDigestMapping(ut *udistribution.UdistributionTransport, backupName, namespace, name string, mapping map[string]string) error {
+	return putBackupMetadata(ut, backupName, mapping, digestMappingDir, namespace, name+".json")
+}
+
+func init() {
+	common.DigestMappingReader = readBackupDigestMappings
+}
+
+// readBackupDigestMappings returns the digest mappings of all ImageStreams of a backup, read from its BSL or,
+// if that fails, from its image backup replicas which hold the same mappings
+func readBackupDigestMappings(backup *velerov1.Backup, log logrus.FieldLogger) (map[string]string, error) {
+	replicas, err := getImageBackupReplicaLocations(backup)
+	if err != nil {
+		return nil, err
+	}
+	var digestMapping map[string]string
+	for _, location := range append([]string{backup.Spec.StorageLocation}, replicas...) {
+		digestMapping, err = readDigestMappings(backup, location, log)
+		if err == nil {
+			return digestMapping, nil
+		}
+		log.Warnf("[digest-mapping] error reading digest mappings from backupstoragelocation %s: %v", location, err)
+	}
+	return nil, err
+}
+
+// readDigestMappings returns the digest mappings of all ImageStreams of a backup recorded in the BSL location
+func readDigestMappings(backup *velerov1.Backup, location string, log logrus.FieldLogger) (map[string]string, error) {
+	ut, err := GetUdistributionTransportForLocation(backup.UID, location, backup.Namespace, log)
+	if err != nil {
+		return nil, err
+	}
+	defer acquireTransport(ut)()
+	files, err := listBackupMetadata(ut, backup.Name, digestMappingDir)
+	if err != nil {
+		return nil, fmt.Errorf("errors listing digest mappings: %v", err)
+	}
+	digestMapping := map[string]string{}
+	for _, file := range files {
+		mapping := map[string]string{}
+		if _, err := getBackupMetadata(ut, backup.Name, &mapping, digestMappingDir, file); err != nil {
+			return nil, fmt.Errorf("errors reading digest mapping %s: %v", file, err)
+		}
+		for oldDigest, newDigest := range mapping {
+			digestMapping[oldDigest] = newDigest
+		}
+	}
+	return digestMapping, nil
+}
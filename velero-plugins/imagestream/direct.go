@@ -0,0 +1,85 @@
+package imagestream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// keys of the direct image transfer secret
+const (
+	DirectTransferHost     = "host"     // exposed route of the source cluster internal registry
+	DirectTransferToken    = "token"    // token of a source cluster service account allowed to pull the images
+	DirectTransferInsecure = "insecure" // optional, true to skip TLS verification for the route
+)
+
+// sourceRegistry is the internal registry of the source cluster, read through its exposed route
+type sourceRegistry struct {
+	Host     string
+	Token    string
+	Insecure bool
+}
+
+// isDirectImageTransfer returns true if the images of a backup are not copied but pulled from the
+// source cluster by the restore
+func isDirectImageTransfer(backup *velerov1.Backup) (bool, error) {
+	direct, err := common.GetPluginOption(backup.UID, backup.Namespace, backup.Annotations, common.BackupDirectImageTransfer)
+	if err != nil {
+		return false, err
+	}
+	return direct == "true", nil
+}
+
+// getDirectTransferSourceRegistry returns the source cluster registry configured for a restore, or nil if
+// images are not pulled from the source cluster
+func getDirectTransferSourceRegistry(restore *velerov1.Restore) (*sourceRegistry, error) {
+	name, err := common.GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, common.RestoreDirectImageTransferSecret)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	client, err := clients.CoreClient()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Secrets(restore.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return sourceRegistryFromSecret(secret)
+}
+
+func sourceRegistryFromSecret(secret *corev1.Secret) (*sourceRegistry, error) {
+	host := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(string(secret.Data[DirectTransferHost])), "https://"), "/")
+	token := strings.TrimSpace(string(secret.Data[DirectTransferToken]))
+	if host == "" || token == "" {
+		return nil, fmt.Errorf("secret %s/%s must have %s and %s of the source cluster registry", secret.Namespace, secret.Name, DirectTransferHost, DirectTransferToken)
+	}
+	return &sourceRegistry{
+		Host:     host,
+		Token:    token,
+		Insecure: strings.TrimSpace(string(secret.Data[DirectTransferInsecure])) == "true",
+	}, nil
+}
+
+// systemContext returns the system context pulling images from the source cluster registry with its token
+func (r *sourceRegistry) systemContext() *types.SystemContext {
+	ctx := &types.SystemContext{
+		DockerDisableDestSchema1MIMETypes: true,
+		DockerAuthConfig: &types.DockerAuthConfig{
+			Username: "ignored",
+			Password: r.Token,
+		},
+	}
+	if r.Insecure {
+		ctx.DockerDaemonInsecureSkipTLSVerify = true
+		ctx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+	}
+	return ctx
+}
@@ -0,0 +1,111 @@
+package imagestream
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_sourceRegistryFromSecret(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          map[string]string
+		want          *sourceRegistry
+		wantErrString string
+	}{
+		{
+			name: "host and token",
+			data: map[string]string{
+				DirectTransferHost:  "default-route-openshift-image-registry.apps.source.example.com",
+				DirectTransferToken: "sha256~token\n",
+			},
+			want: &sourceRegistry{
+				Host:  "default-route-openshift-image-registry.apps.source.example.com",
+				Token: "sha256~token",
+			},
+		},
+		{
+			name: "host with scheme and insecure",
+			data: map[string]string{
+				DirectTransferHost:     "https://registry.source.example.com/",
+				DirectTransferToken:    "token",
+				DirectTransferInsecure: "true",
+			},
+			want: &sourceRegistry{
+				Host:     "registry.source.example.com",
+				Token:    "token",
+				Insecure: true,
+			},
+		},
+		{
+			name: "token missing",
+			data: map[string]string{
+				DirectTransferHost: "registry.source.example.com",
+			},
+			wantErrString: "must have host and token",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "source-registry", Namespace: "openshift-adp"},
+				Data:       map[string][]byte{},
+			}
+			for key, value := range tt.data {
+				secret.Data[key] = []byte(value)
+			}
+			got, err := sourceRegistryFromSecret(secret)
+			if tt.wantErrString != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrString) {
+					t.Errorf("sourceRegistryFromSecret() error = %v, wantErrString %v", err, tt.wantErrString)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("sourceRegistryFromSecret() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sourceRegistryFromSecret() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_directImageTransfer restores the images of an ImageStream recorded with the internal registry hostname
+// of the source cluster by pulling them through the route of that registry
+func Test_directImageTransfer(t *testing.T) {
+	sourceRoute := newTestRegistry(t)
+	destinationRegistry := newTestRegistry(t)
+	backupInternalRegistry := "image-registry.openshift-image-registry.svc:5000"
+	insecureCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	imageDigest := pushTestImage(t, "docker://"+sourceRoute+"/ns1/app:latest", insecureCtx)
+	imageStream := testImageStream(backupInternalRegistry, imageDigest)
+
+	err := imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: backupInternalRegistry,
+		SrcRegistry:          sourceRoute,
+		DestRegistry:         destinationRegistry,
+		DestNamespace:        "ns2",
+		CopyOptions:          &copy.Options{SourceCtx: insecureCtx, DestinationCtx: insecureCtx},
+		Log:                  logrusr.New(test.NewLogger()),
+	})
+	if err != nil {
+		t.Fatalf("CopyLocalImageStreamImages() error = %v", err)
+	}
+	restored, err := testManifestDigest("docker://"+destinationRegistry+"/ns2/app:latest", insecureCtx)
+	if err != nil {
+		t.Fatalf("restored image not found: %v", err)
+	}
+	if restored != imageDigest {
+		t.Errorf("restored image digest = %v, want %v", restored, imageDigest)
+	}
+}
@@ -0,0 +1,75 @@
+package imagestream
+
+import (
+	"context"
+	"encoding/pem"
+	"fmt"
+
+	encconfig "github.com/containers/ocicrypt/config"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// keys of the image encryption secret
+const (
+	EncryptionPublicKey          = "publicKey"          // PEM encoded public key, used on backup
+	EncryptionPrivateKey         = "privateKey"         // PEM encoded private key, used on restore
+	EncryptionPrivateKeyPassword = "privateKeyPassword" // optional password of the private key
+)
+
+// value of the ImageEncryption annotation for images encrypted with JWE
+const jweEncryption = "jwe"
+
+// getImageEncryptionSecret returns the image encryption secret configured for a backup or
+// restore, or nil if images are not encrypted.
+func getImageEncryptionSecret(uid k8stypes.UID, namespace string, annotations map[string]string) (*corev1.Secret, error) {
+	name, err := common.GetPluginOption(uid, namespace, annotations, common.ImageEncryptionSecret)
+	if err != nil || name == "" {
+		return nil, err
+	}
+	client, err := clients.CoreClient()
+	if err != nil {
+		return nil, err
+	}
+	return client.Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+}
+
+// encryptConfig returns the ocicrypt config encrypting image layers for the public key in secret
+func encryptConfig(secret *corev1.Secret) (*encconfig.EncryptConfig, error) {
+	publicKey, err := pemKey(secret, EncryptionPublicKey)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := encconfig.EncryptWithJwe([][]byte{publicKey})
+	if err != nil {
+		return nil, err
+	}
+	return cc.EncryptConfig, nil
+}
+
+// decryptConfig returns the ocicrypt config decrypting image layers with the private key in secret
+func decryptConfig(secret *corev1.Secret) (*encconfig.DecryptConfig, error) {
+	privateKey, err := pemKey(secret, EncryptionPrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	cc, err := encconfig.DecryptWithPrivKeys([][]byte{privateKey}, [][]byte{secret.Data[EncryptionPrivateKeyPassword]})
+	if err != nil {
+		return nil, err
+	}
+	return cc.DecryptConfig, nil
+}
+
+func pemKey(secret *corev1.Secret, key string) ([]byte, error) {
+	data := secret.Data[key]
+	if len(data) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has no %s", secret.Namespace, secret.Name, key)
+	}
+	if block, _ := pem.Decode(data); block == nil {
+		return nil, fmt.Errorf("secret %s/%s: %s is not PEM encoded", secret.Namespace, secret.Name, key)
+	}
+	return data, nil
+}
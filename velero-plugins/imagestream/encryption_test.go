@@ -0,0 +1,203 @@
+package imagestream
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"testing"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_encryptionConfig(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+	privatePEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	tests := []struct {
+		name           string
+		data           map[string][]byte
+		wantEncryptErr bool
+		wantDecryptErr bool
+	}{
+		{
+			name: "both keys",
+			data: map[string][]byte{EncryptionPublicKey: publicPEM, EncryptionPrivateKey: privatePEM},
+		},
+		{
+			name:           "public key only",
+			data:           map[string][]byte{EncryptionPublicKey: publicPEM},
+			wantDecryptErr: true,
+		},
+		{
+			name:           "keys not PEM encoded",
+			data:           map[string][]byte{EncryptionPublicKey: publicKey, EncryptionPrivateKey: []byte("secret")},
+			wantEncryptErr: true,
+			wantDecryptErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Name: "image-keys", Namespace: "openshift-adp"},
+				Data:       tt.data,
+			}
+			ec, err := encryptConfig(secret)
+			if (err != nil) != tt.wantEncryptErr {
+				t.Errorf("encryptConfig() error = %v, wantErr %v", err, tt.wantEncryptErr)
+			}
+			if err == nil && len(ec.Parameters["pubkeys"]) != 1 {
+				t.Errorf("encryptConfig() pubkeys = %v, want 1", len(ec.Parameters["pubkeys"]))
+			}
+			dc, err := decryptConfig(secret)
+			if (err != nil) != tt.wantDecryptErr {
+				t.Errorf("decryptConfig() error = %v, wantErr %v", err, tt.wantDecryptErr)
+			}
+			if err == nil && len(dc.Parameters["privkeys"]) != 1 {
+				t.Errorf("decryptConfig() privkeys = %v, want 1", len(dc.Parameters["privkeys"]))
+			}
+		})
+	}
+}
+
+// Test_encryptedImageDigests backs up the images of an ImageStream encrypted and restores them decrypted, as Execute
+// does, and checks that the digest mappings recorded by both lead from the source digest to the restored digest.
+// Encryption converts Docker schema 2 images to OCI, so those are not restored with their source digest.
+func Test_encryptedImageDigests(t *testing.T) {
+	log := logrusr.New(test.NewLogger())
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "image-keys", Namespace: "openshift-adp"},
+		Data: map[string][]byte{
+			EncryptionPublicKey:  pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey}),
+			EncryptionPrivateKey: pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}),
+		},
+	}
+	ec, err := encryptConfig(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := decryptConfig(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bsl := filesystemBSL(map[string]string{
+		imageRegistryStorage:       Filesystem,
+		imageRegistryRootDirectory: t.TempDir(),
+	})
+	envVars, err := getRegistryEnvVars(bsl)
+	if err != nil {
+		t.Fatalf("getRegistryEnvVars() error = %v", err)
+	}
+	envs, err := coreV1EnvVarArrToStringArr(envVars, bsl.Namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ut, err := udistribution.NewTransportFromNewConfig("", envs)
+	if err != nil {
+		t.Fatalf("NewTransportFromNewConfig() error = %v", err)
+	}
+	defer ut.Deregister()
+
+	internalRegistry := newTestRegistry(t)
+	insecureCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	tests := []struct {
+		name             string
+		manifestMIMEType string
+		wantSourceDigest bool
+	}{
+		{
+			name:             "oci image",
+			manifestMIMEType: imgspecv1.MediaTypeImageManifest,
+			wantSourceDigest: true,
+		},
+		{
+			name:             "docker schema 2 image",
+			manifestMIMEType: manifest.DockerV2Schema2MediaType,
+		},
+	}
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			imageDigest := pushTestImageAs(t, "docker://"+internalRegistry+"/ns1/app:latest", insecureCtx, tt.manifestMIMEType)
+			imageStream := testImageStream(internalRegistry, imageDigest)
+			destNamespace := fmt.Sprintf("ns%d", i+2)
+
+			backupDigests := map[string]string{}
+			err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+				InternalRegistryPath: internalRegistry,
+				SrcRegistry:          internalRegistry,
+				DestRegistry:         imagecopy.BSLRoutePrefix,
+				DestNamespace:        imageStream.Namespace,
+				CopyOptions: &copy.Options{
+					SourceCtx:        insecureCtx,
+					DestinationCtx:   &types.SystemContext{},
+					OciEncryptConfig: ec,
+					OciEncryptLayers: &[]int{},
+				},
+				Log:           log,
+				UpdateDigest:  true,
+				DigestMapping: backupDigests,
+				Ut:            ut,
+			})
+			if err != nil {
+				t.Fatalf("backup CopyLocalImageStreamImages() error = %v", err)
+			}
+			backedUpDigest := imageStream.Status.Tags[0].Items[0].Image
+			if backedUpDigest == string(imageDigest) || backupDigests[string(imageDigest)] != backedUpDigest {
+				t.Fatalf("backup digest mapping = %v, want %v mapped to the digest of the encrypted image", backupDigests, imageDigest)
+			}
+
+			restoredDigests := map[string]string{}
+			err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+				InternalRegistryPath: internalRegistry,
+				SrcRegistry:          imagecopy.BSLRoutePrefix,
+				DestRegistry:         internalRegistry,
+				DestNamespace:        destNamespace,
+				CopyOptions:          &copy.Options{DestinationCtx: insecureCtx, OciDecryptConfig: dc},
+				Log:                  log,
+				UpdateDigest:         true,
+				DigestMapping:        restoredDigests,
+				Ut:                   ut,
+			})
+			if err != nil {
+				t.Fatalf("restore CopyLocalImageStreamImages() error = %v", err)
+			}
+			restored, err := testManifestDigest("docker://"+internalRegistry+"/"+destNamespace+"/app:latest", insecureCtx)
+			if err != nil {
+				t.Fatalf("restored image not found: %v", err)
+			}
+			if (restored == imageDigest) != tt.wantSourceDigest {
+				t.Errorf("restored image digest = %v, source digest %v", restored, imageDigest)
+			}
+			if got := restoredDigests[backupDigests[string(imageDigest)]]; got != string(restored) {
+				t.Errorf("digest mappings map source digest %v to %v, want restored digest %v", imageDigest, got, restored)
+			}
+		})
+	}
+}
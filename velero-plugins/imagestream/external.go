@@ -0,0 +1,182 @@
+package imagestream
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/containers/image/v5/docker/reference"
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	imagev1API "github.com/openshift/api/image/v1"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type dockerConfigEntry struct {
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+	Auth     string `json:"auth,omitempty"`
+}
+
+type dockerConfigJSON struct {
+	Auths map[string]dockerConfigEntry `json:"auths"`
+}
+
+// externalRegistrySystemContext returns the system context used to push to or pull from
+// an external registry, authenticated with the credentials in the referenced secret.
+func externalRegistrySystemContext(external *common.ExternalRegistry, namespace string) (*types.SystemContext, error) {
+	ctx := &types.SystemContext{
+		DockerDisableDestSchema1MIMETypes: true,
+	}
+	if external.Insecure {
+		ctx.DockerDaemonInsecureSkipTLSVerify = true
+		ctx.DockerInsecureSkipTLSVerify = types.OptionalBoolTrue
+	}
+	if external.Secret == "" {
+		return ctx, nil
+	}
+	client, err := clients.CoreClient()
+	if err != nil {
+		return nil, err
+	}
+	secret, err := client.Secrets(namespace).Get(context.Background(), external.Secret, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, ok := secret.Data[corev1API.DockerConfigJsonKey]
+	if !ok {
+		// legacy .dockercfg secrets contain the auths map only
+		legacy, ok := secret.Data[corev1API.DockerConfigKey]
+		if !ok {
+			return nil, fmt.Errorf("secret %s/%s has neither %s nor %s", namespace, external.Secret, corev1API.DockerConfigJsonKey, corev1API.DockerConfigKey)
+		}
+		data = []byte(fmt.Sprintf(`{"auths":%s}`, legacy))
+	}
+	auth, err := dockerAuthForRegistry(data, external.Registry)
+	if err != nil {
+		return nil, fmt.Errorf("secret %s/%s: %v", namespace, external.Secret, err)
+	}
+	ctx.DockerAuthConfig = auth
+	return ctx, nil
+}
+
+// dockerAuthForRegistry returns the credentials from a dockerconfigjson document that best
+// match registry. Entries are matched on the registry host and optional repository path,
+// the most specific matching entry wins.
+func dockerAuthForRegistry(data []byte, registry string) (*types.DockerAuthConfig, error) {
+	config := dockerConfigJSON{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	var match string
+	var entry dockerConfigEntry
+	for key, e := range config.Auths {
+		normalized := strings.TrimSuffix(key, "/")
+		normalized = strings.TrimPrefix(strings.TrimPrefix(normalized, "https://"), "http://")
+		if normalized != registry && !strings.HasPrefix(registry, normalized+"/") {
+			continue
+		}
+		if len(normalized) > len(match) {
+			match = normalized
+			entry = e
+		}
+	}
+	if match == "" {
+		return nil, fmt.Errorf("no credentials found for registry %s", registry)
+	}
+	if entry.Username != "" || entry.Password != "" {
+		return &types.DockerAuthConfig{Username: entry.Username, Password: entry.Password}, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth for registry %s: %v", match, err)
+	}
+	userPass := strings.SplitN(string(decoded), ":", 2)
+	if len(userPass) != 2 {
+		return nil, fmt.Errorf("invalid auth for registry %s", match)
+	}
+	return &types.DockerAuthConfig{Username: userPass[0], Password: userPass[1]}, nil
+}
+
+// updateSpecTagsForExternalRegistry points every spec tag of imageStream whose image was
+// copied to the external registry at that registry, so the restored ImageStream imports
+// the images from there instead of the non-existent internal registry. Images referenced with
+// one of the backupAliases of the internal registry are pointed at the external registry too.
+// Status is cleared since it is regenerated by the import.
+func updateSpecTagsForExternalRegistry(imageStream *imagev1API.ImageStream, backupRegistry, externalRegistry string, namespaceMapping map[string]string, backupAliases ...string) {
+	for _, tag := range imageStream.Status.Tags {
+		if len(tag.Items) == 0 {
+			continue
+		}
+		newRef := ""
+		for _, registry := range append([]string{backupRegistry}, backupAliases...) {
+			if ref, err := common.ReplaceImageRefPrefix(tag.Items[0].DockerImageReference, registry, externalRegistry, namespaceMapping); err == nil {
+				newRef = ref
+				break
+			}
+		}
+		if newRef == "" {
+			continue
+		}
+		from := &corev1API.ObjectReference{Kind: "DockerImage", Name: newRef}
+		found := false
+		for i := range imageStream.Spec.Tags {
+			if imageStream.Spec.Tags[i].Name != tag.Tag {
+				continue
+			}
+			found = true
+			// leave tags referencing other images or imagestreams alone
+			if imageStream.Spec.Tags[i].From == nil || imageStream.Spec.Tags[i].From.Kind == "ImageStreamImage" {
+				imageStream.Spec.Tags[i].From = from
+				imageStream.Spec.Tags[i].ReferencePolicy.Type = imagev1API.SourceTagReferencePolicy
+			}
+		}
+		if !found {
+			imageStream.Spec.Tags = append(imageStream.Spec.Tags, imagev1API.TagReference{
+				Name:            tag.Tag,
+				From:            from,
+				ReferencePolicy: imagev1API.TagReferencePolicy{Type: imagev1API.SourceTagReferencePolicy},
+			})
+		}
+	}
+	imageStream.Status = imagev1API.ImageStreamStatus{}
+}
+
+// default repository below the external registry images are backed up to
+const defaultBackupRepositoryTemplate = "{{.Namespace}}/{{.Name}}"
+
+// backupRepository returns the repository below the external registry the images of an ImageStream
+// are backed up to, rendered from repositoryTemplate with the backup name, namespace and name.
+func backupRepository(external *common.ExternalRegistry, backupName, namespace, name string) (string, error) {
+	repositoryTemplate := external.RepositoryTemplate
+	if repositoryTemplate == "" {
+		repositoryTemplate = defaultBackupRepositoryTemplate
+	}
+	tmpl, err := template.New("repository").Option("missingkey=error").Parse(repositoryTemplate)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: %v", common.BackupExternalRegistryRepository, repositoryTemplate, err)
+	}
+	var repository bytes.Buffer
+	err = tmpl.Execute(&repository, struct {
+		Backup    string
+		Namespace string
+		Name      string
+	}{
+		Backup:    backupName,
+		Namespace: namespace,
+		Name:      name,
+	})
+	if err != nil {
+		return "", fmt.Errorf("invalid %s %q: %v", common.BackupExternalRegistryRepository, repositoryTemplate, err)
+	}
+	if _, err := reference.ParseNamed(external.Registry + "/" + repository.String()); err != nil {
+		return "", fmt.Errorf("invalid repository %q for registry %s: %v", repository.String(), external.Registry, err)
+	}
+	return repository.String(), nil
+}
@@ -0,0 +1,224 @@
+package imagestream
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	imagev1API "github.com/openshift/api/image/v1"
+	corev1API "k8s.io/api/core/v1"
+)
+
+func Test_dockerAuthForRegistry(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		registry string
+		want     *types.DockerAuthConfig
+		wantErr  bool
+	}{
+		{
+			name:     "auth entry for host",
+			data:     `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"}}}`,
+			registry: "quay.io/myorg",
+			want:     &types.DockerAuthConfig{Username: "user", Password: "pass"},
+		},
+		{
+			name:     "username and password entry with scheme",
+			data:     `{"auths":{"https://harbor.example.com/":{"username":"robot","password":"secret"}}}`,
+			registry: "harbor.example.com",
+			want:     &types.DockerAuthConfig{Username: "robot", Password: "secret"},
+		},
+		{
+			name:     "most specific entry wins",
+			data:     `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"},"quay.io/myorg":{"username":"org","password":"orgpass"}}}`,
+			registry: "quay.io/myorg/restore",
+			want:     &types.DockerAuthConfig{Username: "org", Password: "orgpass"},
+		},
+		{
+			name:     "host prefix is not a match",
+			data:     `{"auths":{"quay.io":{"auth":"dXNlcjpwYXNz"}}}`,
+			registry: "quay.io.example.com",
+			wantErr:  true,
+		},
+		{
+			name:     "invalid auth",
+			data:     `{"auths":{"quay.io":{"auth":"bm9jb2xvbg=="}}}`,
+			registry: "quay.io",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dockerAuthForRegistry([]byte(tt.data), tt.registry)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("dockerAuthForRegistry() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dockerAuthForRegistry() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_updateSpecTagsForExternalRegistry(t *testing.T) {
+	backupRegistry := "image-registry.openshift-image-registry.svc:5000"
+	imageStream := imagev1API.ImageStream{
+		Spec: imagev1API.ImageStreamSpec{
+			Tags: []imagev1API.TagReference{
+				{Name: "external", From: &corev1API.ObjectReference{Kind: "DockerImage", Name: "docker.io/library/busybox:latest"}},
+				{Name: "pinned", From: &corev1API.ObjectReference{Kind: "ImageStreamImage", Name: "app@sha256:bbb"}},
+			},
+		},
+		Status: imagev1API.ImageStreamStatus{
+			Tags: []imagev1API.NamedTagEventList{
+				{Tag: "latest", Items: []imagev1API.TagEvent{{DockerImageReference: backupRegistry + "/ns1/app@sha256:aaa"}}},
+				{Tag: "external", Items: []imagev1API.TagEvent{{DockerImageReference: "docker.io/library/busybox@sha256:ccc"}}},
+				{Tag: "pinned", Items: []imagev1API.TagEvent{{DockerImageReference: backupRegistry + "/ns1/app@sha256:bbb"}}},
+				// pushed through the route of the internal registry
+				{Tag: "route", Items: []imagev1API.TagEvent{{DockerImageReference: "default-route-openshift-image-registry.apps.example.com/ns1/app@sha256:ddd"}}},
+			},
+		},
+	}
+	updateSpecTagsForExternalRegistry(&imageStream, backupRegistry, "quay.io/myorg", map[string]string{"ns1": "ns2"}, "default-route-openshift-image-registry.apps.example.com")
+
+	want := []imagev1API.TagReference{
+		{Name: "external", From: &corev1API.ObjectReference{Kind: "DockerImage", Name: "docker.io/library/busybox:latest"}},
+		{
+			Name:            "pinned",
+			From:            &corev1API.ObjectReference{Kind: "DockerImage", Name: "quay.io/myorg/ns2/app@sha256:bbb"},
+			ReferencePolicy: imagev1API.TagReferencePolicy{Type: imagev1API.SourceTagReferencePolicy},
+		},
+		{
+			Name:            "latest",
+			From:            &corev1API.ObjectReference{Kind: "DockerImage", Name: "quay.io/myorg/ns2/app@sha256:aaa"},
+			ReferencePolicy: imagev1API.TagReferencePolicy{Type: imagev1API.SourceTagReferencePolicy},
+		},
+		{
+			Name:            "route",
+			From:            &corev1API.ObjectReference{Kind: "DockerImage", Name: "quay.io/myorg/ns2/app@sha256:ddd"},
+			ReferencePolicy: imagev1API.TagReferencePolicy{Type: imagev1API.SourceTagReferencePolicy},
+		},
+	}
+	if !reflect.DeepEqual(imageStream.Spec.Tags, want) {
+		t.Errorf("updateSpecTagsForExternalRegistry() tags = %v, want %v", imageStream.Spec.Tags, want)
+	}
+	if len(imageStream.Status.Tags) != 0 {
+		t.Errorf("updateSpecTagsForExternalRegistry() status not cleared: %v", imageStream.Status)
+	}
+}
+
+func Test_backupRepository(t *testing.T) {
+	tests := []struct {
+		name          string
+		template      string
+		want          string
+		wantErrString string
+	}{
+		{
+			name: "default template",
+			want: "ns1/app",
+		},
+		{
+			name:     "flat repository for registries with a single level of repositories",
+			template: "{{.Backup}}-{{.Namespace}}-{{.Name}}",
+			want:     "backup1-ns1-app",
+		},
+		{
+			name:     "nested repository",
+			template: "backups/{{.Backup}}/{{.Namespace}}/{{.Name}}",
+			want:     "backups/backup1/ns1/app",
+		},
+		{
+			name:          "unknown field",
+			template:      "{{.Cluster}}/{{.Name}}",
+			wantErrString: "invalid oadp.openshift.io/backup-external-registry-repository",
+		},
+		{
+			name:          "invalid repository name",
+			template:      "{{.Namespace}}/App",
+			wantErrString: "invalid repository \"ns1/App\"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			external := &common.ExternalRegistry{Registry: "quay.io/myorg", RepositoryTemplate: tt.template}
+			got, err := backupRepository(external, "backup1", "ns1", "app")
+			if tt.wantErrString != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrString) {
+					t.Errorf("backupRepository() error = %v, wantErrString %v", err, tt.wantErrString)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("backupRepository() error = %v", err)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("backupRepository() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_externalRegistryRoundTrip backs up the images of an ImageStream to a repository of an external
+// registry and restores them from there into another namespace
+func Test_externalRegistryRoundTrip(t *testing.T) {
+	log := logrusr.New(test.NewLogger())
+	internalRegistry := newTestRegistry(t)
+	externalRegistry := newTestRegistry(t)
+	insecureCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	imageDigest := pushTestImage(t, "docker://"+internalRegistry+"/ns1/app:latest", insecureCtx)
+	imageStream := testImageStream(internalRegistry, imageDigest)
+	repository, err := backupRepository(&common.ExternalRegistry{
+		Registry:           externalRegistry,
+		RepositoryTemplate: "{{.Backup}}-{{.Namespace}}-{{.Name}}",
+	}, "backup1", imageStream.Namespace, imageStream.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		SrcRegistry:          internalRegistry,
+		DestRegistry:         externalRegistry,
+		DestNamespace:        imageStream.Namespace,
+		DestRepository:       repository,
+		CopyOptions:          &copy.Options{SourceCtx: insecureCtx, DestinationCtx: insecureCtx},
+		Log:                  log,
+		UpdateDigest:         true,
+	})
+	if err != nil {
+		t.Fatalf("backup CopyLocalImageStreamImages() error = %v", err)
+	}
+	if _, err := testManifestDigest("docker://"+externalRegistry+"/backup1-ns1-app:latest", insecureCtx); err != nil {
+		t.Errorf("image not backed up to external repository: %v", err)
+	}
+
+	err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		SrcRegistry:          externalRegistry,
+		SrcRepository:        repository,
+		DestRegistry:         internalRegistry,
+		DestNamespace:        "ns2",
+		CopyOptions:          &copy.Options{SourceCtx: insecureCtx, DestinationCtx: insecureCtx},
+		Log:                  log,
+	})
+	if err != nil {
+		t.Fatalf("restore CopyLocalImageStreamImages() error = %v", err)
+	}
+	restored, err := testManifestDigest("docker://"+internalRegistry+"/ns2/app:latest", insecureCtx)
+	if err != nil {
+		t.Fatalf("restored image not found: %v", err)
+	}
+	if restored != imageDigest {
+		t.Errorf("restored image digest = %v, want %v", restored, imageDigest)
+	}
+}
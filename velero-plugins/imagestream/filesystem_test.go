@@ -0,0 +1,358 @@
+package imagestream
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/manifest"
+	"github.com/containers/image/v5/signature"
+	"github.com/containers/image/v5/transports/alltransports"
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	"github.com/opencontainers/go-digest"
+	"github.com/opencontainers/image-spec/specs-go"
+	imgspecv1 "github.com/opencontainers/image-spec/specs-go/v1"
+	imagev1API "github.com/openshift/api/image/v1"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func filesystemBSL(config map[string]string) *velerov1.BackupStorageLocation {
+	return &velerov1.BackupStorageLocation{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test-bsl",
+			Namespace: "test-ns",
+		},
+		Spec: velerov1.BackupStorageLocationSpec{
+			Provider: "velero.io/aws",
+			StorageType: velerov1.StorageType{
+				ObjectStorage: &velerov1.ObjectStorageLocation{
+					Bucket: "test-bucket",
+				},
+			},
+			Config: config,
+		},
+	}
+}
+
+func Test_getFilesystemRegistryEnvVars(t *testing.T) {
+	rootDirectory := t.TempDir()
+	file := filepath.Join(rootDirectory, "file")
+	if err := os.WriteFile(file, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		config        map[string]string
+		pluginConfig  map[string]string
+		want          []corev1.EnvVar
+		wantErrString string
+	}{
+		{
+			name: "filesystem storage from bsl config",
+			config: map[string]string{
+				imageRegistryStorage:       Filesystem,
+				imageRegistryRootDirectory: rootDirectory,
+			},
+			want: []corev1.EnvVar{
+				{Name: RegistryStorageEnvVarKey, Value: Filesystem},
+				{Name: RegistryStorageFilesystemRootdirectoryEnvVarKey, Value: rootDirectory},
+			},
+		},
+		{
+			name: "filesystem storage from plugin config",
+			pluginConfig: map[string]string{
+				"image-registry-storage":        Filesystem,
+				"image-registry-root-directory": rootDirectory,
+			},
+			want: []corev1.EnvVar{
+				{Name: RegistryStorageEnvVarKey, Value: Filesystem},
+				{Name: RegistryStorageFilesystemRootdirectoryEnvVarKey, Value: rootDirectory},
+			},
+		},
+		{
+			name: "bsl config takes precedence over plugin config",
+			config: map[string]string{
+				imageRegistryStorage:       Filesystem,
+				imageRegistryRootDirectory: rootDirectory,
+			},
+			pluginConfig: map[string]string{
+				"image-registry-storage":        Filesystem,
+				"image-registry-root-directory": "/other",
+			},
+			want: []corev1.EnvVar{
+				{Name: RegistryStorageEnvVarKey, Value: Filesystem},
+				{Name: RegistryStorageFilesystemRootdirectoryEnvVarKey, Value: rootDirectory},
+			},
+		},
+		{
+			name: "root directory not set",
+			config: map[string]string{
+				imageRegistryStorage: Filesystem,
+			},
+			wantErrString: "imageRegistryRootDirectory not set",
+		},
+		{
+			name: "relative root directory",
+			config: map[string]string{
+				imageRegistryStorage:       Filesystem,
+				imageRegistryRootDirectory: "registry",
+			},
+			wantErrString: "is not an absolute path",
+		},
+		{
+			name: "root directory not mounted",
+			config: map[string]string{
+				imageRegistryStorage:       Filesystem,
+				imageRegistryRootDirectory: filepath.Join(rootDirectory, "missing"),
+			},
+			wantErrString: "no such file or directory",
+		},
+		{
+			name: "root directory is a file",
+			config: map[string]string{
+				imageRegistryStorage:       Filesystem,
+				imageRegistryRootDirectory: file,
+			},
+			wantErrString: "is not a directory",
+		},
+		{
+			name: "unsupported storage",
+			config: map[string]string{
+				imageRegistryStorage: "inmemory",
+			},
+			wantErrString: "unsupported imageRegistryStorage inmemory",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bsl := filesystemBSL(tt.config)
+			applyPluginRegistryStorage(bsl, tt.pluginConfig)
+			got, err := getRegistryEnvVars(bsl)
+			if tt.wantErrString != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErrString) {
+					t.Errorf("getRegistryEnvVars() error = %v, wantErrString %v", err, tt.wantErrString)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("getRegistryEnvVars() error = %v", err)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getRegistryEnvVars() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_filesystemRegistryRoundTrip backs up the images of an ImageStream from a registry served from a local
+// directory into a filesystem BSL registry and restores them into another namespace
+func Test_filesystemRegistryRoundTrip(t *testing.T) {
+	log := logrusr.New(test.NewLogger())
+	bsl := filesystemBSL(map[string]string{
+		imageRegistryStorage:       Filesystem,
+		imageRegistryRootDirectory: t.TempDir(),
+	})
+	envVars, err := getRegistryEnvVars(bsl)
+	if err != nil {
+		t.Fatalf("getRegistryEnvVars() error = %v", err)
+	}
+	envs, err := coreV1EnvVarArrToStringArr(envVars, bsl.Namespace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ut, err := udistribution.NewTransportFromNewConfig("", envs)
+	if err != nil {
+		t.Fatalf("NewTransportFromNewConfig() error = %v", err)
+	}
+	defer ut.Deregister()
+
+	internalRegistry := newTestRegistry(t)
+	insecureCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+
+	imageDigest := pushTestImage(t, "docker://"+internalRegistry+"/ns1/app:latest", insecureCtx)
+	imageStream := testImageStream(internalRegistry, imageDigest)
+
+	err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		SrcRegistry:          internalRegistry,
+		DestRegistry:         imagecopy.BSLRoutePrefix,
+		DestNamespace:        imageStream.Namespace,
+		CopyOptions:          &copy.Options{SourceCtx: insecureCtx},
+		Log:                  log,
+		Ut:                   ut,
+	})
+	if err != nil {
+		t.Fatalf("backup CopyLocalImageStreamImages() error = %v", err)
+	}
+	repository := filepath.Join(bsl.Spec.Config[imageRegistryRootDirectory], "docker/registry/v2/repositories/ns1/app")
+	if _, err := os.Stat(repository); err != nil {
+		t.Errorf("image not stored in registry root directory: %v", err)
+	}
+
+	err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		SrcRegistry:          imagecopy.BSLRoutePrefix,
+		DestRegistry:         internalRegistry,
+		DestNamespace:        "ns2",
+		CopyOptions:          &copy.Options{DestinationCtx: insecureCtx},
+		Log:                  log,
+		Ut:                   ut,
+	})
+	if err != nil {
+		t.Fatalf("restore CopyLocalImageStreamImages() error = %v", err)
+	}
+	restored, err := testManifestDigest("docker://"+internalRegistry+"/ns2/app:latest", insecureCtx)
+	if err != nil {
+		t.Fatalf("restored image not found: %v", err)
+	}
+	if restored != imageDigest {
+		t.Errorf("restored image digest = %v, want %v", restored, imageDigest)
+	}
+}
+
+// newTestRegistry serves a registry stored in a temporary directory over http and returns its host
+func newTestRegistry(t *testing.T) string {
+	ut, err := udistribution.NewTransportFromNewConfig("", []string{
+		RegistryStorageEnvVarKey + "=" + Filesystem,
+		RegistryStorageFilesystemRootdirectoryEnvVarKey + "=" + t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(ut.GetApp())
+	t.Cleanup(func() {
+		server.Close()
+		ut.Deregister()
+	})
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+// testImageStream returns an ImageStream with a latest tag pointing at an image in the internal registry
+func testImageStream(internalRegistry string, imageDigest digest.Digest) imagev1API.ImageStream {
+	return imagev1API.ImageStream{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "ns1"},
+		Status: imagev1API.ImageStreamStatus{
+			DockerImageRepository: internalRegistry + "/ns1/app",
+			Tags: []imagev1API.NamedTagEventList{
+				{
+					Tag: "latest",
+					Items: []imagev1API.TagEvent{
+						{
+							DockerImageReference: internalRegistry + "/ns1/app@" + string(imageDigest),
+							Image:                string(imageDigest),
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// pushTestImage pushes a single layer image to dest and returns its digest
+func pushTestImage(t *testing.T, dest string, sys *types.SystemContext) digest.Digest {
+	return pushTestImageAs(t, dest, sys, "")
+}
+
+// pushTestImageAs pushes the test image with the given manifest type, e.g. a Docker schema 2 manifest
+// instead of OCI
+func pushTestImageAs(t *testing.T, dest string, sys *types.SystemContext, manifestMIMEType string) digest.Digest {
+	dir := t.TempDir()
+	writeBlob := func(data []byte) imgspecv1.Descriptor {
+		d := digest.FromBytes(data)
+		if err := os.WriteFile(filepath.Join(dir, d.Encoded()), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return imgspecv1.Descriptor{Digest: d, Size: int64(len(data))}
+	}
+	var layerData bytes.Buffer
+	tw := tar.NewWriter(&layerData)
+	content := []byte("hello")
+	if err := tw.WriteHeader(&tar.Header{Name: "hello.txt", Mode: 0644, Size: int64(len(content))}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tw.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	layer := writeBlob(layerData.Bytes())
+	layer.MediaType = imgspecv1.MediaTypeImageLayer
+	configData, err := json.Marshal(imgspecv1.Image{
+		Platform: imgspecv1.Platform{Architecture: "amd64", OS: "linux"},
+		RootFS:   imgspecv1.RootFS{Type: "layers", DiffIDs: []digest.Digest{layer.Digest}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := writeBlob(configData)
+	config.MediaType = imgspecv1.MediaTypeImageConfig
+	manifestData, err := json.Marshal(imgspecv1.Manifest{
+		Versioned: specs.Versioned{SchemaVersion: 2},
+		MediaType: imgspecv1.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []imgspecv1.Descriptor{layer},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), manifestData, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy := &signature.Policy{Default: []signature.PolicyRequirement{signature.NewPRInsecureAcceptAnything()}}
+	policyContext, err := signature.NewPolicyContext(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer policyContext.Destroy()
+	srcRef, err := alltransports.ParseImageName("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	destRef, err := alltransports.ParseImageName(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pushed, err := copy.Image(context.Background(), policyContext, destRef, srcRef, &copy.Options{DestinationCtx: sys, ForceManifestMIMEType: manifestMIMEType})
+	if err != nil {
+		t.Fatalf("pushing test image: %v", err)
+	}
+	pushedDigest, err := manifest.Digest(pushed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pushedDigest
+}
+
+func testManifestDigest(src string, sys *types.SystemContext) (digest.Digest, error) {
+	ref, err := alltransports.ParseImageName(src)
+	if err != nil {
+		return "", err
+	}
+	source, err := ref.NewImageSource(context.Background(), sys)
+	if err != nil {
+		return "", err
+	}
+	defer source.Close()
+	data, _, err := source.GetManifest(context.Background(), nil)
+	if err != nil {
+		return "", err
+	}
+	return manifest.Digest(data)
+}
@@ -0,0 +1,130 @@
+package imagestream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/distribution/distribution/v3/registry/storage/driver/factory"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+)
+
+// pluginMetadataRoot is the path, relative to the registry storage root in the BSL, below
+// which the plugin keeps metadata about the images of each backup.
+const pluginMetadataRoot = "/openshift-velero-plugin/backups"
+
+var (
+	storageDrivers     = map[string]storagedriver.StorageDriver{}
+	storageDriversLock sync.Mutex
+)
+
+// getStorageDriver returns a storage driver for the object storage backing the udistribution
+// transport. Drivers are cached per transport.
+func getStorageDriver(ut *udistribution.UdistributionTransport) (storagedriver.StorageDriver, error) {
+	storageDriversLock.Lock()
+	defer storageDriversLock.Unlock()
+	if driver, ok := storageDrivers[ut.Name()]; ok {
+		return driver, nil
+	}
+	config := ut.GetApp().Config
+	driver, err := factory.Create(config.Storage.Type(), config.Storage.Parameters())
+	if err != nil {
+		return nil, fmt.Errorf("errors creating %s storage driver: %v", config.Storage.Type(), err)
+	}
+	storageDrivers[ut.Name()] = driver
+	return driver, nil
+}
+
+// dropStorageDriver removes the cached storage driver of a udistribution transport that is shut down
+func dropStorageDriver(ut *udistribution.UdistributionTransport) {
+	storageDriversLock.Lock()
+	defer storageDriversLock.Unlock()
+	delete(storageDrivers, ut.Name())
+}
+
+// backupMetadataPath returns the storage path of a metadata object for the named backup
+func backupMetadataPath(backupName string, elem ...string) string {
+	return path.Join(append([]string{pluginMetadataRoot, backupName}, elem...)...)
+}
+
+// putBackupMetadata stores v as JSON at the metadata path of the named backup
+func putBackupMetadata(ut *udistribution.UdistributionTransport, backupName string, v interface{}, elem ...string) error {
+	return putMetadata(ut, backupMetadataPath(backupName, elem...), v)
+}
+
+// getBackupMetadata reads the JSON metadata object of the named backup into v.
+// It returns false if the object does not exist.
+func getBackupMetadata(ut *udistribution.UdistributionTransport, backupName string, v interface{}, elem ...string) (bool, error) {
+	return getMetadata(ut, backupMetadataPath(backupName, elem...), v)
+}
+
+// putMetadata stores v as JSON at the given storage path
+func putMetadata(ut *udistribution.UdistributionTransport, storagePath string, v interface{}) error {
+	driver, err := getStorageDriver(ut)
+	if err != nil {
+		return err
+	}
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return driver.PutContent(context.Background(), storagePath, content)
+}
+
+// getMetadata reads the JSON object at the given storage path into v.
+// It returns false if the object does not exist.
+func getMetadata(ut *udistribution.UdistributionTransport, storagePath string, v interface{}) (bool, error) {
+	driver, err := getStorageDriver(ut)
+	if err != nil {
+		return false, err
+	}
+	content, err := driver.GetContent(context.Background(), storagePath)
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, json.Unmarshal(content, v)
+}
+
+// deleteMetadata removes the object at the given storage path, if it exists
+func deleteMetadata(ut *udistribution.UdistributionTransport, storagePath string) error {
+	driver, err := getStorageDriver(ut)
+	if err != nil {
+		return err
+	}
+	err = driver.Delete(context.Background(), storagePath)
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// listBackupMetadata returns the paths of all metadata objects below the given directory
+// of the named backup, relative to that directory.
+func listBackupMetadata(ut *udistribution.UdistributionTransport, backupName string, elem ...string) ([]string, error) {
+	driver, err := getStorageDriver(ut)
+	if err != nil {
+		return nil, err
+	}
+	dir := backupMetadataPath(backupName, elem...)
+	var files []string
+	err = driver.Walk(context.Background(), dir, func(fileInfo storagedriver.FileInfo) error {
+		if !fileInfo.IsDir() {
+			files = append(files, strings.TrimPrefix(fileInfo.Path(), dir+"/"))
+		}
+		return nil
+	})
+	if err != nil {
+		if _, ok := err.(storagedriver.PathNotFoundError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return files, nil
+}
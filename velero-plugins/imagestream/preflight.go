@@ -0,0 +1,87 @@
+package imagestream
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/sirupsen/logrus"
+	v1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// isImageBackupPreflight returns true if the backup only reports the size of its images
+func isImageBackupPreflight(backup *v1.Backup) bool {
+	return backup.Annotations[common.ImageBackupPreflight] == "true"
+}
+
+// getPreflightReport returns the preflight report accumulated for a backup UID
+func getPreflightReport(uid k8stypes.UID) *imagecopy.PreflightReport {
+	if common.BackupUidMap == nil {
+		common.BackupUidMap = make(map[k8stypes.UID]*common.CommonStruct)
+	}
+	if common.BackupUidMap[uid] == nil {
+		common.BackupUidMap[uid] = &common.CommonStruct{}
+	}
+	common.BackupUidMap[uid].JustAccessed()
+	if common.BackupUidMap[uid].ImagePreflight == nil {
+		common.BackupUidMap[uid].ImagePreflight = imagecopy.NewPreflightReport()
+	}
+	return common.BackupUidMap[uid].ImagePreflight
+}
+
+// logPreflightReport logs the running totals of a preflight report
+func logPreflightReport(log logrus.FieldLogger, report *imagecopy.PreflightReport) {
+	log.Info(fmt.Sprintf("[is-preflight] %s", report.Summary()))
+	for _, image := range report.Largest() {
+		log.Info(fmt.Sprintf("[is-preflight] %s: %s", image.Reference, imagecopy.FormatBytes(image.Size)))
+	}
+}
+
+// Preflight writes a report of the local images of the ImageStreams in namespaces that a backup would copy,
+// without copying anything. Registry overrides are read from the plugin ConfigMap in veleroNamespace. It is
+// run by the image-preflight command of the plugin binary.
+func Preflight(namespaces []string, veleroNamespace string, out io.Writer, log logrus.FieldLogger) error {
+	if len(namespaces) == 0 {
+		return fmt.Errorf("no namespaces given")
+	}
+	registryHostnames, err := common.GetRegistryHostnames(veleroNamespace, log)
+	if err != nil {
+		return err
+	}
+	internalRegistry := registryHostnames.Hostname
+	if len(internalRegistry) == 0 {
+		return fmt.Errorf("internal registry not found")
+	}
+	sourceCtx, err := internalRegistrySystemContext()
+	if err != nil {
+		return err
+	}
+	imageClient, err := clients.ImageClient()
+	if err != nil {
+		return err
+	}
+	report := imagecopy.NewPreflightReport()
+	for _, namespace := range namespaces {
+		imageStreams, err := imageClient.ImageStreams(namespace).List(context.Background(), metav1.ListOptions{})
+		if err != nil {
+			return err
+		}
+		for _, imageStream := range imageStreams.Items {
+			err = report.AddLocalImageStreamImages(imageStream, internalRegistry, registryHostnames.Aliases, internalRegistry, sourceCtx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	fmt.Fprintln(out, report.Summary())
+	fmt.Fprintln(out, "Largest images:")
+	for _, image := range report.Largest() {
+		fmt.Fprintf(out, "  %s\t%s\n", imagecopy.FormatBytes(image.Size), image.Reference)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package imagestream
+
+import (
+	"testing"
+
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+)
+
+// Test_preflightRegistryAliases reports images referenced with an alias of the internal registry hostname
+func Test_preflightRegistryAliases(t *testing.T) {
+	internalRegistry := newTestRegistry(t)
+	insecureCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	imageDigest := pushTestImage(t, "docker://"+internalRegistry+"/ns1/app:latest", insecureCtx)
+	// the image is referenced with the route of the registry
+	imageStream := testImageStream("default-route-openshift-image-registry.apps.example.com", imageDigest)
+	tests := []struct {
+		name    string
+		aliases []string
+		want    int
+	}{
+		{
+			name: "no aliases",
+		},
+		{
+			name:    "route alias",
+			aliases: []string{"default-route-openshift-image-registry.apps.example.com"},
+			want:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := imagecopy.NewPreflightReport()
+			err := report.AddLocalImageStreamImages(imageStream, "image-registry.openshift-image-registry.svc:5000", tt.aliases, internalRegistry, insecureCtx)
+			if err != nil {
+				t.Fatalf("AddLocalImageStreamImages() error = %v", err)
+			}
+			if report.Images != tt.want {
+				t.Errorf("AddLocalImageStreamImages() reported %d images, want %d", report.Images, tt.want)
+			}
+		})
+	}
+}
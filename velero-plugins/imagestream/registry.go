@@ -1,13 +1,19 @@
 package imagestream
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
-	oadpv1alpha1 "github.com/openshift/oadp-operator/api/v1alpha1"
-	oadpCreds "github.com/openshift/oadp-operator/pkg/credentials"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
 	"github.com/pkg/errors"
 	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // Registry Env var keys
@@ -22,6 +28,7 @@ const (
 	RegistryStorageS3RegionendpointEnvVarKey        = "REGISTRY_STORAGE_S3_REGIONENDPOINT"
 	RegistryStorageS3RootdirectoryEnvVarKey         = "REGISTRY_STORAGE_S3_ROOTDIRECTORY"
 	RegistryStorageS3SkipverifyEnvVarKey            = "REGISTRY_STORAGE_S3_SKIPVERIFY"
+	RegistryStorageS3ForcepathstyleEnvVarKey        = "REGISTRY_STORAGE_S3_FORCEPATHSTYLE"
 	// Azure registry env vars
 	RegistryStorageAzureContainerEnvVarKey       = "REGISTRY_STORAGE_AZURE_CONTAINER"
 	RegistryStorageAzureAccountnameEnvVarKey     = "REGISTRY_STORAGE_AZURE_ACCOUNTNAME"
@@ -34,6 +41,15 @@ const (
 	RegistryStorageGCSBucket        = "REGISTRY_STORAGE_GCS_BUCKET"
 	RegistryStorageGCSKeyfile       = "REGISTRY_STORAGE_GCS_KEYFILE"
 	RegistryStorageGCSRootdirectory = "REGISTRY_STORAGE_GCS_ROOTDIRECTORY"
+	// Alibaba Cloud OSS registry env vars
+	RegistryStorageOSSAccesskeyidEnvVarKey     = "REGISTRY_STORAGE_OSS_ACCESSKEYID"
+	RegistryStorageOSSAccesskeysecretEnvVarKey = "REGISTRY_STORAGE_OSS_ACCESSKEYSECRET"
+	RegistryStorageOSSRegionEnvVarKey          = "REGISTRY_STORAGE_OSS_REGION"
+	RegistryStorageOSSBucketEnvVarKey          = "REGISTRY_STORAGE_OSS_BUCKET"
+	RegistryStorageOSSEndpointEnvVarKey        = "REGISTRY_STORAGE_OSS_ENDPOINT"
+	RegistryStorageOSSInternalEnvVarKey        = "REGISTRY_STORAGE_OSS_INTERNAL"
+	// filesystem registry env vars
+	RegistryStorageFilesystemRootdirectoryEnvVarKey = "REGISTRY_STORAGE_FILESYSTEM_ROOTDIRECTORY"
 )
 
 // provider specific object storage config
@@ -41,9 +57,12 @@ const (
 	S3                    = "s3"
 	Azure                 = "azure"
 	GCS                   = "gcs"
+	OSS                   = "oss"
+	Filesystem            = "filesystem"
 	AWSProvider           = "aws"
 	AzureProvider         = "azure"
 	GCPProvider           = "gcp"
+	OSSProvider           = "alibabacloud"
 	Region                = "region"
 	Profile               = "profile"
 	S3URL                 = "s3Url"
@@ -52,6 +71,21 @@ const (
 	StorageAccount        = "storageAccount"
 	ResourceGroup         = "resourceGroup"
 	enableSharedConfig    = "enableSharedConfig"
+	Endpoint              = "endpoint"
+	Network               = "network"
+	// config key naming the credential key holding the storage account key
+	storageAccountKeyEnvVar = "storageAccountKeyEnvVar"
+	// default region for S3 compatible storage, the storage driver requires one
+	defaultS3CompatibleRegion = "us-east-1"
+	// config keys storing the images of the BSL in a directory instead of its object storage
+	imageRegistryStorage       = "imageRegistryStorage"
+	imageRegistryRootDirectory = "imageRegistryRootDirectory"
+)
+
+// Alibaba Cloud credential keys of the velero plugin for alibabacloud
+const (
+	alibabaCloudAccessKeyID     = "ALIBABA_CLOUD_ACCESS_KEY_ID"
+	alibabaCloudAccessKeySecret = "ALIBABA_CLOUD_ACCESS_KEY_SECRET"
 )
 
 // velero constants
@@ -101,7 +135,14 @@ var cloudProviderEnvVarMap = map[string][]corev1.EnvVar{
 
 func getRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar, error) {
 	var envVars []corev1.EnvVar
-	provider := bsl.Spec.Provider
+	switch bsl.Spec.Config[imageRegistryStorage] {
+	case "":
+	case Filesystem:
+		return getFilesystemRegistryEnvVars(bsl)
+	default:
+		return nil, errors.Errorf("unsupported %s %s", imageRegistryStorage, bsl.Spec.Config[imageRegistryStorage])
+	}
+	provider := strings.TrimPrefix(bsl.Spec.Provider, "velero.io/")
 	var err error
 	switch provider {
 	case AWSProvider:
@@ -112,8 +153,15 @@ func getRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar, e
 
 	case GCPProvider:
 		envVars, err = getGCPRegistryEnvVars(bsl)
+
+	case OSSProvider:
+		envVars, err = getOSSRegistryEnvVars(bsl)
 	default:
-		return nil, errors.New("unsupported provider")
+		// any other provider storing to an S3 compatible endpoint, e.g. MinIO, Ceph RGW or NooBaa
+		if bsl.Spec.Config[S3URL] == "" {
+			return nil, errors.Errorf("unsupported provider %s", bsl.Spec.Provider)
+		}
+		envVars, err = getS3CompatibleRegistryEnvVars(bsl)
 	}
 	if err != nil {
 		return nil, err
@@ -161,19 +209,48 @@ func getAWSRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar
 			Value: bsl.Spec.Config[InsecureSkipTLSVerify],
 		},
 	}
-	// if credential is sts, then add sts specific env vars
-	if bsl.Spec.Config[enableSharedConfig] == "true" {
+	if bsl.Spec.Config[S3ForcePathStyle] != "" {
+		awsEnvs = append(awsEnvs, corev1.EnvVar{
+			Name:  RegistryStorageS3ForcepathstyleEnvVarKey,
+			Value: bsl.Spec.Config[S3ForcePathStyle],
+		})
+	}
+	if needsS3CredentialsConfig(bsl) {
+		configPath, err := getS3CredentialsConfigPath(bsl)
+		if err != nil {
+			return nil, err
+		}
+		awsEnvs = append(awsEnvs, corev1.EnvVar{
+			Name:  RegistryStorageS3CredentialsConfigPathEnvVarKey,
+			Value: configPath,
+		})
+	} else if bsl.Spec.Config[enableSharedConfig] == "true" {
+		// if credential is sts, then add sts specific env vars
 		awsEnvs = append(awsEnvs, corev1.EnvVar{
 			Name:  RegistryStorageS3CredentialsConfigPathEnvVarKey,
 			Value: getBslSecretPath(bsl),
 		})
 	} else {
+		if credentials, err := getBslCredentials(bsl); err == nil {
+			hasSecret, err := hasRegistrySecret(bsl)
+			if err != nil {
+				return nil, err
+			}
+			if !hasSecret {
+				// not installed by OADP, read the keys from the BSL credential
+				keyEnvs, err := awsCredentialsEnvVars(bsl, credentials)
+				if err != nil {
+					return nil, err
+				}
+				return append(awsEnvs, keyEnvs...), nil
+			}
+		}
 		awsEnvs = append(awsEnvs,
 			corev1.EnvVar{
 				Name: RegistryStorageS3AccesskeyEnvVarKey,
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
-						LocalObjectReference: corev1.LocalObjectReference{Name: "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"},
+						LocalObjectReference: corev1.LocalObjectReference{Name: registrySecretName(bsl)},
 						Key:                  "access_key",
 					},
 				},
@@ -182,7 +259,7 @@ func getAWSRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar
 				Name: RegistryStorageS3SecretkeyEnvVarKey,
 				ValueFrom: &corev1.EnvVarSource{
 					SecretKeyRef: &corev1.SecretKeySelector{
-						LocalObjectReference: corev1.LocalObjectReference{Name: "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"},
+						LocalObjectReference: corev1.LocalObjectReference{Name: registrySecretName(bsl)},
 						Key:                  "secret_key",
 					},
 				},
@@ -197,25 +274,52 @@ func getAWSRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar
 // https://github.com/vmware-tanzu/velero/blob/5afe837f76aea4dd59b1bf2792e7802d4966f0a7/internal/credentials/file_store.go#L72
 // This file is written by velero server on startup
 func getBslSecretPath(bsl *velerov1.BackupStorageLocation) string {
-	var secretName, secretKey string
-	if bsl.Spec.Credential != nil {
-		secretName = bsl.Spec.Credential.LocalObjectReference.Name
-		secretKey = bsl.Spec.Credential.Key
-	}
 	// if secretName or secretKey is not set, inherit from OADP defaults for each provider
-	if bsl.Spec.Credential == nil || secretName == "" {
-		secretName = oadpCreds.PluginSpecificFields[oadpv1alpha1.DefaultPlugin(bsl.Spec.Provider)].SecretName
+	selector := bslSecretKeySelector(bsl)
+	return fmt.Sprintf("%s/%s/%s-%s", defaultCredentialsDirectory, bsl.Namespace, selector.Name, selector.Key)
+}
+
+// needsS3CredentialsConfig returns true if the credentials of an aws BSL have to be passed to the storage driver
+// in a generated shared config file, for a custom CA bundle or for STS web identity credentials
+func needsS3CredentialsConfig(bsl *velerov1.BackupStorageLocation) bool {
+	if bsl.Spec.ObjectStorage != nil && len(bsl.Spec.ObjectStorage.CACert) > 0 {
+		return true
+	}
+	if bsl.Spec.Config[enableSharedConfig] == "true" {
+		// the credentials file written by velero is used as is
+		return false
+	}
+	credentials, err := getBslCredentials(bsl)
+	if err != nil {
+		// static keys are read from the registry secret
+		return false
 	}
-	if bsl.Spec.Credential == nil || secretKey == "" {
-		secretKey = oadpCreds.PluginSpecificFields[oadpv1alpha1.DefaultPlugin(bsl.Spec.Provider)].PluginSecretKey
+	profile := bsl.Spec.Config[Profile]
+	if profile == "" {
+		profile = defaultProfile
 	}
-	return fmt.Sprintf("%s/%s/%s-%s", defaultCredentialsDirectory, bsl.Namespace, secretName, secretKey)
+	return isAWSWebIdentity(parseCredentialsINI(credentials)[profile])
 }
 
 func getAzureRegistryEnvVars(bsl *velerov1.BackupStorageLocation, azureEnvVars []corev1.EnvVar) ([]corev1.EnvVar, error) {
 	if bsl.Spec.Config == nil {
 		bsl.Spec.Config = make(map[string]string)
 	}
+	if credentials, err := getBslCredentials(bsl); err == nil {
+		if isAzureWorkloadIdentity(parseCredentialsEnv(credentials)) {
+			// the storage driver only takes workload identity from the environment of the plugin process,
+			// which is shared by the storage drivers of all BSLs
+			return nil, errors.Errorf("backupstoragelocation %s uses Azure workload identity, which is not supported for image backup; use a credential with a storage account key or client secret", bsl.Name)
+		}
+		hasSecret, err := hasRegistrySecret(bsl)
+		if err != nil {
+			return nil, err
+		}
+		if !hasSecret {
+			// not installed by OADP, read the keys from the BSL credential
+			return azureCredentialsEnvVars(bsl, parseCredentialsEnv(credentials))
+		}
+	}
 	for i := range azureEnvVars {
 		if azureEnvVars[i].Name == RegistryStorageAzureContainerEnvVarKey {
 			azureEnvVars[i].Value = bsl.Spec.StorageType.ObjectStorage.Bucket
@@ -228,7 +332,7 @@ func getAzureRegistryEnvVars(bsl *velerov1.BackupStorageLocation, azureEnvVars [
 		if azureEnvVars[i].Name == RegistryStorageAzureAccountkeyEnvVarKey {
 			azureEnvVars[i].ValueFrom = &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"},
+					LocalObjectReference: corev1.LocalObjectReference{Name: registrySecretName(bsl)},
 					Key:                  "storage_account_key",
 				},
 			}
@@ -236,7 +340,7 @@ func getAzureRegistryEnvVars(bsl *velerov1.BackupStorageLocation, azureEnvVars [
 		if azureEnvVars[i].Name == RegistryStorageAzureSPNClientIDEnvVarKey {
 			azureEnvVars[i].ValueFrom = &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"},
+					LocalObjectReference: corev1.LocalObjectReference{Name: registrySecretName(bsl)},
 					Key:                  "client_id_key",
 				},
 			}
@@ -245,7 +349,7 @@ func getAzureRegistryEnvVars(bsl *velerov1.BackupStorageLocation, azureEnvVars [
 		if azureEnvVars[i].Name == RegistryStorageAzureSPNClientSecretEnvVarKey {
 			azureEnvVars[i].ValueFrom = &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"},
+					LocalObjectReference: corev1.LocalObjectReference{Name: registrySecretName(bsl)},
 					Key:                  "client_secret_key",
 				},
 			}
@@ -253,7 +357,7 @@ func getAzureRegistryEnvVars(bsl *velerov1.BackupStorageLocation, azureEnvVars [
 		if azureEnvVars[i].Name == RegistryStorageAzureSPNTenantIDEnvVarKey {
 			azureEnvVars[i].ValueFrom = &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
-					LocalObjectReference: corev1.LocalObjectReference{Name: "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"},
+					LocalObjectReference: corev1.LocalObjectReference{Name: registrySecretName(bsl)},
 					Key:                  "tenant_id_key",
 				},
 			}
@@ -262,7 +366,98 @@ func getAzureRegistryEnvVars(bsl *velerov1.BackupStorageLocation, azureEnvVars [
 	return azureEnvVars, nil
 }
 
+// registrySecretName returns the name of the secret with registry credentials the OADP operator creates for a BSL
+func registrySecretName(bsl *velerov1.BackupStorageLocation) string {
+	return "oadp-" + bsl.Name + "-" + bsl.Spec.Provider + "-registry-secret"
+}
+
+// hasRegistrySecret returns true if the OADP operator created a registry secret for the BSL
+func hasRegistrySecret(bsl *velerov1.BackupStorageLocation) (bool, error) {
+	client, err := clients.CoreClient()
+	if err != nil {
+		return false, err
+	}
+	_, err = client.Secrets(bsl.Namespace).Get(context.Background(), registrySecretName(bsl), metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// awsCredentialsEnvVars returns the key env vars for the profile of the BSL in an AWS credentials file
+func awsCredentialsEnvVars(bsl *velerov1.BackupStorageLocation, credentials []byte) ([]corev1.EnvVar, error) {
+	profile := bsl.Spec.Config[Profile]
+	if profile == "" {
+		profile = defaultProfile
+	}
+	values, found := parseCredentialsINI(credentials)[profile]
+	if !found {
+		return nil, errors.Errorf("profile %s not found in credential of backupstoragelocation %s", profile, bsl.Name)
+	}
+	if values[awsAccessKeyID] == "" || values[awsSecretAccessKey] == "" {
+		return nil, errors.Errorf("%s and %s not found in profile %s of backupstoragelocation %s", awsAccessKeyID, awsSecretAccessKey, profile, bsl.Name)
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  RegistryStorageS3AccesskeyEnvVarKey,
+			Value: values[awsAccessKeyID],
+		},
+		{
+			Name:  RegistryStorageS3SecretkeyEnvVarKey,
+			Value: values[awsSecretAccessKey],
+		},
+	}, nil
+}
+
+// azureCredentialsEnvVars returns the registry env vars for an Azure credentials file with a storage account
+// key or service principal
+func azureCredentialsEnvVars(bsl *velerov1.BackupStorageLocation, credentials map[string]string) ([]corev1.EnvVar, error) {
+	accountKeyEnvVar := bsl.Spec.Config[storageAccountKeyEnvVar]
+	if accountKeyEnvVar == "" {
+		accountKeyEnvVar = azureStorageAccountKey
+	}
+	if credentials[accountKeyEnvVar] == "" && credentials[azureClientSecret] == "" {
+		return nil, errors.Errorf("neither %s nor %s found in credential of backupstoragelocation %s", accountKeyEnvVar, azureClientSecret, bsl.Name)
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  RegistryStorageEnvVarKey,
+			Value: Azure,
+		},
+		{
+			Name:  RegistryStorageAzureContainerEnvVarKey,
+			Value: bsl.Spec.StorageType.ObjectStorage.Bucket,
+		},
+		{
+			Name:  RegistryStorageAzureAccountnameEnvVarKey,
+			Value: bsl.Spec.Config[StorageAccount],
+		},
+		{
+			Name:  RegistryStorageAzureAccountkeyEnvVarKey,
+			Value: credentials[accountKeyEnvVar],
+		},
+		{
+			Name:  RegistryStorageAzureSPNClientIDEnvVarKey,
+			Value: credentials[azureClientID],
+		},
+		{
+			Name:  RegistryStorageAzureSPNClientSecretEnvVarKey,
+			Value: credentials[azureClientSecret],
+		},
+		{
+			Name:  RegistryStorageAzureSPNTenantIDEnvVarKey,
+			Value: credentials[azureTenantID],
+		},
+	}, nil
+}
+
 func getGCPRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar, error) {
+	// workload identity federation credentials are read from the keyfile like service account keys
+	if credentials, err := getBslCredentials(bsl); err == nil {
+		if err := validateGCPCredentials(credentials); err != nil {
+			return nil, err
+		}
+	}
 	gcpEnvVars := []corev1.EnvVar{
 		{
 			Name:  RegistryStorageEnvVarKey,
@@ -279,3 +474,154 @@ func getGCPRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar
 	}
 	return gcpEnvVars, nil
 }
+
+// getS3CompatibleRegistryEnvVars returns the registry env vars for a BSL of a provider storing to the
+// S3 compatible endpoint in s3Url. Its credential is expected in the AWS credentials file format.
+func getS3CompatibleRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar, error) {
+	configPath, err := getS3CredentialsConfigPath(bsl)
+	if err != nil {
+		return nil, err
+	}
+	return s3CompatibleRegistryEnvVars(bsl, configPath), nil
+}
+
+func s3CompatibleRegistryEnvVars(bsl *velerov1.BackupStorageLocation, configPath string) []corev1.EnvVar {
+	region := bsl.Spec.Config[Region]
+	if region == "" {
+		region = defaultS3CompatibleRegion
+	}
+	envVars := []corev1.EnvVar{
+		{
+			Name:  RegistryStorageEnvVarKey,
+			Value: S3,
+		},
+		{
+			Name:  RegistryStorageS3BucketEnvVarKey,
+			Value: bsl.Spec.StorageType.ObjectStorage.Bucket,
+		},
+		{
+			Name:  RegistryStorageS3RegionEnvVarKey,
+			Value: region,
+		},
+		{
+			Name:  RegistryStorageS3RegionendpointEnvVarKey,
+			Value: bsl.Spec.Config[S3URL],
+		},
+		{
+			Name:  RegistryStorageS3SkipverifyEnvVarKey,
+			Value: bsl.Spec.Config[InsecureSkipTLSVerify],
+		},
+		{
+			Name:  RegistryStorageS3ForcepathstyleEnvVarKey,
+			Value: bsl.Spec.Config[S3ForcePathStyle],
+		},
+		{
+			Name:  RegistryStorageS3CredentialsConfigPathEnvVarKey,
+			Value: configPath,
+		},
+	}
+	return envVars
+}
+
+func getOSSRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar, error) {
+	credentials, err := getBslCredentials(bsl)
+	if err != nil {
+		return nil, err
+	}
+	return ossRegistryEnvVars(bsl, parseCredentialsEnv(credentials))
+}
+
+func ossRegistryEnvVars(bsl *velerov1.BackupStorageLocation, credentials map[string]string) ([]corev1.EnvVar, error) {
+	region := bsl.Spec.Config[Region]
+	if region == "" {
+		return nil, errors.New("region not found in backupstoragelocation spec")
+	}
+	// velero uses region ids (cn-hangzhou), the storage driver OSS regions (oss-cn-hangzhou)
+	if !strings.HasPrefix(region, "oss-") {
+		region = "oss-" + region
+	}
+	if credentials[alibabaCloudAccessKeyID] == "" || credentials[alibabaCloudAccessKeySecret] == "" {
+		return nil, errors.Errorf("%s and %s not found in credential of backupstoragelocation %s", alibabaCloudAccessKeyID, alibabaCloudAccessKeySecret, bsl.Name)
+	}
+	internal := ""
+	if bsl.Spec.Config[Network] == "internal" {
+		internal = "true"
+	}
+	ossEnvVars := []corev1.EnvVar{
+		{
+			Name:  RegistryStorageEnvVarKey,
+			Value: OSS,
+		},
+		{
+			Name:  RegistryStorageOSSBucketEnvVarKey,
+			Value: bsl.Spec.StorageType.ObjectStorage.Bucket,
+		},
+		{
+			Name:  RegistryStorageOSSRegionEnvVarKey,
+			Value: region,
+		},
+		{
+			Name:  RegistryStorageOSSEndpointEnvVarKey,
+			Value: bsl.Spec.Config[Endpoint],
+		},
+		{
+			Name:  RegistryStorageOSSInternalEnvVarKey,
+			Value: internal,
+		},
+		{
+			Name:  RegistryStorageOSSAccesskeyidEnvVarKey,
+			Value: credentials[alibabaCloudAccessKeyID],
+		},
+		{
+			Name:  RegistryStorageOSSAccesskeysecretEnvVarKey,
+			Value: credentials[alibabaCloudAccessKeySecret],
+		},
+	}
+	return ossEnvVars, nil
+}
+
+// applyPluginRegistryStorage sets the registry storage of the plugin ConfigMap on a BSL that does not configure
+// its own
+func applyPluginRegistryStorage(bsl *velerov1.BackupStorageLocation, pluginConfig map[string]string) {
+	storage := common.PluginConfigValue(pluginConfig, common.ImageRegistryStorage)
+	if storage == "" || bsl.Spec.Config[imageRegistryStorage] != "" {
+		return
+	}
+	if bsl.Spec.Config == nil {
+		bsl.Spec.Config = make(map[string]string)
+	}
+	bsl.Spec.Config[imageRegistryStorage] = storage
+	if bsl.Spec.Config[imageRegistryRootDirectory] == "" {
+		bsl.Spec.Config[imageRegistryRootDirectory] = common.PluginConfigValue(pluginConfig, common.ImageRegistryRootDirectory)
+	}
+}
+
+// getFilesystemRegistryEnvVars returns the registry env vars storing images in a directory, e.g. a PV mounted
+// into the velero pod, for sites without object storage the registry can use
+func getFilesystemRegistryEnvVars(bsl *velerov1.BackupStorageLocation) ([]corev1.EnvVar, error) {
+	rootDirectory := bsl.Spec.Config[imageRegistryRootDirectory]
+	if rootDirectory == "" {
+		return nil, errors.Errorf("%s not set for %s registry storage of backupstoragelocation %s", imageRegistryRootDirectory, Filesystem, bsl.Name)
+	}
+	if !filepath.IsAbs(rootDirectory) {
+		return nil, errors.Errorf("registry root directory %s of backupstoragelocation %s is not an absolute path", rootDirectory, bsl.Name)
+	}
+	// refuse to write images into the container filesystem if the volume is not mounted
+	info, err := os.Stat(rootDirectory)
+	if err != nil {
+		return nil, errors.Wrapf(err, "registry root directory of backupstoragelocation %s", bsl.Name)
+	}
+	if !info.IsDir() {
+		return nil, errors.Errorf("registry root directory %s of backupstoragelocation %s is not a directory", rootDirectory, bsl.Name)
+	}
+	return []corev1.EnvVar{
+		{
+			Name:  RegistryStorageEnvVarKey,
+			Value: Filesystem,
+		},
+		{
+			Name:  RegistryStorageFilesystemRootdirectoryEnvVarKey,
+			Value: rootDirectory,
+		},
+	}, nil
+}
@@ -0,0 +1,141 @@
+package imagestream
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/containers/image/v5/copy"
+	storagedriver "github.com/distribution/distribution/v3/registry/storage/driver"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	imagev1API "github.com/openshift/api/image/v1"
+	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+)
+
+// repositoriesRoot is the path, relative to the registry storage root in the BSL, of the image repositories
+const repositoriesRoot = "/docker/registry/v2/repositories"
+
+// getImageBackupReplicaLocations returns the BSLs other than the backup storage location the images of a
+// backup are also copied to
+func getImageBackupReplicaLocations(backup *velerov1.Backup) ([]string, error) {
+	value, err := common.GetPluginOption(backup.UID, backup.Namespace, backup.Annotations, common.ImageBackupReplicaLocations)
+	if err != nil {
+		return nil, err
+	}
+	return splitLocations(value, backup.Spec.StorageLocation), nil
+}
+
+// splitLocations returns the distinct BSL names of a comma separated list, without exclude
+func splitLocations(value, exclude string) []string {
+	var locations []string
+	seen := map[string]bool{exclude: true}
+	for _, location := range strings.Split(value, ",") {
+		location = strings.TrimSpace(location)
+		if location == "" || seen[location] {
+			continue
+		}
+		seen[location] = true
+		locations = append(locations, location)
+	}
+	return locations
+}
+
+// replicateImageStreamImages copies the images of an ImageStream, already copied to the BSL of ut, to each
+// replica BSL together with their digest mapping, using checkpoints of checkpointScope. A replica failing is logged and skipped so that it
+// does not fail the backup; the BSLs the images were copied to are returned.
+func replicateImageStreamImages(imageStream imagev1API.ImageStream, backup *velerov1.Backup, replicas []string, ut *udistribution.UdistributionTransport, o imagecopy.CopyLocalImageStreamImagesOptions, checkpointScope string, digestMapping map[string]string, log logrus.FieldLogger) []string {
+	var replicated []string
+	for _, location := range replicas {
+		log.Info(fmt.Sprintf("[is-backup] replicating images to backupstoragelocation %s", location))
+		replicaUt, err := GetUdistributionTransportForLocation(backup.UID, location, backup.Namespace, log)
+		if err != nil {
+			log.Warn(fmt.Sprintf("[is-backup] skipping replica backupstoragelocation %s: %v", location, err))
+			continue
+		}
+		release := acquireTransport(replicaUt)
+		checkpoints := newBSLCheckpoints(replicaUt, checkpointScope)
+		err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+			InternalRegistryPath:    o.InternalRegistryPath,
+			InternalRegistryAliases: o.InternalRegistryAliases,
+			SrcRegistry:             imagecopy.BSLRoutePrefix,
+			DestRegistry:            imagecopy.BSLRoutePrefix,
+			DestNamespace:           o.DestNamespace,
+			SrcRepository:           path.Join(o.DestNamespace, imageStream.Name),
+			// images are copied as stored in the BSL, already compressed and encrypted
+			CopyOptions:    &copy.Options{},
+			Log:            logrusr.New(log),
+			BandwidthLimit: o.BandwidthLimit,
+			Checkpoints:    checkpoints,
+			SrcUt:          ut,
+			Ut:             replicaUt,
+		})
+		if err == nil && len(digestMapping) > 0 {
+			err = putDigestMapping(replicaUt, backup.Name, imageStream.Namespace, imageStream.Name, digestMapping)
+			if err != nil {
+				err = fmt.Errorf("error recording digest mapping: %v", err)
+			}
+		}
+		if err == nil {
+			if err := checkpoints.cleanup(); err != nil {
+				log.Warn(fmt.Sprintf("[is-backup] error removing image copy checkpoints in backupstoragelocation %s: %v", location, err))
+			}
+		}
+		release()
+		if err != nil {
+			log.Warn(fmt.Sprintf("[is-backup] error replicating images to backupstoragelocation %s: %v", location, err))
+			continue
+		}
+		replicated = append(replicated, location)
+	}
+	return replicated
+}
+
+// getRestoreTransport returns the transport of the first BSL, among the backup storage location and the
+// replicas, holding the images of the ImageStream namespace/name. The transport of the backup storage
+// location is returned if no BSL holds them, e.g. when the ImageStream has no local images.
+func getRestoreTransport(backup *velerov1.Backup, replicas []string, namespace, name string, log logrus.FieldLogger) (*udistribution.UdistributionTransport, error) {
+	primary, err := GetUdistributionTransportForLocation(backup.UID, backup.Spec.StorageLocation, backup.Namespace, log)
+	if len(replicas) == 0 {
+		return primary, err
+	}
+	if err == nil {
+		if err = checkRepository(primary, namespace, name); err == nil {
+			return primary, nil
+		}
+	}
+	log.Warn(fmt.Sprintf("[is-restore] images of imagestream %s/%s not available in backupstoragelocation %s: %v", namespace, name, backup.Spec.StorageLocation, err))
+	for _, location := range replicas {
+		ut, err := GetUdistributionTransportForLocation(backup.UID, location, backup.Namespace, log)
+		if err == nil {
+			err = checkRepository(ut, namespace, name)
+		}
+		if err != nil {
+			log.Warn(fmt.Sprintf("[is-restore] images of imagestream %s/%s not available in replica backupstoragelocation %s: %v", namespace, name, location, err))
+			continue
+		}
+		log.Info(fmt.Sprintf("[is-restore] restoring images from replica backupstoragelocation %s", location))
+		return ut, nil
+	}
+	if primary == nil {
+		return nil, fmt.Errorf("images of imagestream %s/%s not available in backupstoragelocation %s or its replicas", namespace, name, backup.Spec.StorageLocation)
+	}
+	return primary, nil
+}
+
+// checkRepository returns an error if the BSL of ut can not be read or has no repository namespace/name
+func checkRepository(ut *udistribution.UdistributionTransport, namespace, name string) error {
+	driver, err := getStorageDriver(ut)
+	if err != nil {
+		return err
+	}
+	_, err = driver.Stat(context.Background(), path.Join(repositoriesRoot, namespace, name))
+	if _, ok := err.(storagedriver.PathNotFoundError); ok {
+		return fmt.Errorf("repository %s/%s not found", namespace, name)
+	}
+	return err
+}
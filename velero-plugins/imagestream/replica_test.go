@@ -0,0 +1,130 @@
+package imagestream
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bombsimon/logrusr/v3"
+	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/types"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_splitLocations(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		exclude string
+		want    []string
+	}{
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name:    "backup storage location and duplicates dropped",
+			value:   "secondary, default,secondary ,,tertiary",
+			exclude: "default",
+			want:    []string{"secondary", "tertiary"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitLocations(tt.value, tt.exclude); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitLocations() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_imageBackupReplicas replicates backed up images to a second BSL and restores them from it when the
+// backup storage location does not hold them
+func Test_imageBackupReplicas(t *testing.T) {
+	log := test.NewLogger()
+	pool := bslTransports
+	bslTransports = newTransportPool()
+	defer func() {
+		for _, transport := range bslTransports.current {
+			transport.ut.Deregister()
+		}
+		bslTransports = pool
+	}()
+	backup := &velerov1.Backup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup", Namespace: "openshift-adp", UID: "replica-backup"},
+		Spec:       velerov1.BackupSpec{StorageLocation: "primary"},
+	}
+	newTransport := func(location string) *udistribution.UdistributionTransport {
+		ut, err := bslTransports.get(backup.UID, GetUdistributionKey(location, backup.Namespace), "1", func() (*udistribution.UdistributionTransport, error) {
+			return udistribution.NewTransportFromNewConfig("", []string{
+				RegistryStorageEnvVarKey + "=" + Filesystem,
+				RegistryStorageFilesystemRootdirectoryEnvVarKey + "=" + t.TempDir(),
+			})
+		}, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ut
+	}
+	primary := newTransport("primary")
+	replica := newTransport("replica")
+
+	internalRegistry := newTestRegistry(t)
+	insecureCtx := &types.SystemContext{DockerInsecureSkipTLSVerify: types.OptionalBoolTrue}
+	imageDigest := pushTestImage(t, "docker://"+internalRegistry+"/ns1/app:latest", insecureCtx)
+	imageStream := testImageStream(internalRegistry, imageDigest)
+	copyOptions := imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		SrcRegistry:          internalRegistry,
+		DestRegistry:         imagecopy.BSLRoutePrefix,
+		DestNamespace:        imageStream.Namespace,
+		CopyOptions:          &copy.Options{SourceCtx: insecureCtx},
+		Log:                  logrusr.New(log),
+		Ut:                   primary,
+	}
+	if err := imagecopy.CopyLocalImageStreamImages(imageStream, copyOptions); err != nil {
+		t.Fatalf("backup CopyLocalImageStreamImages() error = %v", err)
+	}
+
+	replicated := replicateImageStreamImages(imageStream, backup, []string{"replica"}, primary, copyOptions, "", nil, log)
+	if !reflect.DeepEqual(replicated, []string{"replica"}) {
+		t.Fatalf("replicateImageStreamImages() = %v, want [replica]", replicated)
+	}
+	if err := checkRepository(replica, "ns1", "app"); err != nil {
+		t.Errorf("checkRepository() of replica error = %v", err)
+	}
+
+	// the images are missing from the backup storage location of the restored backup
+	restoreBackup := backup.DeepCopy()
+	restoreBackup.Spec.StorageLocation = "empty"
+	newTransport("empty")
+	ut, err := getRestoreTransport(restoreBackup, replicated, "ns1", "app", log)
+	if err != nil {
+		t.Fatalf("getRestoreTransport() error = %v", err)
+	}
+	if ut != replica {
+		t.Fatalf("getRestoreTransport() = %s, want replica %s", ut.Name(), replica.Name())
+	}
+	err = imagecopy.CopyLocalImageStreamImages(imageStream, imagecopy.CopyLocalImageStreamImagesOptions{
+		InternalRegistryPath: internalRegistry,
+		SrcRegistry:          imagecopy.BSLRoutePrefix,
+		DestRegistry:         internalRegistry,
+		DestNamespace:        "ns2",
+		CopyOptions:          &copy.Options{DestinationCtx: insecureCtx},
+		Log:                  logrusr.New(log),
+		Ut:                   ut,
+	})
+	if err != nil {
+		t.Fatalf("restore CopyLocalImageStreamImages() error = %v", err)
+	}
+	restored, err := testManifestDigest("docker://"+internalRegistry+"/ns2/app:latest", insecureCtx)
+	if err != nil {
+		t.Fatalf("restored image not found: %v", err)
+	}
+	if restored != imageDigest {
+		t.Errorf("restored image digest = %v, want %v", restored, imageDigest)
+	}
+}
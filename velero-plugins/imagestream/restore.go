@@ -7,11 +7,13 @@ import (
 
 	"github.com/bombsimon/logrusr/v3"
 	"github.com/containers/image/v5/copy"
+	"github.com/containers/image/v5/types"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
 	"github.com/migtools/udistribution/pkg/image/udistribution"
 	imagev1API "github.com/openshift/api/image/v1"
 	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
 )
 
@@ -40,7 +42,33 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		annotations = make(map[string]string)
 	}
 	var ut *udistribution.UdistributionTransport
-	if input.Restore.Labels[common.MigrationApplicationLabelKey] != common.MigrationApplicationLabelValue {
+	backupRegistry := annotations[common.ImageBackupRegistry]
+	srcRepository := annotations[common.ImageBackupRepository]
+	directTransfer := annotations[common.DirectImageTransfer] == "true"
+	delete(annotations, common.DirectImageTransfer)
+	replicas := splitLocations(annotations[common.ImageBackupReplicas], "")
+	delete(annotations, common.ImageBackupReplicas)
+	imageStreamUnmodified := imagev1API.ImageStream{}
+	itemMarshal, _ = json.Marshal(input.ItemFromBackup)
+	json.Unmarshal(itemMarshal, &imageStreamUnmodified)
+	sourceRegistry, err := getDirectTransferSourceRegistry(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if sourceRegistry != nil {
+		// images are pulled from the source cluster registry instead of a registry they were copied to
+		p.Log.Info(fmt.Sprintf("[is-restore] restoring images directly from source cluster registry: %s", sourceRegistry.Host))
+		annotations[common.MigrationRegistry] = sourceRegistry.Host
+		backupRegistry, srcRepository = "", ""
+		delete(annotations, common.ImageBackupRegistry)
+		delete(annotations, common.ImageBackupRepository)
+	} else if backupRegistry != "" {
+		// images were backed up to an external registry instead of the BSL
+		p.Log.Info(fmt.Sprintf("[is-restore] restoring images from external registry: %s/%s", backupRegistry, srcRepository))
+		annotations[common.MigrationRegistry] = backupRegistry
+		delete(annotations, common.ImageBackupRegistry)
+		delete(annotations, common.ImageBackupRepository)
+	} else if input.Restore.Labels[common.MigrationApplicationLabelKey] != common.MigrationApplicationLabelValue {
 		// if the current workflow is not CAM(i.e B/R) then get the backup registry route and set the same on annotation to use in plugins.
 		backupLocation, err := common.GetBackup(input.Restore.GetUID(), input.Restore.Spec.BackupName, input.Restore.Namespace)
 		if err != nil {
@@ -49,10 +77,11 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		if imagecopy.UsePluginRegistry(){
 			var err error
 			p.Log.Info(fmt.Sprintf("[is-restore] Getting UdistributionTransportForLocation(%s, namespace: %s)", backupLocation.Spec.StorageLocation, backupLocation.Namespace))
-			ut, err = GetUdistributionTransportForLocation(backupLocation.GetUID(), backupLocation.Spec.StorageLocation, backupLocation.Namespace, p.Log)
+			ut, err = getRestoreTransport(backupLocation, replicas, imageStreamUnmodified.Namespace, imageStreamUnmodified.Name, p.Log)
 			if err != nil {
 				return nil, err
 			}
+			defer acquireTransport(ut)()
 			p.Log.Info(fmt.Sprintf("[is-restore] migrationRegistry: %s)", fmt.Sprintf("%s%s", imagecopy.BSLRoutePrefix,  GetUdistributionKey(backupLocation.Spec.StorageLocation, backupLocation.Namespace))))
 			annotations[common.MigrationRegistry] = imagecopy.BSLRoutePrefix
 		} else {
@@ -68,16 +97,19 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		p.Log.Info("[is-restore] Image copy is excluded for backup; skipping image copy.")
 		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
 	}
-
-	imageStreamUnmodified := imagev1API.ImageStream{}
-	itemMarshal, _ = json.Marshal(input.ItemFromBackup)
-	json.Unmarshal(itemMarshal, &imageStreamUnmodified)
+	if annotations[common.ImageBackupPreflight] == "true" {
+		p.Log.Info("[is-restore] Images were not copied by the preflight backup; skipping image copy.")
+		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+	}
 
 	skipImages := annotations[common.SkipImageCopy]
 	if len(skipImages) != 0 && skipImages == "true" {
 		p.Log.Info("Not running in OADP/CAM context, skipping copy of image.")
 		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
 	}
+	if directTransfer && sourceRegistry == nil {
+		return nil, fmt.Errorf("images of imagestream %s were not copied by the backup but no %s is configured for the restore", imageStream.Name, common.RestoreDirectImageTransferSecret)
+	}
 	backupInternalRegistry, internalRegistry, err := common.GetSrcAndDestRegistryInfo(input.Item)
 	if err != nil {
 		return nil, err
@@ -96,36 +128,103 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		destNamespace = namespaceMapping[imageStreamUnmodified.Namespace]
 	}
 
-	sourceCtx, err := migrationRegistrySystemContext()
+	sourceCtx, err := restoreSourceSystemContext(input.Restore, sourceRegistry, backupRegistry)
+	if err != nil {
+		return nil, err
+	}
+	// images are pushed to an external registry when one is configured for the restore,
+	// e.g. when the destination cluster has no internal registry
+	externalRegistry, err := common.GetRestoreExternalRegistry(input.Restore)
 	if err != nil {
 		return nil, err
 	}
-	destinationCtx, err := internalRegistrySystemContext()
+	var destinationCtx *types.SystemContext
+	if externalRegistry != nil {
+		p.Log.Info(fmt.Sprintf("[is-restore] restoring images into external registry: %s", externalRegistry.Registry))
+		internalRegistry = externalRegistry.Registry
+		destinationCtx, err = externalRegistrySystemContext(externalRegistry, input.Restore.Namespace)
+	} else {
+		destinationCtx, err = internalRegistrySystemContext()
+	}
 	if err != nil {
 		return nil, err
 	}
+	copyOptions := &copy.Options{
+		SourceCtx:      sourceCtx,
+		DestinationCtx: destinationCtx,
+	}
+	var restoredDigests map[string]string
+	if annotations[common.ImageEncryption] != "" {
+		encryptionSecret, err := getImageEncryptionSecret(input.Restore.UID, input.Restore.Namespace, input.Restore.Annotations)
+		if err != nil {
+			return nil, err
+		}
+		if encryptionSecret == nil {
+			return nil, fmt.Errorf("images of imagestream %s are encrypted but no %s is configured for the restore", imageStream.Name, common.ImageEncryptionSecret)
+		}
+		p.Log.Info(fmt.Sprintf("[is-restore] decrypting image layers with keys from secret %s", encryptionSecret.Name))
+		copyOptions.OciDecryptConfig, err = decryptConfig(encryptionSecret)
+		if err != nil {
+			return nil, err
+		}
+		delete(annotations, common.ImageEncryption)
+		// decrypting changes the digests of the backed up images again, e.g. a Docker schema 2 image was
+		// converted to OCI when it was encrypted, so it is not restored with its source digest either
+		restoredDigests = map[string]string{}
+	}
 	err = imagecopy.CopyLocalImageStreamImages(
 		imageStreamUnmodified,
 		imagecopy.CopyLocalImageStreamImagesOptions{
 			InternalRegistryPath: backupInternalRegistry,
+			InternalRegistryAliases: common.GetBackupRegistryAliases(input.Item),
 			SrcRegistry: migrationRegistry,
+			SrcRepository: srcRepository,
 			DestRegistry: internalRegistry,
 			DestNamespace: destNamespace,
-			CopyOptions: &copy.Options{
-							SourceCtx:      sourceCtx,
-							DestinationCtx: destinationCtx,
-						},
+			CopyOptions: copyOptions,
 			Log: logrusr.New(p.Log),
-			UpdateDigest: false,
+			UpdateDigest: externalRegistry != nil || restoredDigests != nil,
+			DigestMapping: restoredDigests,
 			Ut: ut,
 		})
 	if err != nil {
 		return nil, err
 	}
+	if len(restoredDigests) > 0 {
+		p.Log.Info(fmt.Sprintf("[is-restore] recording %d image digests changed by decryption", len(restoredDigests)))
+		common.RecordRestoredDigests(input.Restore, restoredDigests, p.Log)
+	}
+
+	if externalRegistry != nil {
+		// there is no internal registry to push tags into, so the ImageStream itself is
+		// restored with its tags pointing at the external registry
+		imageStream.Status = imageStreamUnmodified.Status
+		updateSpecTagsForExternalRegistry(&imageStream, backupInternalRegistry, externalRegistry.Registry, namespaceMapping, common.GetBackupRegistryAliases(input.Item)...)
+	}
 
 	var out map[string]interface{}
 	objrec, _ := json.Marshal(imageStream)
 	json.Unmarshal(objrec, &out)
 	input.Item.SetUnstructuredContent(out)
+	if externalRegistry != nil {
+		return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+	}
 	return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
 }
+
+// restoreSourceSystemContext returns the system context images are pulled with on restore: from the source
+// cluster registry with direct image transfer, from the external registry the images were backed up to, or
+// from the migration registry
+func restoreSourceSystemContext(restore *velerov1.Restore, sourceRegistry *sourceRegistry, backupRegistry string) (*types.SystemContext, error) {
+	if sourceRegistry != nil {
+		return sourceRegistry.systemContext(), nil
+	}
+	if backupRegistry == "" {
+		return migrationRegistrySystemContext()
+	}
+	backupExternalRegistry, err := common.GetBackupExternalRegistryForRestore(restore, backupRegistry)
+	if err != nil {
+		return nil, err
+	}
+	return externalRegistrySystemContext(backupExternalRegistry, restore.Namespace)
+}
@@ -0,0 +1,85 @@
+package imagestream
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/rest"
+)
+
+func Test_restoreSourceSystemContext(t *testing.T) {
+	// the API server fails every request, the credential secret can't be read
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusInternalServerError)
+	}))
+	defer server.Close()
+	client, err := corev1.NewForConfig(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(clients.SetCoreClient(client))
+	restore := func(secret string) *velerov1.Restore {
+		return &velerov1.Restore{ObjectMeta: metav1.ObjectMeta{
+			Name:      "restore",
+			Namespace: "openshift-adp",
+			UID:       "restore-uid",
+			Annotations: map[string]string{
+				common.BackupExternalRegistrySecret:   secret,
+				common.BackupExternalRegistryInsecure: "true",
+			},
+		}}
+	}
+	tests := []struct {
+		name           string
+		restore        *velerov1.Restore
+		sourceRegistry *sourceRegistry
+		backupRegistry string
+		wantInsecure   bool
+		wantErr        bool
+	}{
+		{
+			name:           "direct transfer",
+			restore:        restore("quay-creds"),
+			sourceRegistry: &sourceRegistry{Host: "source.example.com"},
+			backupRegistry: "quay.io/myorg",
+		},
+		{
+			name:           "backup registry without secret",
+			restore:        restore(""),
+			backupRegistry: "quay.io/myorg",
+			wantInsecure:   true,
+		},
+		{
+			name:           "backup registry secret lookup fails",
+			restore:        restore("quay-creds"),
+			backupRegistry: "quay.io/myorg",
+			wantErr:        true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := restoreSourceSystemContext(tt.restore, tt.sourceRegistry, tt.backupRegistry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("restoreSourceSystemContext() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if got != nil {
+					t.Errorf("restoreSourceSystemContext() = %v, want nil on error", got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("restoreSourceSystemContext() = nil")
+			}
+			if got.DockerDaemonInsecureSkipTLSVerify != tt.wantInsecure {
+				t.Errorf("restoreSourceSystemContext() insecure = %v, want %v", got.DockerDaemonInsecureSkipTLSVerify, tt.wantInsecure)
+			}
+		})
+	}
+}
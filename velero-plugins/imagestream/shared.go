@@ -8,11 +8,9 @@ import (
 	"github.com/containers/image/v5/types"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
-	"github.com/migtools/udistribution/pkg/image/udistribution"
-	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	k8stypes "k8s.io/apimachinery/pkg/types"
 	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
 )
 
@@ -54,44 +52,25 @@ func migrationRegistrySystemContext() (*types.SystemContext, error) {
 	return ctx, nil
 }
 
-func GetUdistributionTransportForLocation(uid k8stypes.UID, location, namespace string, log logrus.FieldLogger) (*udistribution.UdistributionTransport, error) {
-	if common.BackupUidMap == nil {
-		common.BackupUidMap = make(map[k8stypes.UID]*common.CommonStruct)
-	}
-	if common.BackupUidMap[uid] == nil {
-		common.BackupUidMap[uid] = &common.CommonStruct{}
-	}
-	common.BackupUidMap[uid].JustAccessed()
-	if common.BackupUidMap[uid].Ut != nil {
-		log.Info("Got udistribution transport from cache")
-		return common.BackupUidMap[uid].Ut, nil
-	}
-	log.Info("Getting registry envs for udistribution transport")
-	envs, err := GetRegistryEnvsForLocation(location, namespace)
-	if err != nil {
-		return nil, fmt.Errorf("errors getting registryenv: %v", err)
-	}
-	log.Info("Creating udistribution transport")
-	ut, err := udistribution.NewTransportFromNewConfig("", envs)
-	if err != nil {
-		return nil, fmt.Errorf("errors creating new udistribution transport from config: %v", err)
-	}
-	log.Info("Got udistribution transport")
-	common.BackupUidMap[uid].Ut = ut
-	return ut, nil
-}
-
 func GetUdistributionKey(location, namespace string) string {
 	return fmt.Sprintf("%s-%s", namespace, location)
 }
 
 // Get Registry environment variables to create registry client
-// This should be called once per backup.
 func GetRegistryEnvsForLocation(location string, namespace string) ([]string, error) {
 	bsl, err := common.GetBackupStorageLocation(location, namespace)
 	if err != nil {
 		return nil, fmt.Errorf("errors getting bsl: %v", err)
 	}
+	return getRegistryEnvsForBSL(bsl)
+}
+
+func getRegistryEnvsForBSL(bsl *velerov1.BackupStorageLocation) ([]string, error) {
+	pluginConfig, err := common.ReadPluginConfig(bsl.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("errors getting plugin config: %v", err)
+	}
+	applyPluginRegistryStorage(bsl, pluginConfig)
 
 	envVars, err := getRegistryEnvVars(bsl)
 	if err != nil {
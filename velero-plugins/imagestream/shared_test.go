@@ -100,7 +100,71 @@ func TestGetRegistryEnvsForLocation(t *testing.T) {
 			},
 		},
 		{
-			name: "aws case, operator did not create registry secret",
+			name: "aws case, operator did not create registry secret, keys read from bsl credential",
+			args: args{
+				location:  "bsl1",
+				namespace: "ns1",
+			},
+			objs: []runtime.Object{
+				&corev1.Namespace{
+					TypeMeta: metav1.TypeMeta{
+						Kind:       "Namespace",
+						APIVersion: "v1",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "ns1",
+					},
+				},
+				&corev1.Secret{
+					TypeMeta: metav1.TypeMeta{
+						Kind:       "Secret",
+						APIVersion: "v1",
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "cloud-credentials",
+						Namespace: "ns1",
+					},
+					Data: map[string][]byte{
+						"cloud": []byte("[default]\naws_access_key_id=bslak\naws_secret_access_key=bslsk\n"),
+					},
+				},
+				&velerov1.BackupStorageLocation{
+					TypeMeta: metav1.TypeMeta{
+						Kind:       "BackupStorageLocation",
+						APIVersion: velerov1.SchemeGroupVersion.String(),
+					},
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "bsl1",
+						Namespace: "ns1",
+					},
+					Spec: velerov1.BackupStorageLocationSpec{
+						Provider: "aws",
+						Default:  true,
+						StorageType: velerov1.StorageType{
+							ObjectStorage: &velerov1.ObjectStorageLocation{
+								Bucket: "buc",
+								Prefix: "prefix",
+							},
+						},
+						Credential: &corev1.SecretKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{
+								Name: "cloud-credentials",
+							},
+							Key: "cloud",
+						},
+					},
+				},
+			},
+			want: []string{
+				"REGISTRY_STORAGE=s3",
+				"REGISTRY_STORAGE_S3_BUCKET=buc",
+				"REGISTRY_STORAGE_S3_REGION=us-east-2",
+				"REGISTRY_STORAGE_S3_ACCESSKEY=bslak",
+				"REGISTRY_STORAGE_S3_SECRETKEY=bslsk",
+			},
+		},
+		{
+			name: "aws case, neither registry secret nor bsl credential",
 			args: args{
 				location:  "bsl1",
 				namespace: "ns1",
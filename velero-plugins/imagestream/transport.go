@@ -0,0 +1,261 @@
+package imagestream
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+// transports and backups/restores using them are dropped from the pool when not used for this long
+const transportIdleTimeout = 30 * time.Minute
+
+var bslTransports = newTransportPool()
+
+// transportPool shares one udistribution transport per BSL between concurrent backups and restores.
+// The transport of a BSL is replaced when the BSL, its secrets or the plugin ConfigMap change; a
+// backup or restore keeps the transport it started with until it is done.
+type transportPool struct {
+	mu sync.Mutex
+	// current transport of each BSL by GetUdistributionKey
+	current map[string]*pooledTransport
+	// replaced transports still used by a backup or restore
+	retired []*pooledTransport
+	users   map[transportUserKey]*transportUser
+	now     func() time.Time
+}
+
+type pooledTransport struct {
+	ut *udistribution.UdistributionTransport
+	// resource versions of the BSL and the objects its registry config is read from
+	version  string
+	lastUsed time.Time
+	// image copies using the transport, it is not shut down while any are running
+	refs int
+}
+
+// transportUserKey identifies a backup using the transport of a BSL
+type transportUserKey struct {
+	uid k8stypes.UID
+	key string
+}
+
+type transportUser struct {
+	transport *pooledTransport
+	lastUsed  time.Time
+}
+
+func newTransportPool() *transportPool {
+	return &transportPool{
+		current: map[string]*pooledTransport{},
+		users:   map[transportUserKey]*transportUser{},
+		now:     time.Now,
+	}
+}
+
+// GetUdistributionTransportForLocation returns the transport of the BSL registry for the backup with uid
+func GetUdistributionTransportForLocation(uid k8stypes.UID, location, namespace string, log logrus.FieldLogger) (*udistribution.UdistributionTransport, error) {
+	key := GetUdistributionKey(location, namespace)
+	if ut := bslTransports.inUse(uid, key); ut != nil {
+		log.Info("Got udistribution transport from cache")
+		return ut, nil
+	}
+	bsl, err := common.GetBackupStorageLocation(location, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("errors getting bsl: %v", err)
+	}
+	version, err := transportVersion(bsl)
+	if err != nil {
+		return nil, fmt.Errorf("errors getting bsl registry config version: %v", err)
+	}
+	return bslTransports.get(uid, key, version, func() (*udistribution.UdistributionTransport, error) {
+		log.Info("Getting registry envs for udistribution transport")
+		envs, err := getRegistryEnvsForBSL(bsl)
+		if err != nil {
+			return nil, fmt.Errorf("errors getting registryenv: %v", err)
+		}
+		log.Info("Creating udistribution transport")
+		ut, err := udistribution.NewTransportFromNewConfig("", envs)
+		if err != nil {
+			return nil, fmt.Errorf("errors creating new udistribution transport from config: %v", err)
+		}
+		return ut, nil
+	}, log)
+}
+
+// inUse returns the transport of the BSL with key used by the backup with uid, or nil if it has none yet
+func (p *transportPool) inUse(uid k8stypes.UID, key string) *udistribution.UdistributionTransport {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	user := p.users[transportUserKey{uid: uid, key: key}]
+	if user == nil {
+		return nil
+	}
+	p.touch(user)
+	return user.transport.ut
+}
+
+// get returns the transport of the BSL with key used by the backup with uid. Otherwise the current transport of the BSL with
+// key is used if it was created for version; if not, a transport is created with newTransport.
+func (p *transportPool) get(uid k8stypes.UID, key, version string, newTransport func() (*udistribution.UdistributionTransport, error), log logrus.FieldLogger) (*udistribution.UdistributionTransport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictIdle(log)
+	userKey := transportUserKey{uid: uid, key: key}
+	if user := p.users[userKey]; user != nil {
+		p.touch(user)
+		return user.transport.ut, nil
+	}
+	transport := p.current[key]
+	if transport != nil && transport.version != version {
+		log.Infof("[transport-pool] backupstoragelocation %s changed, replacing its udistribution transport", key)
+		p.retired = append(p.retired, transport)
+		delete(p.current, key)
+		transport = nil
+	}
+	if transport == nil {
+		ut, err := newTransport()
+		if err != nil {
+			return nil, err
+		}
+		log.Infof("[transport-pool] created udistribution transport %s for backupstoragelocation %s", ut.Name(), key)
+		transport = &pooledTransport{ut: ut, version: version}
+		p.current[key] = transport
+	} else {
+		log.Info("Got udistribution transport from pool")
+	}
+	user := &transportUser{transport: transport}
+	p.users[userKey] = user
+	p.touch(user)
+	return transport.ut, nil
+}
+
+// acquireTransport marks ut as used by an image copy until the returned func is called
+func acquireTransport(ut *udistribution.UdistributionTransport) func() {
+	return bslTransports.acquire(ut)
+}
+
+// acquire marks ut as used by an image copy until the returned func is called. Transports not
+// from the pool are not tracked.
+func (p *transportPool) acquire(ut *udistribution.UdistributionTransport) func() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	transport := p.find(ut)
+	if transport == nil {
+		return func() {}
+	}
+	transport.refs++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			transport.refs--
+			transport.lastUsed = p.now()
+		})
+	}
+}
+
+// find returns the pooled transport of ut, or nil if it is not in the pool
+func (p *transportPool) find(ut *udistribution.UdistributionTransport) *pooledTransport {
+	if ut == nil {
+		return nil
+	}
+	for _, transport := range p.current {
+		if transport.ut == ut {
+			return transport
+		}
+	}
+	for _, transport := range p.retired {
+		if transport.ut == ut {
+			return transport
+		}
+	}
+	return nil
+}
+
+func (p *transportPool) touch(user *transportUser) {
+	user.lastUsed = p.now()
+	user.transport.lastUsed = user.lastUsed
+}
+
+// evictIdle drops the backups and restores not using their transport anymore and shuts down idle transports
+// no image copy is using
+func (p *transportPool) evictIdle(log logrus.FieldLogger) {
+	expired := p.now().Add(-transportIdleTimeout)
+	for userKey, user := range p.users {
+		if user.lastUsed.Before(expired) {
+			delete(p.users, userKey)
+		}
+	}
+	inUse := map[*pooledTransport]bool{}
+	for _, user := range p.users {
+		inUse[user.transport] = true
+	}
+	for key, transport := range p.current {
+		if !inUse[transport] && transport.refs == 0 && transport.lastUsed.Before(expired) {
+			p.shutdown(transport, log)
+			delete(p.current, key)
+		}
+	}
+	retired := p.retired[:0]
+	for _, transport := range p.retired {
+		if inUse[transport] || transport.refs > 0 {
+			retired = append(retired, transport)
+			continue
+		}
+		p.shutdown(transport, log)
+	}
+	p.retired = retired
+}
+
+func (p *transportPool) shutdown(transport *pooledTransport, log logrus.FieldLogger) {
+	log.Infof("[transport-pool] shutting down udistribution transport %s", transport.ut.Name())
+	transport.ut.Deregister()
+	dropStorageDriver(transport.ut)
+}
+
+// transportVersion returns the resource versions of a BSL, its credential and registry secrets and the
+// plugin ConfigMap, which together determine the registry config of the BSL
+func transportVersion(bsl *velerov1.BackupStorageLocation) (string, error) {
+	client, err := clients.CoreClient()
+	if err != nil {
+		return "", err
+	}
+	versions := []string{bsl.ResourceVersion}
+	for _, name := range []string{bslSecretKeySelector(bsl).Name, registrySecretName(bsl)} {
+		if name == "" {
+			versions = append(versions, "")
+			continue
+		}
+		secret, err := client.Secrets(bsl.Namespace).Get(context.Background(), name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			versions = append(versions, "")
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		versions = append(versions, secret.ResourceVersion)
+	}
+	cm, err := client.ConfigMaps(bsl.Namespace).Get(context.Background(), common.RegistryConfigMap, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		versions = append(versions, "")
+	case err != nil:
+		return "", err
+	default:
+		versions = append(versions, cm.ResourceVersion)
+	}
+	return strings.Join(versions, "/"), nil
+}
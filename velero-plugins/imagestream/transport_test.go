@@ -0,0 +1,109 @@
+package imagestream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/containers/image/v5/transports"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	"github.com/migtools/udistribution/pkg/image/udistribution"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+)
+
+func Test_transportPool(t *testing.T) {
+	log := test.NewLogger()
+	now := time.Now()
+	pool := newTransportPool()
+	pool.now = func() time.Time { return now }
+	created := 0
+	newTransport := func() (*udistribution.UdistributionTransport, error) {
+		created++
+		return udistribution.NewTransportFromNewConfig("", []string{
+			RegistryStorageEnvVarKey + "=" + Filesystem,
+			RegistryStorageFilesystemRootdirectoryEnvVarKey + "=" + t.TempDir(),
+		})
+	}
+	get := func(uid k8stypes.UID, version string) *udistribution.UdistributionTransport {
+		ut, err := pool.get(uid, "velero-bsl", version, newTransport, log)
+		if err != nil {
+			t.Fatalf("get() error = %v", err)
+		}
+		return ut
+	}
+
+	backup1 := get("backup-1", "1")
+	if backup2 := get("backup-2", "1"); backup2 != backup1 {
+		t.Errorf("get() created transport %s for unchanged bsl, want %s", backup2.Name(), backup1.Name())
+	}
+	if created != 1 {
+		t.Errorf("get() created %d transports, want 1", created)
+	}
+
+	// the bsl changed while backup-1 is running
+	backup3 := get("backup-3", "2")
+	if backup3 == backup1 {
+		t.Errorf("get() reused transport %s for changed bsl", backup3.Name())
+	}
+	if got := get("backup-1", "2"); got != backup1 {
+		t.Errorf("get() for running backup = %s, want %s", got.Name(), backup1.Name())
+	}
+	if got := pool.inUse("backup-3", "velero-bsl"); got != backup3 {
+		t.Errorf("inUse() = %v, want %s", got, backup3.Name())
+	}
+	if got := pool.inUse("backup-4", "velero-bsl"); got != nil {
+		t.Errorf("inUse() = %s, want nil", got.Name())
+	}
+
+	// backup-3 keeps using the current transport, the others are done
+	now = now.Add(transportIdleTimeout / 2)
+	pool.inUse("backup-3", "velero-bsl")
+	now = now.Add(transportIdleTimeout/2 + time.Second)
+	if got := get("backup-4", "2"); got != backup3 {
+		t.Errorf("get() = %s, want current transport %s", got.Name(), backup3.Name())
+	}
+	if transports.Get(backup1.Name()) != nil {
+		t.Errorf("replaced transport %s not shut down", backup1.Name())
+	}
+	if transports.Get(backup3.Name()) == nil {
+		t.Errorf("current transport %s shut down", backup3.Name())
+	}
+	if len(pool.users) != 2 || len(pool.retired) != 0 {
+		t.Errorf("pool has %d users and %d retired transports, want 2 and 0", len(pool.users), len(pool.retired))
+	}
+
+	// nothing used the bsl for a while
+	now = now.Add(transportIdleTimeout + time.Second)
+	pool.evictIdle(log)
+	if transports.Get(backup3.Name()) != nil {
+		t.Errorf("idle transport %s not shut down", backup3.Name())
+	}
+	if len(pool.current) != 0 || len(pool.users) != 0 {
+		t.Errorf("pool has %d transports and %d users, want none", len(pool.current), len(pool.users))
+	}
+
+	// an image copy outlasts the idle timeout
+	backup5 := get("backup-5", "2")
+	if _, err := getStorageDriver(backup5); err != nil {
+		t.Fatalf("getStorageDriver() error = %v", err)
+	}
+	release := pool.acquire(backup5)
+	now = now.Add(transportIdleTimeout + time.Second)
+	pool.evictIdle(log)
+	if transports.Get(backup5.Name()) == nil {
+		t.Errorf("transport %s used by an image copy shut down", backup5.Name())
+	}
+	release()
+	release()
+	pool.evictIdle(log)
+	if transports.Get(backup5.Name()) == nil {
+		t.Errorf("transport %s shut down right after the image copy", backup5.Name())
+	}
+	now = now.Add(transportIdleTimeout + time.Second)
+	pool.evictIdle(log)
+	if transports.Get(backup5.Name()) != nil {
+		t.Errorf("idle transport %s not shut down", backup5.Name())
+	}
+	if _, ok := storageDrivers[backup5.Name()]; ok {
+		t.Errorf("storage driver of shut down transport %s not dropped", backup5.Name())
+	}
+}
@@ -0,0 +1,83 @@
+package imagestream
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/containers/image/v5/pkg/compression"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagecopy"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// imageBackupTuning holds the options controlling how images are written to the backup
+type imageBackupTuning struct {
+	compression      *compression.Algorithm
+	compressionLevel *int
+	maxImageSize     int64
+	bandwidthLimit   int64
+}
+
+// getImageBackupTuning reads the image backup tuning options of a backup
+func getImageBackupTuning(backup *velerov1.Backup) (imageBackupTuning, error) {
+	options := map[string]string{}
+	for _, key := range []string{
+		common.ImageBackupCompression,
+		common.ImageBackupCompressionLevel,
+		common.ImageBackupMaxImageSize,
+		common.ImageBackupBandwidthLimit,
+	} {
+		val, err := common.GetPluginOption(backup.UID, backup.Namespace, backup.Annotations, key)
+		if err != nil {
+			return imageBackupTuning{}, err
+		}
+		options[key] = val
+	}
+	return parseImageBackupTuning(options)
+}
+
+func parseImageBackupTuning(options map[string]string) (imageBackupTuning, error) {
+	tuning := imageBackupTuning{}
+	if val := options[common.ImageBackupCompression]; val != "" {
+		algorithm, err := compression.AlgorithmByName(val)
+		if err != nil {
+			return tuning, fmt.Errorf("invalid %s: %v", common.ImageBackupCompression, err)
+		}
+		tuning.compression = &algorithm
+	}
+	if val := options[common.ImageBackupCompressionLevel]; val != "" {
+		level, err := strconv.Atoi(val)
+		if err != nil {
+			return tuning, fmt.Errorf("invalid %s: %v", common.ImageBackupCompressionLevel, err)
+		}
+		tuning.compressionLevel = &level
+	}
+	if val := options[common.ImageBackupMaxImageSize]; val != "" {
+		quantity, err := resource.ParseQuantity(val)
+		if err != nil {
+			return tuning, fmt.Errorf("invalid %s: %v", common.ImageBackupMaxImageSize, err)
+		}
+		tuning.maxImageSize = quantity.Value()
+	}
+	if val := options[common.ImageBackupBandwidthLimit]; val != "" {
+		quantity, err := resource.ParseQuantity(val)
+		if err != nil {
+			return tuning, fmt.Errorf("invalid %s: %v", common.ImageBackupBandwidthLimit, err)
+		}
+		tuning.bandwidthLimit = quantity.Value()
+	}
+	return tuning, nil
+}
+
+// apply sets the tuning options on the image copy options
+func (t imageBackupTuning) apply(o *imagecopy.CopyLocalImageStreamImagesOptions) {
+	if t.compression != nil {
+		o.CopyOptions.DestinationCtx.CompressionFormat = t.compression
+		o.CopyOptions.DestinationCtx.CompressionLevel = t.compressionLevel
+		// recompress layers already compressed with another algorithm
+		o.CopyOptions.ForceCompressionFormat = true
+	}
+	o.MaxImageSize = t.maxImageSize
+	o.BandwidthLimit = t.bandwidthLimit
+}
@@ -0,0 +1,74 @@
+package imagestream
+
+import (
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+)
+
+func Test_parseImageBackupTuning(t *testing.T) {
+	tests := []struct {
+		name               string
+		options            map[string]string
+		wantCompression    string
+		wantLevel          int
+		wantMaxImageSize   int64
+		wantBandwidthLimit int64
+		wantErr            bool
+	}{
+		{
+			name:    "no options",
+			options: map[string]string{},
+		},
+		{
+			name: "all options",
+			options: map[string]string{
+				common.ImageBackupCompression:      "zstd:chunked",
+				common.ImageBackupCompressionLevel: "3",
+				common.ImageBackupMaxImageSize:     "5Gi",
+				common.ImageBackupBandwidthLimit:   "50Mi",
+			},
+			wantCompression:    "zstd:chunked",
+			wantLevel:          3,
+			wantMaxImageSize:   5 * 1024 * 1024 * 1024,
+			wantBandwidthLimit: 50 * 1024 * 1024,
+		},
+		{
+			name:    "unknown compression",
+			options: map[string]string{common.ImageBackupCompression: "lz4"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid size",
+			options: map[string]string{common.ImageBackupMaxImageSize: "big"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseImageBackupTuning(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseImageBackupTuning() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.wantCompression == "" && got.compression != nil {
+				t.Errorf("parseImageBackupTuning() compression = %v, want none", got.compression.Name())
+			}
+			if tt.wantCompression != "" && (got.compression == nil || got.compression.Name() != tt.wantCompression) {
+				t.Errorf("parseImageBackupTuning() compression = %v, want %v", got.compression, tt.wantCompression)
+			}
+			if tt.wantLevel != 0 && (got.compressionLevel == nil || *got.compressionLevel != tt.wantLevel) {
+				t.Errorf("parseImageBackupTuning() compressionLevel = %v, want %v", got.compressionLevel, tt.wantLevel)
+			}
+			if got.maxImageSize != tt.wantMaxImageSize {
+				t.Errorf("parseImageBackupTuning() maxImageSize = %v, want %v", got.maxImageSize, tt.wantMaxImageSize)
+			}
+			if got.bandwidthLimit != tt.wantBandwidthLimit {
+				t.Errorf("parseImageBackupTuning() bandwidthLimit = %v, want %v", got.bandwidthLimit, tt.wantBandwidthLimit)
+			}
+		})
+	}
+}
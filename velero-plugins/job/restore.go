@@ -35,8 +35,25 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	if err != nil {
 		return nil, err
 	}
-	common.SwapContainerImageRefs(job.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
-	common.SwapContainerImageRefs(job.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	common.SwapContainerImageRefs(job.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	common.SwapContainerImageRefs(job.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	rewriter.Containers(job.Spec.Template.Spec.Containers, p.Log)
+	rewriter.Containers(job.Spec.Template.Spec.InitContainers, p.Log)
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	common.RemapContainerImageDigests(job.Spec.Template.Spec.Containers, digestMapping, p.Log, backupRegistry, registry)
+	common.RemapContainerImageDigests(job.Spec.Template.Spec.InitContainers, digestMapping, p.Log, backupRegistry, registry)
+	namespace, err := common.GetRestoreNamespace(input.ItemFromBackup, input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.UpdatePodSpecPullSecrets(&job.Spec.Template.Spec, namespace, p.Log); err != nil {
+		return nil, err
+	}
 
 	ownerRefs, err := common.GetOwnerReferences(input.ItemFromBackup)
 	if err != nil {
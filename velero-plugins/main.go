@@ -1,6 +1,9 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/build"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/buildconfig"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clusterrolebindings"
@@ -16,6 +19,7 @@ import (
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/imagetag"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/job"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/nonadmin"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/ownerref"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/persistentvolume"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/pod"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/pvc"
@@ -33,8 +37,23 @@ import (
 	veleroplugin "github.com/vmware-tanzu/velero/pkg/plugin/framework"
 )
 
+// imagePreflightCommand runs the image backup preflight for the namespaces given as arguments
+const imagePreflightCommand = "image-preflight"
+
+// veleroNamespaceEnv is set to the namespace of the Velero pod
+const veleroNamespaceEnv = "VELERO_NAMESPACE"
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == imagePreflightCommand {
+		if err := imagestream.Preflight(os.Args[2:], os.Getenv(veleroNamespaceEnv), os.Stdout, logrus.New()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	veleroplugin.NewServer().
+		RegisterBackupItemAction("openshift.io/00-owner-backup-plugin", newOwnerBackupPlugin).
+		RegisterRestoreItemAction("openshift.io/00-owner-restore-plugin", newOwnerRestorePlugin).
 		RegisterBackupItemAction("openshift.io/01-common-backup-plugin", newCommonBackupPlugin).
 		RegisterRestoreItemAction("openshift.io/01-common-restore-plugin", newCommonRestorePlugin).
 		RegisterBackupItemAction("openshift.io/02-serviceaccount-backup-plugin", newServiceAccountBackupPlugin).
@@ -50,7 +69,7 @@ func main() {
 		RegisterBackupItemAction("openshift.io/07-pod-backup-plugin", newPodBackupPlugin).
 		RegisterRestoreItemAction("openshift.io/07-pod-restore-plugin", newPodRestorePlugin).
 		RegisterBackupItemAction("openshift.io/08-deploymentconfig-backup-plugin", newDeploymentConfigBackupPlugin).
-		RegisterRestoreItemAction("openshift.io/08-deploymentconfig-restore-plugin", newDeploymentConfigRestorePlugin).
+		RegisterRestoreItemActionV2("openshift.io/08-deploymentconfig-restore-plugin", newDeploymentConfigRestorePlugin).
 		RegisterBackupItemAction("openshift.io/09-replicationcontroller-backup-plugin", newReplicationControllerBackupPlugin).
 		RegisterRestoreItemAction("openshift.io/09-replicationcontroller-restore-plugin", newReplicationControllerRestorePlugin).
 		RegisterRestoreItemAction("openshift.io/10-job-restore-plugin", newJobRestorePlugin).
@@ -83,6 +102,14 @@ func newCommonRestorePlugin(logger logrus.FieldLogger) (interface{}, error) {
 	return &common.RestorePlugin{Log: logger}, nil
 }
 
+func newOwnerBackupPlugin(logger logrus.FieldLogger) (interface{}, error) {
+	return &ownerref.BackupPlugin{Log: logger}, nil
+}
+
+func newOwnerRestorePlugin(logger logrus.FieldLogger) (interface{}, error) {
+	return &ownerref.RestorePlugin{Log: logger}, nil
+}
+
 func newBuildRestorePlugin(logger logrus.FieldLogger) (interface{}, error) {
 	return &build.RestorePlugin{Log: logger}, nil
 }
@@ -0,0 +1,50 @@
+package ownerref
+
+import (
+	"encoding/json"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/sirupsen/logrus"
+	v1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// BackupPlugin is a backup item action plugin for Velero
+type BackupPlugin struct {
+	Log logrus.FieldLogger
+}
+
+// AppliesTo returns a velero.ResourceSelector that applies to the resources operators commonly create
+func (p *BackupPlugin) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: includedResources,
+	}, nil
+}
+
+// Execute records the controller owner of the item if it is a custom resource included in the backup
+func (p *BackupPlugin) Execute(item runtime.Unstructured, backup *v1.Backup) (runtime.Unstructured, []velero.ResourceIdentifier, error) {
+	owner, err := common.GetBackedUpControllerOwner(item, backup, p.Log)
+	if err != nil || owner == nil {
+		return item, nil, err
+	}
+	// the restore checks its label selectors against the labels of the owner
+	ownerLabels, err := json.Marshal(owner.Labels)
+	if err != nil {
+		return nil, nil, err
+	}
+	metadata, err := meta.Accessor(item)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.Log.Infof("[owner-backup] %s is owned by backed up custom resource %s", metadata.GetName(), owner.Name)
+	annotations := metadata.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[common.BackupControllerOwner] = owner.Name
+	annotations[common.BackupControllerOwnerLabels] = string(ownerLabels)
+	metadata.SetAnnotations(annotations)
+	return item, nil, nil
+}
@@ -0,0 +1,63 @@
+package ownerref
+
+import (
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/sirupsen/logrus"
+	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// resources owned by operator custom resources that operators recreate
+var includedResources = []string{
+	"pods",
+	"deployments.apps",
+	"statefulsets.apps",
+	"daemonsets.apps",
+	"replicasets.apps",
+	"replicationcontrollers",
+	"deploymentconfigs.apps.openshift.io",
+	"jobs.batch",
+	"cronjobs.batch",
+	"services",
+	"secrets",
+	"configmaps",
+	"serviceaccounts",
+	"routes.route.openshift.io",
+}
+
+// RestorePlugin is a restore item action plugin for Velero
+type RestorePlugin struct {
+	Log logrus.FieldLogger
+}
+
+// AppliesTo returns a velero.ResourceSelector that applies to the resources operators commonly create
+func (p *RestorePlugin) AppliesTo() (velero.ResourceSelector, error) {
+	return velero.ResourceSelector{
+		IncludedResources: includedResources,
+	}, nil
+}
+
+// Execute skips the restore of items owned by a restored custom resource, so that its operator recreates them,
+// if the restore sets the skip policy
+func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*velero.RestoreItemActionExecuteOutput, error) {
+	metadata, err := meta.Accessor(input.Item)
+	if err != nil {
+		return nil, err
+	}
+	skip, err := common.SkipOperatorOwned(input.Item, input.Restore, p.Log)
+	if err != nil {
+		p.Log.Infof("[owner-restore] SkipOperatorOwned() failed with err %s", err.Error())
+		return nil, err
+	}
+	annotations := metadata.GetAnnotations()
+	if skip {
+		p.Log.Infof("[owner-restore] Skipping restore of %s, owner %s will be restored and recreate it", metadata.GetName(), annotations[common.BackupControllerOwner])
+		return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
+	}
+	if _, ok := annotations[common.BackupControllerOwner]; ok {
+		delete(annotations, common.BackupControllerOwner)
+		delete(annotations, common.BackupControllerOwnerLabels)
+		metadata.SetAnnotations(annotations)
+	}
+	return velero.NewRestoreItemActionExecuteOutput(input.Item), nil
+}
@@ -0,0 +1,229 @@
+package pod
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1API "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// default time the dockercfg secrets may take to be created after the namespace
+const defaultPullSecretTimeout = 5 * time.Minute
+
+// secret watches of namespaces no pod waited on for this long are stopped
+const pullSecretWatchIdleTimeout = 10 * time.Minute
+
+// errPullSecretTimeout is returned by pullSecretWaiter.Wait if the dockercfg secret is not created in time
+var errPullSecretTimeout = errors.New("dockercfg Secrets are not getting created in time")
+
+// pullSecrets is shared by all pod restores, so that one watch per namespace serves all restored pods
+var pullSecrets = newPullSecretWaiter(func() (corev1client.SecretsGetter, error) {
+	return clients.CoreClient()
+})
+
+// pullSecretWaiter waits for the service account dockercfg secrets of namespaces. Secrets are watched once
+// per namespace and the pods waiting on the namespace are woken up whenever a secret is added.
+type pullSecretWaiter struct {
+	mu         sync.Mutex
+	getClient  func() (corev1client.SecretsGetter, error)
+	namespaces map[string]*namespaceSecrets
+}
+
+// namespaceSecrets is the secret watch of a namespace
+type namespaceSecrets struct {
+	store cache.Store
+	// closed and replaced whenever a secret is added
+	changed     chan struct{}
+	changedLock sync.Mutex
+	stop        chan struct{}
+	// pods currently waiting
+	waiters  int
+	lastUsed time.Time
+}
+
+func newPullSecretWaiter(getClient func() (corev1client.SecretsGetter, error)) *pullSecretWaiter {
+	return &pullSecretWaiter{
+		getClient:  getClient,
+		namespaces: map[string]*namespaceSecrets{},
+	}
+}
+
+// Wait returns the dockercfg secrets of namespace once those of serviceAccounts exist. errPullSecretTimeout
+// is returned if they do not exist by deadline.
+func (w *pullSecretWaiter) Wait(namespace string, serviceAccounts []string, deadline time.Time, log logrus.FieldLogger) (*corev1API.SecretList, error) {
+	secrets, err := w.watch(namespace, log)
+	if err != nil {
+		return nil, err
+	}
+	defer w.done(secrets)
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+	for {
+		// read before listing, so that a secret added meanwhile is not missed
+		secrets.changedLock.Lock()
+		changed := secrets.changed
+		secrets.changedLock.Unlock()
+		secretList := secrets.list()
+		if hasDockercfgSecrets(secretList, serviceAccounts) {
+			log.Infof("[pod-restore] found dockercfg secrets of service accounts %v in namespace %s", serviceAccounts, namespace)
+			return secretList, nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return nil, errPullSecretTimeout
+		}
+	}
+}
+
+// list returns the secrets in the store
+func (s *namespaceSecrets) list() *corev1API.SecretList {
+	secretList := &corev1API.SecretList{}
+	for _, obj := range s.store.List() {
+		if secret, ok := obj.(*corev1API.Secret); ok {
+			secretList.Items = append(secretList.Items, *secret)
+		}
+	}
+	return secretList
+}
+
+// pullSecretServiceAccounts returns the service accounts a pod restored to namespace waits for the dockercfg secrets
+// of: the default service account, whose secret shows that the namespace is set up, and the service accounts of
+// the dockercfg pull secrets of the pod that exist in namespace. Pull secrets of other service accounts are kept.
+func pullSecretServiceAccounts(pod *corev1API.Pod, namespace string, client corev1client.ServiceAccountsGetter) ([]string, error) {
+	serviceAccounts := []string{"default"}
+	for _, secret := range pod.Spec.ImagePullSecrets {
+		serviceAccount := common.DockercfgServiceAccount(secret.Name)
+		if serviceAccount == "" || slices.Contains(serviceAccounts, serviceAccount) {
+			continue
+		}
+		_, err := client.ServiceAccounts(namespace).Get(context.Background(), serviceAccount, metav1.GetOptions{})
+		if k8serrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		serviceAccounts = append(serviceAccounts, serviceAccount)
+	}
+	return serviceAccounts, nil
+}
+
+// hasDockercfgSecrets returns true if secretList has a dockercfg secret of every service account
+func hasDockercfgSecrets(secretList *corev1API.SecretList, serviceAccounts []string) bool {
+	for _, serviceAccount := range serviceAccounts {
+		found := false
+		for _, secret := range secretList.Items {
+			if common.DockercfgServiceAccount(secret.Name) == serviceAccount {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// watch returns the secret watch of namespace, starting it if needed
+func (w *pullSecretWaiter) watch(namespace string, log logrus.FieldLogger) (*namespaceSecrets, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopIdle(log)
+	if secrets := w.namespaces[namespace]; secrets != nil {
+		secrets.waiters++
+		return secrets, nil
+	}
+	client, err := w.getClient()
+	if err != nil {
+		return nil, err
+	}
+	secrets := &namespaceSecrets{
+		changed: make(chan struct{}),
+		stop:    make(chan struct{}),
+		waiters: 1,
+	}
+	// only dockercfg secrets are cached, the watch is shared by the pods of all restores to namespace
+	dockercfgSecrets := fields.OneTermEqualSelector("type", string(corev1API.SecretTypeDockercfg)).String()
+	informer := cache.NewSharedInformer(&cache.ListWatch{
+		ListFunc: func(options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = dockercfgSecrets
+			return client.Secrets(namespace).List(context.Background(), options)
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = dockercfgSecrets
+			return client.Secrets(namespace).Watch(context.Background(), options)
+		},
+	}, &corev1API.Secret{}, 0)
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: func(obj interface{}) {
+			secrets.changedLock.Lock()
+			close(secrets.changed)
+			secrets.changed = make(chan struct{})
+			secrets.changedLock.Unlock()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	secrets.store = informer.GetStore()
+	go informer.Run(secrets.stop)
+	log.Infof("[pod-restore] watching secrets of namespace %s", namespace)
+	w.namespaces[namespace] = secrets
+	return secrets, nil
+}
+
+// done releases a waiter of secrets
+func (w *pullSecretWaiter) done(secrets *namespaceSecrets) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	secrets.waiters--
+	secrets.lastUsed = time.Now()
+}
+
+// stopIdle stops the watches no pod waited on for pullSecretWatchIdleTimeout. w.mu must be held.
+func (w *pullSecretWaiter) stopIdle(log logrus.FieldLogger) {
+	for namespace, secrets := range w.namespaces {
+		if secrets.waiters == 0 && time.Since(secrets.lastUsed) > pullSecretWatchIdleTimeout {
+			log.Infof("[pod-restore] stopping idle secret watch of namespace %s", namespace)
+			close(secrets.stop)
+			delete(w.namespaces, namespace)
+		}
+	}
+}
+
+// getPullSecretOptions returns the RestorePullSecretTimeout and RestorePullSecretFallback options of a restore
+func getPullSecretOptions(restore *velerov1.Restore) (time.Duration, bool, error) {
+	timeout := defaultPullSecretTimeout
+	value, err := common.GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, common.RestorePullSecretTimeout)
+	if err != nil {
+		return 0, false, err
+	}
+	if value != "" {
+		timeout, err = time.ParseDuration(value)
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s %q: %v", common.RestorePullSecretTimeout, value, err)
+		}
+	}
+	fallback, err := common.GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, common.RestorePullSecretFallback)
+	if err != nil {
+		return 0, false, err
+	}
+	return timeout, fallback == "true", nil
+}
@@ -0,0 +1,135 @@
+package pod
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	corev1API "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	corev1client "k8s.io/client-go/kubernetes/typed/core/v1"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func testSecret(namespace, name string) *corev1API.Secret {
+	return &corev1API.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}, Type: corev1API.SecretTypeDockercfg}
+}
+
+func Test_pullSecretWaiter(t *testing.T) {
+	log := test.NewLogger()
+	client := fake.NewSimpleClientset(
+		testSecret("ready", "default-dockercfg-abcde"),
+		testSecret("ready", "builder-dockercfg-abcde"),
+		testSecret("pending", "other"),
+		testSecret("pending", "default-dockercfg-abcde"),
+	)
+	var fieldSelectorsLock sync.Mutex
+	fieldSelectors := map[string]bool{}
+	client.PrependReactor("list", "secrets", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		fieldSelectorsLock.Lock()
+		defer fieldSelectorsLock.Unlock()
+		fieldSelectors[action.(k8stesting.ListAction).GetListRestrictions().Fields.String()] = true
+		return false, nil, nil
+	})
+	waiter := newPullSecretWaiter(func() (corev1client.SecretsGetter, error) {
+		return client.CoreV1(), nil
+	})
+	defer func() {
+		for _, secrets := range waiter.namespaces {
+			close(secrets.stop)
+		}
+	}()
+
+	t.Run("secret exists", func(t *testing.T) {
+		secretList, err := waiter.Wait("ready", []string{"default"}, time.Now().Add(time.Minute), log)
+		if err != nil {
+			t.Fatalf("Wait() error = %v", err)
+		}
+		if len(secretList.Items) != 2 {
+			t.Errorf("Wait() returned %d secrets, want 2", len(secretList.Items))
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := waiter.Wait("missing", []string{"default"}, time.Now().Add(100*time.Millisecond), log)
+		if err != errPullSecretTimeout {
+			t.Errorf("Wait() error = %v, want %v", err, errPullSecretTimeout)
+		}
+	})
+
+	t.Run("pods released when secret is created", func(t *testing.T) {
+		var wg sync.WaitGroup
+		errs := make([]error, 3)
+		for i := range errs {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, errs[i] = waiter.Wait("pending", []string{"default", "myapp"}, time.Now().Add(time.Minute), log)
+			}(i)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if _, err := client.CoreV1().Secrets("pending").Create(context.Background(), testSecret("pending", "myapp-dockercfg-fghij"), metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Errorf("Wait() %d error = %v", i, err)
+			}
+		}
+		if len(waiter.namespaces) != 3 {
+			t.Errorf("%d namespaces watched, want 3", len(waiter.namespaces))
+		}
+	})
+
+	// only dockercfg secrets are listed
+	fieldSelectorsLock.Lock()
+	defer fieldSelectorsLock.Unlock()
+	if want := map[string]bool{"type=kubernetes.io/dockercfg": true}; !reflect.DeepEqual(fieldSelectors, want) {
+		t.Errorf("secrets listed with field selectors %v, want %v", fieldSelectors, want)
+	}
+}
+
+func Test_pullSecretServiceAccounts(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&corev1API.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "builder"}},
+		&corev1API.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Namespace: "app", Name: "myapp"}},
+	)
+	tests := []struct {
+		name           string
+		serviceAccount string
+		pullSecrets    []string
+		want           []string
+	}{
+		{name: "no pull secrets", serviceAccount: "myapp", want: []string{"default"}},
+		{name: "default pull secret", pullSecrets: []string{"default-dockercfg-abcde"}, want: []string{"default"}},
+		{
+			name:           "service account pull secrets",
+			serviceAccount: "myapp",
+			pullSecrets:    []string{"builder-dockercfg-abcde", "myapp-dockercfg-abcde", "builder-dockercfg-fghij"},
+			want:           []string{"default", "builder", "myapp"},
+		},
+		{name: "service account not restored", pullSecrets: []string{"deployer-dockercfg-abcde"}, want: []string{"default"}},
+		{name: "custom pull secret", pullSecrets: []string{"registry-credentials"}, want: []string{"default"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := &corev1API.Pod{Spec: corev1API.PodSpec{ServiceAccountName: tt.serviceAccount}}
+			for _, secret := range tt.pullSecrets {
+				pod.Spec.ImagePullSecrets = append(pod.Spec.ImagePullSecrets, corev1API.LocalObjectReference{Name: secret})
+			}
+			got, err := pullSecretServiceAccounts(pod, "app", client.CoreV1())
+			if err != nil {
+				t.Fatalf("pullSecretServiceAccounts() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("pullSecretServiceAccounts() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,11 +3,7 @@ package pod
 import (
 	"context"
 	"encoding/json"
-	"errors"
-	"fmt"
 	"strconv"
-	"strings"
-	"time"
 
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/clients"
 	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
@@ -118,9 +114,13 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
 	}
 
-	// ISSUE-61 : removing the node selectors from pods
+	// ISSUE-61 : removing the node selectors from pods by default
 	// to avoid pod being `unschedulable` on destination
-	pod.Spec.NodeSelector = nil
+	placement, err := common.GetPlacementPolicy(input.Restore, common.PlacementStrip)
+	if err != nil {
+		return nil, err
+	}
+	placement.Apply(&pod.Spec, p.Log)
 
 	ownerRefs, err := common.GetOwnerReferences(input.ItemFromBackup)
 	if err != nil {
@@ -175,6 +175,13 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	} else {
 		disconnectIfDC = defaultVolumesToFsBackup != nil && *defaultVolumesToFsBackup
 	}
+	// Pods of DCs converted to Deployments are always disconnected so that the Deployment, which keeps the
+	// DC selector, does not select them
+	convert, err := common.ConvertDeploymentConfigs(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	disconnectIfDC = disconnectIfDC || convert
 	if pod.Labels != nil &&
 		pod.Labels[common.DCPodDeploymentLabel] != "" &&
 		pod.Labels[common.DCPodDeploymentConfigLabel] != "" &&
@@ -191,8 +198,18 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 		p.Log.Infof("[pod-restore] pod: %s, GetSrcAndDestRegistryInfo failed with err %s", pod.Name, err.Error())
 		return nil, err
 	}
-	common.SwapContainerImageRefs(pod.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
-	common.SwapContainerImageRefs(pod.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	common.SwapContainerImageRefs(pod.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	common.SwapContainerImageRefs(pod.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	rewriter.Containers(pod.Spec.Containers, p.Log)
+	rewriter.Containers(pod.Spec.InitContainers, p.Log)
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	common.RemapContainerImageDigests(pod.Spec.Containers, digestMapping, p.Log, backupRegistry, registry)
+	common.RemapContainerImageDigests(pod.Spec.InitContainers, digestMapping, p.Log, backupRegistry, registry)
 
 	// update PullSecrets
 	client, err := clients.CoreClient()
@@ -225,87 +242,47 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	// Additionally we also need to check OCP version
 	// Based on the above things we determine whether to skip waiting for docker secret i.e.  if image registry is not enabled and image registry replicas are zero along-with OCP cluster is above 4.15
 	if *p.WaitForPullSecrets {
-		for {
-			secretList, err = client.Secrets(destNamespace).List(context.Background(), metav1.ListOptions{})
-			if err != nil {
-				p.Log.Infof("[pod-restore] pod: %s, Secrets(ns).List() failed for ns %s with err %s", pod.Name, destNamespace, err.Error())
-				return nil, err
-			}
-			flag := 0
-			for _, secret := range secretList.Items {
-				if strings.HasPrefix(secret.Name, "default-dockercfg-") {
-					p.Log.Info(fmt.Sprintf("[pod-restore] Found new dockercfg secret: %v", secret))
-					flag = 1
-					break
-				}
-			}
-			if flag == 1 {
-				p.Log.Info("[pod-restore] the dockercfg secret is created")
-				break
-			}
-			if time.Since(nameSpace.CreationTimestamp.Time) >= 5*time.Minute {
-				return nil, errors.New("default-dockercfg- Secret is not getting created within 5 minutes, exiting")
-			}
-			time.Sleep(time.Second)
-		}
-		for n, secret := range pod.Spec.ImagePullSecrets {
-			newSecret, err := common.UpdatePullSecret(&secret, secretList, p.Log)
-			if err != nil {
-				p.Log.Infof("[pod-restore] pod: %s, UpdatePullSecret() failed with err %s", pod.Name, err.Error())
-				return nil, err
-			}
-			pod.Spec.ImagePullSecrets[n] = *newSecret
+		timeout, fallback, err := getPullSecretOptions(input.Restore)
+		if err != nil {
+			return nil, err
 		}
-	}
-	// if this is a stage pod and there's a stage pod image found
-	destStagePodImage := input.Restore.Annotations[common.StagePodImageAnnotation]
-	if len(pod.Labels[common.IncludedInStageBackupLabel]) > 0 && len(destStagePodImage) > 0 {
-		p.Log.Infof("[pod-restore] swapping stage pod images for pod %s", pod.Name)
-		pvcVolumes := []corev1API.Volume{}
-		excludePVC := []string{}
-		if len(pod.Annotations[common.ExcludePVCPodAnnotation]) > 0 {
-			excludePVC = strings.Split(pod.Annotations[common.ExcludePVCPodAnnotation], ",")
+		serviceAccounts, err := pullSecretServiceAccounts(&pod, destNamespace, client)
+		if err != nil {
+			p.Log.Infof("[pod-restore] pod: %s, getting service accounts of pull secrets in ns %s failed with err %s", pod.Name, destNamespace, err.Error())
+			return nil, err
 		}
-		contains := func(volumeName string) bool {
-			for _, name := range excludePVC {
-				if name == volumeName {
-					return true
+		secretList, err = pullSecrets.Wait(destNamespace, serviceAccounts, nameSpace.CreationTimestamp.Add(timeout), p.Log)
+		if err == errPullSecretTimeout && fallback {
+			p.Log.Warnf("[pod-restore] pod: %s, dockercfg secrets not created within %s, restoring with unchanged pull secrets", pod.Name, timeout)
+			setPullSecretsNotRemapped(&pod)
+		} else if err != nil {
+			p.Log.Infof("[pod-restore] pod: %s, waiting for pull secrets in ns %s failed with err %s", pod.Name, destNamespace, err.Error())
+			return nil, err
+		} else {
+			p.Log.Info("[pod-restore] the dockercfg secrets are created")
+			for n, secret := range pod.Spec.ImagePullSecrets {
+				newSecret, err := common.UpdatePullSecret(&secret, destNamespace, secretList, p.Log)
+				if err != nil && fallback {
+					p.Log.Warnf("[pod-restore] pod: %s, keeping pull secret %s, UpdatePullSecret() failed with err %s", pod.Name, secret.Name, err.Error())
+					setPullSecretsNotRemapped(&pod)
+					continue
 				}
-			}
-			return false
-		}
-		for _, volume := range pod.Spec.Volumes {
-			if volume.PersistentVolumeClaim == nil {
-				continue
-			}
-
-			if len(excludePVC) > 0 && contains(volume.Name) {
-				continue
-			}
-			pvcVolumes = append(pvcVolumes, volume)
-		}
-		pod.Spec.Volumes = pvcVolumes
-		inVolumes := func(mount corev1API.VolumeMount) bool {
-			for _, volume := range pvcVolumes {
-				if volume.Name == mount.Name {
-					return true
+				if err != nil {
+					p.Log.Infof("[pod-restore] pod: %s, UpdatePullSecret() failed with err %s", pod.Name, err.Error())
+					return nil, err
 				}
+				pod.Spec.ImagePullSecrets[n] = *newSecret
 			}
-			return false
 		}
-
-		for n, container := range pod.Spec.Containers {
-			p.Log.Infof("[pod-restore] swapping stage pod image from %s to %s", container.Image, destStagePodImage)
-			pod.Spec.Containers[n].Image = destStagePodImage
-			pod.Spec.Containers[n].Command = []string{"sleep"}
-			pod.Spec.Containers[n].Args = []string{"infinity"}
-			volumeMount := []corev1API.VolumeMount{}
-			for _, vol := range container.VolumeMounts {
-				if inVolumes(vol) {
-					volumeMount = append(volumeMount, vol)
-				}
-			}
-			pod.Spec.Containers[n].VolumeMounts = volumeMount
+	}
+	// if this is a stage pod and there's a stage pod image found
+	if len(pod.Labels[common.IncludedInStageBackupLabel]) > 0 {
+		template, err := getStagePodTemplate(input.Restore)
+		if err != nil {
+			return nil, err
+		}
+		if len(template.Image) > 0 {
+			buildStagePod(&pod, template, p.Log)
 		}
 	}
 	var out map[string]interface{}
@@ -361,3 +338,11 @@ func (p *RestorePlugin) UpdateWaitForPullSecrets() (bool, error) {
 
 	return true, nil
 }
+
+// setPullSecretsNotRemapped records on pod that its pull secrets were restored unchanged
+func setPullSecretsNotRemapped(pod *corev1API.Pod) {
+	if pod.Annotations == nil {
+		pod.Annotations = map[string]string{}
+	}
+	pod.Annotations[common.PullSecretsNotRemapped] = "true"
+}
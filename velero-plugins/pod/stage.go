@@ -0,0 +1,152 @@
+package pod
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/sirupsen/logrus"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1API "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/yaml"
+)
+
+// name of the single container of stage pods
+const stageContainerName = "stage"
+
+// PVCs whose mount path is already taken are mounted below this path
+const stageMountRoot = "/var/lib/stage"
+
+// defaultStagePodResources are the resources of the stage container if the template sets none
+var defaultStagePodResources = corev1API.ResourceRequirements{
+	Requests: corev1API.ResourceList{
+		corev1API.ResourceCPU:    resource.MustParse("10m"),
+		corev1API.ResourceMemory: resource.MustParse("32Mi"),
+	},
+	Limits: corev1API.ResourceList{
+		corev1API.ResourceCPU:    resource.MustParse("100m"),
+		corev1API.ResourceMemory: resource.MustParse("128Mi"),
+	},
+}
+
+// StagePodTemplate customizes the pods restored in place of application pods by stage restores. Stage pods run a
+// single container mounting all PVCs of the application pod that are not excluded.
+type StagePodTemplate struct {
+	// image of the stage container, defaults to the StagePodImageAnnotation of the restore
+	Image string `json:"image,omitempty"`
+	// defaults to sleep infinity
+	Command []string `json:"command,omitempty"`
+	Args    []string `json:"args,omitempty"`
+	// defaults to defaultStagePodResources
+	Resources *corev1API.ResourceRequirements `json:"resources,omitempty"`
+	// security context of the stage container, defaults to the one of the first application container
+	SecurityContext *corev1API.SecurityContext `json:"securityContext,omitempty"`
+	// pod security context, the application pod security context is kept if not set
+	PodSecurityContext *corev1API.PodSecurityContext `json:"podSecurityContext,omitempty"`
+	// tolerations replacing those of the application pod, if set
+	Tolerations []corev1API.Toleration `json:"tolerations,omitempty"`
+	// labels added to the stage pod
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// getStagePodTemplate returns the StagePodTemplate option of a restore, YAML or JSON, with its image set to
+// the StagePodImageAnnotation if it has none
+func getStagePodTemplate(restore *velerov1.Restore) (*StagePodTemplate, error) {
+	template := &StagePodTemplate{}
+	value, err := common.GetPluginOption(restore.UID, restore.Namespace, restore.Annotations, common.StagePodTemplate)
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal([]byte(value), template); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", common.StagePodTemplate, err)
+	}
+	if template.Image == "" {
+		template.Image = restore.Annotations[common.StagePodImageAnnotation]
+	}
+	return template, nil
+}
+
+// stagePodVolumes returns the PVC volumes of pod that are not excluded by ExcludePVCPodAnnotation
+func stagePodVolumes(pod *corev1API.Pod) []corev1API.Volume {
+	excludePVC := map[string]bool{}
+	if len(pod.Annotations[common.ExcludePVCPodAnnotation]) > 0 {
+		for _, name := range strings.Split(pod.Annotations[common.ExcludePVCPodAnnotation], ",") {
+			excludePVC[name] = true
+		}
+	}
+	pvcVolumes := []corev1API.Volume{}
+	for _, volume := range pod.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil || excludePVC[volume.Name] {
+			continue
+		}
+		pvcVolumes = append(pvcVolumes, volume)
+	}
+	return pvcVolumes
+}
+
+// stagePodVolumeMounts returns a mount of every volume, at its first mount path in the containers if that
+// is not taken by another volume
+func stagePodVolumeMounts(volumes []corev1API.Volume, containers []corev1API.Container) []corev1API.VolumeMount {
+	mountPaths := map[string]string{}
+	for _, container := range containers {
+		for _, mount := range container.VolumeMounts {
+			if _, ok := mountPaths[mount.Name]; !ok {
+				mountPaths[mount.Name] = mount.MountPath
+			}
+		}
+	}
+	taken := map[string]bool{}
+	volumeMounts := []corev1API.VolumeMount{}
+	for _, volume := range volumes {
+		mountPath := mountPaths[volume.Name]
+		if mountPath == "" || taken[mountPath] {
+			mountPath = stageMountRoot + "/" + volume.Name
+		}
+		taken[mountPath] = true
+		volumeMounts = append(volumeMounts, corev1API.VolumeMount{Name: volume.Name, MountPath: mountPath})
+	}
+	return volumeMounts
+}
+
+// buildStagePod replaces the containers of pod with a single stage container mounting its PVCs
+func buildStagePod(pod *corev1API.Pod, template *StagePodTemplate, log logrus.FieldLogger) {
+	log.Infof("[pod-restore] replacing containers of pod %s with stage container running %s", pod.Name, template.Image)
+	pod.Spec.Volumes = stagePodVolumes(pod)
+	container := corev1API.Container{
+		Name:         stageContainerName,
+		Image:        template.Image,
+		Command:      template.Command,
+		Args:         template.Args,
+		Resources:    defaultStagePodResources,
+		VolumeMounts: stagePodVolumeMounts(pod.Spec.Volumes, pod.Spec.Containers),
+	}
+	if len(container.Command) == 0 {
+		container.Command = []string{"sleep"}
+		container.Args = []string{"infinity"}
+	}
+	if template.Resources != nil {
+		container.Resources = *template.Resources
+	}
+	if template.SecurityContext != nil {
+		container.SecurityContext = template.SecurityContext
+	} else if len(pod.Spec.Containers) > 0 {
+		container.SecurityContext = pod.Spec.Containers[0].SecurityContext
+	}
+	pod.Spec.Containers = []corev1API.Container{container}
+	// init containers would run against volumes the stage pod does not have
+	pod.Spec.InitContainers = nil
+	pod.Spec.EphemeralContainers = nil
+	if template.PodSecurityContext != nil {
+		pod.Spec.SecurityContext = template.PodSecurityContext
+	}
+	if template.Tolerations != nil {
+		pod.Spec.Tolerations = template.Tolerations
+	}
+	for key, value := range template.Labels {
+		if pod.Labels == nil {
+			pod.Labels = map[string]string{}
+		}
+		pod.Labels[key] = value
+	}
+}
@@ -0,0 +1,171 @@
+package pod
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/util/test"
+	velerov1 "github.com/vmware-tanzu/velero/pkg/apis/velero/v1"
+	corev1API "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testAppPod() *corev1API.Pod {
+	runAsNonRoot := true
+	return &corev1API.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "app",
+			Labels:      map[string]string{common.IncludedInStageBackupLabel: "true"},
+			Annotations: map[string]string{common.ExcludePVCPodAnnotation: "cache"},
+		},
+		Spec: corev1API.PodSpec{
+			Volumes: []corev1API.Volume{
+				{Name: "data", VolumeSource: corev1API.VolumeSource{PersistentVolumeClaim: &corev1API.PersistentVolumeClaimVolumeSource{ClaimName: "data"}}},
+				{Name: "logs", VolumeSource: corev1API.VolumeSource{PersistentVolumeClaim: &corev1API.PersistentVolumeClaimVolumeSource{ClaimName: "logs"}}},
+				{Name: "cache", VolumeSource: corev1API.VolumeSource{PersistentVolumeClaim: &corev1API.PersistentVolumeClaimVolumeSource{ClaimName: "cache"}}},
+				{Name: "config", VolumeSource: corev1API.VolumeSource{ConfigMap: &corev1API.ConfigMapVolumeSource{}}},
+			},
+			Containers: []corev1API.Container{
+				{
+					Name:            "app",
+					Image:           "app:latest",
+					SecurityContext: &corev1API.SecurityContext{RunAsNonRoot: &runAsNonRoot},
+					VolumeMounts: []corev1API.VolumeMount{
+						{Name: "data", MountPath: "/data"},
+						{Name: "config", MountPath: "/config"},
+					},
+					LivenessProbe: &corev1API.Probe{},
+				},
+				{
+					Name:  "sidecar",
+					Image: "sidecar:latest",
+					VolumeMounts: []corev1API.VolumeMount{
+						{Name: "logs", MountPath: "/data"},
+					},
+				},
+			},
+		},
+	}
+}
+
+func Test_buildStagePod(t *testing.T) {
+	tests := []struct {
+		name     string
+		template StagePodTemplate
+		want     corev1API.Container
+		labels   map[string]string
+		// initContainer adds an init container mounting the config map volume to the pod
+		initContainer bool
+	}{
+		{
+			name:     "default template",
+			template: StagePodTemplate{Image: "stage:latest"},
+			want: corev1API.Container{
+				Name:            stageContainerName,
+				Image:           "stage:latest",
+				Command:         []string{"sleep"},
+				Args:            []string{"infinity"},
+				Resources:       defaultStagePodResources,
+				SecurityContext: testAppPod().Spec.Containers[0].SecurityContext,
+				VolumeMounts: []corev1API.VolumeMount{
+					{Name: "data", MountPath: "/data"},
+					{Name: "logs", MountPath: stageMountRoot + "/logs"},
+				},
+			},
+			labels: map[string]string{common.IncludedInStageBackupLabel: "true"},
+		},
+		{
+			name: "custom template",
+			template: StagePodTemplate{
+				Image:   "stage:latest",
+				Command: []string{"/bin/pause"},
+				Resources: &corev1API.ResourceRequirements{
+					Limits: corev1API.ResourceList{corev1API.ResourceMemory: resource.MustParse("64Mi")},
+				},
+				SecurityContext: &corev1API.SecurityContext{},
+				Labels:          map[string]string{"stage": "true"},
+			},
+			want: corev1API.Container{
+				Name:    stageContainerName,
+				Image:   "stage:latest",
+				Command: []string{"/bin/pause"},
+				Resources: corev1API.ResourceRequirements{
+					Limits: corev1API.ResourceList{corev1API.ResourceMemory: resource.MustParse("64Mi")},
+				},
+				SecurityContext: &corev1API.SecurityContext{},
+				VolumeMounts: []corev1API.VolumeMount{
+					{Name: "data", MountPath: "/data"},
+					{Name: "logs", MountPath: stageMountRoot + "/logs"},
+				},
+			},
+			labels: map[string]string{common.IncludedInStageBackupLabel: "true", "stage": "true"},
+		},
+		{
+			name:     "init container",
+			template: StagePodTemplate{Image: "stage:latest"},
+			want: corev1API.Container{
+				Name:            stageContainerName,
+				Image:           "stage:latest",
+				Command:         []string{"sleep"},
+				Args:            []string{"infinity"},
+				Resources:       defaultStagePodResources,
+				SecurityContext: testAppPod().Spec.Containers[0].SecurityContext,
+				VolumeMounts: []corev1API.VolumeMount{
+					{Name: "data", MountPath: "/data"},
+					{Name: "logs", MountPath: stageMountRoot + "/logs"},
+				},
+			},
+			labels:        map[string]string{common.IncludedInStageBackupLabel: "true"},
+			initContainer: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pod := testAppPod()
+			if tt.initContainer {
+				pod.Spec.InitContainers = []corev1API.Container{{
+					Name:         "init",
+					Image:        "init:latest",
+					VolumeMounts: []corev1API.VolumeMount{{Name: "config", MountPath: "/config"}},
+				}}
+			}
+			buildStagePod(pod, &tt.template, test.NewLogger())
+			if len(pod.Spec.InitContainers) != 0 {
+				t.Errorf("buildStagePod() kept %d init containers, want none", len(pod.Spec.InitContainers))
+			}
+			if len(pod.Spec.Containers) != 1 {
+				t.Fatalf("buildStagePod() left %d containers, want 1", len(pod.Spec.Containers))
+			}
+			if !reflect.DeepEqual(pod.Spec.Containers[0], tt.want) {
+				t.Errorf("buildStagePod() container = %+v, want %+v", pod.Spec.Containers[0], tt.want)
+			}
+			if len(pod.Spec.Volumes) != 2 {
+				t.Errorf("buildStagePod() kept %d volumes, want 2", len(pod.Spec.Volumes))
+			}
+			if !reflect.DeepEqual(pod.Labels, tt.labels) {
+				t.Errorf("buildStagePod() labels = %v, want %v", pod.Labels, tt.labels)
+			}
+		})
+	}
+}
+
+func Test_getStagePodTemplate(t *testing.T) {
+	restore := &velerov1.Restore{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				common.StagePodImageAnnotation: "stage:latest",
+				common.StagePodTemplate:        "command: [/bin/pause]\nlabels:\n  stage: \"true\"\n",
+			},
+		},
+	}
+	template, err := getStagePodTemplate(restore)
+	if err != nil {
+		t.Fatalf("getStagePodTemplate() error = %v", err)
+	}
+	want := &StagePodTemplate{Image: "stage:latest", Command: []string{"/bin/pause"}, Labels: map[string]string{"stage": "true"}}
+	if !reflect.DeepEqual(template, want) {
+		t.Errorf("getStagePodTemplate() = %+v, want %+v", template, want)
+	}
+}
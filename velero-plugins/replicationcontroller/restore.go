@@ -35,8 +35,25 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	if err != nil {
 		return nil, err
 	}
-	common.SwapContainerImageRefs(replicationController.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
-	common.SwapContainerImageRefs(replicationController.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping)
+	backupAliases := common.GetBackupRegistryAliases(input.Item)
+	common.SwapContainerImageRefs(replicationController.Spec.Template.Spec.Containers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	common.SwapContainerImageRefs(replicationController.Spec.Template.Spec.InitContainers, backupRegistry, registry, p.Log, input.Restore.Spec.NamespaceMapping, backupAliases...)
+	rewriter, err := common.GetNamespaceRewriter(input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	rewriter.Containers(replicationController.Spec.Template.Spec.Containers, p.Log)
+	rewriter.Containers(replicationController.Spec.Template.Spec.InitContainers, p.Log)
+	digestMapping := common.GetDigestMappingForRestore(input.Restore, p.Log)
+	common.RemapContainerImageDigests(replicationController.Spec.Template.Spec.Containers, digestMapping, p.Log, backupRegistry, registry)
+	common.RemapContainerImageDigests(replicationController.Spec.Template.Spec.InitContainers, digestMapping, p.Log, backupRegistry, registry)
+	namespace, err := common.GetRestoreNamespace(input.ItemFromBackup, input.Restore)
+	if err != nil {
+		return nil, err
+	}
+	if err := common.UpdatePodSpecPullSecrets(&replicationController.Spec.Template.Spec, namespace, p.Log); err != nil {
+		return nil, err
+	}
 
 	ownerRefs, err := common.GetOwnerReferences(input.ItemFromBackup)
 	if err != nil {
@@ -44,11 +61,19 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	}
 
 	annotations := replicationController.Annotations
+	convert, err := common.ConvertDeploymentConfigs(input.Restore)
+	if err != nil {
+		return nil, err
+	}
 
 	// Don't restore ReplicationController if owned by DeploymentConfig
 	for i := range ownerRefs {
 		ref := ownerRefs[i]
 		if ref.Kind == "DeploymentConfig" {
+			if convert {
+				p.Log.Infof("[replicationcontroller-restore] skipping restore of ReplicationController %s, DeploymentConfig is converted to Deployment", replicationController.Name)
+				return velero.NewRestoreItemActionExecuteOutput(input.Item).WithoutRestore(), nil
+			}
 			if _, ok := annotations[common.PausedOwnerRef]; ok {
 				delete(annotations, common.PausedOwnerRef)
 				replicationController.Annotations = annotations
@@ -3,6 +3,7 @@ package service
 import (
 	"encoding/json"
 
+	"github.com/konveyor/openshift-velero-plugin/velero-plugins/common"
 	"github.com/sirupsen/logrus"
 	"github.com/vmware-tanzu/velero/pkg/plugin/velero"
 	corev1API "k8s.io/api/core/v1"
@@ -30,6 +31,17 @@ func (p *RestorePlugin) Execute(input *velero.RestoreItemActionExecuteInput) (*v
 	json.Unmarshal(itemMarshal, &service)
 	p.Log.Infof("[service-restore] service: %s", service.Name)
 
+	if service.Spec.Type == corev1API.ServiceTypeExternalName {
+		rewriter, err := common.GetNamespaceRewriter(input.Restore)
+		if err != nil {
+			return nil, err
+		}
+		if externalName := rewriter.DNSNames(service.Spec.ExternalName); externalName != service.Spec.ExternalName {
+			p.Log.Infof("[service-restore] Mapping namespace of externalName %s to %s", service.Spec.ExternalName, externalName)
+			service.Spec.ExternalName = externalName
+		}
+	}
+
 	// only clear ExternalIPs for LoadBalancer services
 	if service.Spec.Type == corev1API.ServiceTypeLoadBalancer {
 		p.Log.Infof("[service-restore] Clearing externalIPs for LoadBalancer service: %s", service.Name)